@@ -35,12 +35,13 @@ import(
 type Tile struct {
   // golang: variables have default values; use 0,0 tile as default, resulting in 1-based indexing to handle default uninitialized case
 
-  // 1-3: dots, bamboo, characters; 4: honors; 5 bonus (retitled special)
+  // 1-3: dots, bamboo, characters; 4: honors; 5 bonus (retitled special); 6 joker
   Suit int
   
   // for standard suits, 1-9; 
   // for honors: east, south, west, north, red, green, white; 
   // for bonus: flowers 1-4; seasons spring to fall
+  // for jokers: 1
   Value int
   
   // for tile tracking
@@ -48,11 +49,25 @@ type Tile struct {
   
   // unicode display
   Ud string
+  
+  // red five (counts as dora under riichi rules)
+  Red bool
 }
 
 // uninitialized tile
 var EmptyTile Tile
 
+// create a tile; instance (0-3, 0-7 for jokers) distinguishes the copies of a tile and is ignored for special tiles
+func NewTile(suit int, value int, instance int) Tile {
+  id := (suit-1)*36+(value-1)*4+instance+1
+  if suit == 5 {
+    id = 3*36+7*4+value
+  } else if suit == 6 {
+    id = TilesInGame+instance+1
+  }
+  return Tile{ Suit: suit, Value: value, Id: id, Ud: UnicodeDisplay[suit-1][value] }
+}
+
 // golang: native support for unicode!
 // return tile serial and human readable character
 func (t Tile) String() string {
@@ -64,6 +79,17 @@ func (t Tile) UdString() string {
   return fmt.Sprintf("%v", t.Ud)
 }
 
+// return compact notation: value followed by suit letter (e.g., 5p, 7z); a red five is written as 0 (e.g., 0p)
+func (t Tile) Notation() string {
+  if t == EmptyTile {
+    return ""
+  }
+  if t.Red {
+    return fmt.Sprintf("0%s", SuitNotation[t.Suit-1])
+  }
+  return fmt.Sprintf("%d%s", t.Value, SuitNotation[t.Suit-1])
+}
+
 // return if a tile is a special tile
 func (t Tile) IsSpecial() bool {
   if t.Suit == 5 {
@@ -72,25 +98,36 @@ func (t Tile) IsSpecial() bool {
   return false
 }
 
+// return if a tile is a joker, standing in for a tile of a card hand
+func (t Tile) IsJoker() bool {
+  return t.Suit == 6
+}
+
 // # tileCollection
 type TileCollection []Tile
 // translation to unicode characters
 var UnicodeDisplay [][]string
 // show verbose debug messages
 var VerboseDebug bool
-// tiles needed for a special win
-var SpecialWinTiles map[string]int
+// suit letters for notation: dots (p), bamboo (s), characters (m), honors (z), bonus (f), jokers (j)
+var SuitNotation []string
 // is rand deterministic?
 var DeterministicRand bool
 
 const (
   TilesInGame = 144
+  // most jokers a wall may hold
+  MaxJokers = 8
+  // back of a tile, for tiles shown face-down
+  TileBack = "🀫"
 )
 
 // discarded tile
 type DiscardedTile struct {
   Player int
   Item Tile
+  // taken by another player to make a set; the tile stays in the pile, marked
+  Claimed bool
 }
 
 // discards
@@ -101,33 +138,20 @@ func init() {
   VerboseDebug = false
   DeterministicRand = false
   
-  // allocate tiles needed for a special win
-  SpecialWinTiles = make(map[string]int)
-  // zero to handle no match case
-  SpecialWinTiles["🀀"] = 1
-  SpecialWinTiles["🀁"] = 2
-  SpecialWinTiles["🀂"] = 3
-  SpecialWinTiles["🀃"] = 4
-  SpecialWinTiles["🀄"] = 5
-  SpecialWinTiles["🀅"] = 6
-  SpecialWinTiles["🀆"] = 7
-  SpecialWinTiles["🀙"] = 8
-  SpecialWinTiles["🀡"] = 9
-  SpecialWinTiles["🀐"] = 10
-  SpecialWinTiles["🀘"] = 11
-  SpecialWinTiles["🀇"] = 12
-  SpecialWinTiles["🀏"] = 13
+  // honors follow the Value order: east, south, west, north, red, green, white
+  SuitNotation = []string {"p", "s", "m", "z", "f", "j"}
   
   // seed deterministic generator
   insecureRand.Seed(12345);
   
   // allocate Unicode display content
-  UnicodeDisplay = make([][]string, 5, 5)
+  UnicodeDisplay = make([][]string, 6, 6)
   UnicodeDisplay[0] = []string {"", "🀙", "🀚", "🀛", "🀜", "🀝", "🀞", "🀟", "🀠", "🀡"}
   UnicodeDisplay[1] = []string {"", "🀐", "🀑", "🀒", "🀓", "🀔", "🀕", "🀖", "🀗", "🀘"}
   UnicodeDisplay[2] = []string {"", "🀇", "🀈", "🀉", "🀊", "🀋", "🀌", "🀍", "🀎", "🀏"}
   UnicodeDisplay[3] = []string {"", "🀀", "🀁", "🀂", "🀃", "🀄", "🀅", "🀆"}
   UnicodeDisplay[4] = []string {"", "🀢", "🀣", "🀤", "🀥", "🀦", "🀧", "🀨", "🀩"}
+  UnicodeDisplay[5] = []string {"", "🃏"}
 }
 
 // shuffle undealt tiles (only if not previously shuffled)
@@ -157,7 +181,7 @@ func (g *Game) Shuffle(deterministic bool) error {
 
 // output undealt tiles and positions
 func (g *Game) OutputUndealtTiles() {
-  for i := 0; i < TilesInGame; i++ {
+  for i := 0; i < len(g.Undealt); i++ {
     if g.Undealt[i] != EmptyTile {
       fmt.Printf("%3d: %v\n", i, g.Undealt[i])
     } else {
@@ -176,22 +200,34 @@ func (g *Game) SetDealLocations(diceRoll int) error {
     g.DrawLocationsSet = true
   }
   
+  // tiles along each side of the wall; one side for each player
+  wallLength := len(g.Undealt)/g.Rules.Players
+  
   // only valid if in the east location
-  g.AllocationStart = ((diceRoll-1)%4)*36+(diceRoll)*2+g.StartPlayer*36
+  g.AllocationStart = ((diceRoll-1)%g.Rules.Players)*wallLength+(diceRoll)*2+g.StartPlayer*wallLength
   
-  g.DrawPointer = (g.AllocationStart + 16*3 + 5) % 144
-  g.ReplacementPointer = g.AllocationStart-1 % 144
+  g.DrawPointer = (g.AllocationStart + g.initialDealLength()) % len(g.Undealt)
+  g.ReplacementPointer = (g.AllocationStart-1) % len(g.Undealt)
   
   return nil
 }
 
+// tiles taken from the wall by the initial deal: rounds of four for each player, then the last tiles with the dealer's extra tile
+func (g *Game) initialDealLength() int {
+  rounds := g.Rules.HandSize/4
+  if g.Rules.HandSize % 4 == 0 {
+    return 4*g.Rules.Players*rounds + 1
+  }
+  return 4*g.Rules.Players*rounds + g.Rules.Players + 1
+}
+
 func (g *Game) GetInitialTile(round int, player int, current int) (Tile, error) {
     // have tiles to deal?
   if g.UndealtTileCount < 1 {
     return EmptyTile, fmt.Errorf("no tiles to deal; undealt tile count at %d", g.UndealtTileCount)
   }
   
-  allocationPosition := (g.AllocationStart + 16*round + 4*player + current) % 144
+  allocationPosition := (g.AllocationStart + 4*g.Rules.Players*round + 4*player + current) % len(g.Undealt)
   if g.Undealt[allocationPosition] == EmptyTile {
     return EmptyTile, fmt.Errorf("round %d, player %d, item %d with an AllocationStart of %d yields %d, which is empty", round, player, current, g.AllocationStart, allocationPosition)
   }
@@ -204,20 +240,37 @@ func (g *Game) GetInitialTile(round int, player int, current int) (Tile, error)
   return selection, nil
 }
 
+// tiles left to be drawn in turn: those outside the dead wall and the reserved tiles
+func (g *Game) LiveTiles() int {
+  live := g.UndealtTileCount - g.Rules.DeadWall - g.Rules.ReservedTiles
+  if live < 0 {
+    return 0
+  }
+  return live
+}
+
 // retrieve new tile for both draw and replacement
 func (g *Game) GetNewTile(pointer* int, replacement bool) (Tile, error) {
   if g.UndealtTileCount < 1 {
-    return EmptyTile, errors.New("no more tiles to deal")
+    return EmptyTile, errors.New("the wall is empty")
+  }
+  
+  if !replacement && g.LiveTiles() < 1 {
+    return EmptyTile, fmt.Errorf("the live wall is exhausted; only the %d tiles of the dead wall and the %d reserved tiles remain", g.Rules.DeadWall, g.Rules.ReservedTiles)
+  }
+  
+  if replacement && g.UndealtTileCount <= g.Rules.ReservedTiles {
+    return EmptyTile, fmt.Errorf("no replacement tile; only the %d reserved tiles remain", g.Rules.ReservedTiles)
   }
   
   if (*pointer) < 0 {
     return EmptyTile, errors.New("uninitialized pointer?")
   }
   
-  (*pointer) = (*pointer) % 144
+  (*pointer) = (*pointer) % len(g.Undealt)
 
   if (*pointer) < 0 || g.Undealt[(*pointer)] == EmptyTile {
-    return EmptyTile, fmt.Errorf("expected a tile at position %d, which is empty", (*pointer))
+    return EmptyTile, fmt.Errorf("the draw and replacement pointers have met at position %d, which is empty", (*pointer))
   }
   
   var newTile Tile
@@ -225,7 +278,8 @@ func (g *Game) GetNewTile(pointer* int, replacement bool) (Tile, error) {
   g.UndealtTileCount--
   
   if replacement {
-    (*pointer)--
+    // wrap around the start of the wall
+    (*pointer) = ((*pointer) - 1 + len(g.Undealt)) % len(g.Undealt)
   } else {
     (*pointer)++
   }
@@ -259,7 +313,11 @@ func (g *Game) OutputDiscardedTiles() {
         if j == 0 {
           fmt.Printf("D: ")
         }
-        fmt.Printf("(%v-%d)", g.Discard[k].Item.Ud, g.Discard[k].Player)
+        if g.Discard[k].Claimed {
+          fmt.Printf("[%v-%d]", g.Discard[k].Item.Ud, g.Discard[k].Player)
+        } else {
+          fmt.Printf("(%v-%d)", g.Discard[k].Item.Ud, g.Discard[k].Player)
+        }
         if j == 7 {
           fmt.Println()
         }
@@ -0,0 +1,178 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// physical layout of the undealt tiles: a wall in front of each player, two tiles tall
+package mahjong
+
+import(
+  "fmt"
+  "strings"
+)
+
+// # Wall
+// column of two tiles; the top tile is taken first when dealing and drawing
+type WallStack struct {
+  Top Tile
+  Bottom Tile
+  // position of the top tile among the undealt tiles; the bottom tile follows it
+  Position int
+  // holds tiles of the dead wall, which are drawn only as replacements, or reserved tiles, which are never drawn
+  Dead bool
+}
+
+// the undealt tiles as stacks, in front of each seat
+type Wall struct {
+  // stacks of each side, indexed by the seat the side is in front of, in dealing order
+  Sides [][]WallStack
+  // tiles along each side; the last side takes any remainder
+  SideLength int
+  // side and stack where the wall was broken; the deal starts from this stack
+  BreakSide int
+  BreakStack int
+  // tiles left in the wall, and how many of them are in the dead wall or reserved
+  Remaining int
+  DeadTiles int
+}
+
+// glyphs for a stack: both tiles, the top tile only, the bottom tile only, neither
+var WallGlyphs []string
+// glyph for a full stack of the dead wall
+var DeadWallGlyph string
+// glyph for the stack at the break, once dealt
+var WallBreakGlyph string
+
+func init() {
+  WallGlyphs = []string {"█", "▀", "▄", "·"}
+  DeadWallGlyph = "▓"
+  WallBreakGlyph = "╳"
+}
+
+// view of the undealt tiles as a physical wall
+func (g *Game) Wall() Wall {
+  n := len(g.Undealt)
+  w := Wall{ Sides: make([][]WallStack, g.Rules.Players), Remaining: g.UndealtTileCount }
+  if n == 0 {
+    return w
+  }
+  w.SideLength = n/g.Rules.Players
+
+  // the dead wall and reserved tiles are the last of the remaining tiles, up to the replacement pointer
+  dead := make(map[int]bool)
+  if g.DrawLocationsSet {
+    for i := 0; i < g.Rules.DeadWall+g.Rules.ReservedTiles && i < g.UndealtTileCount; i++ {
+      dead[((g.ReplacementPointer-i) % n + n) % n] = true
+    }
+    w.DeadTiles = len(dead)
+  }
+
+  for side := range w.Sides {
+    end := (side+1)*w.SideLength
+    if side == len(w.Sides)-1 {
+      end = n
+    }
+    for p := side*w.SideLength; p < end; p += 2 {
+      stack := WallStack{ Top: g.Undealt[p], Position: p, Dead: dead[p] }
+      if p+1 < end {
+        stack.Bottom = g.Undealt[p+1]
+        stack.Dead = stack.Dead || dead[p+1]
+      }
+      w.Sides[side] = append(w.Sides[side], stack)
+    }
+  }
+
+  if g.DrawLocationsSet {
+    w.BreakSide, w.BreakStack = w.Locate(g.AllocationStart % n)
+  }
+  return w
+}
+
+// side and stack holding a position among the undealt tiles
+func (w Wall) Locate(position int) (int, int) {
+  side := position/w.SideLength
+  if side >= len(w.Sides) {
+    side = len(w.Sides)-1
+  }
+  return side, (position - side*w.SideLength)/2
+}
+
+// glyph for a stack; tiles are face-down, so only their presence is shown
+func (w Wall) glyph(side int, stack int) string {
+  if stack >= len(w.Sides[side]) {
+    return " "
+  }
+  s := w.Sides[side][stack]
+  switch {
+    case s.Top != EmptyTile && s.Bottom != EmptyTile && s.Dead:
+      return DeadWallGlyph
+    case s.Top != EmptyTile && s.Bottom != EmptyTile:
+      return WallGlyphs[0]
+    case s.Top != EmptyTile:
+      return WallGlyphs[1]
+    case s.Bottom != EmptyTile:
+      return WallGlyphs[2]
+    case side == w.BreakSide && stack == w.BreakStack:
+      return WallBreakGlyph
+  }
+  return WallGlyphs[3]
+}
+
+// draw the walls around the table from a player's point of view: their own wall along the bottom,
+// the next player's on the right, across at the top and the previous player's on the left
+// stacks follow the dealing order, which runs around the table from one wall to the next
+func (w Wall) Render(player int) []string {
+  players := len(w.Sides)
+  if players == 0 || w.SideLength == 0 {
+    return []string {}
+  }
+  length := 0
+  for _, side := range w.Sides {
+    if len(side) > length {
+      length = len(side)
+    }
+  }
+
+  bottom := player
+  right := (player+1)%players
+  left := (player+players-1)%players
+
+  lines := make([]string, 0, length+3)
+
+  // across, dealt from right to left
+  top := " "
+  if players == 4 {
+    across := (player+2)%players
+    for i := length-1; i >= 0; i-- {
+      top += w.glyph(across, i)
+    }
+  }
+  lines = append(lines, top)
+
+  // the left wall is dealt downwards, the right wall upwards
+  for i := 0; i < length; i++ {
+    lines = append(lines, w.glyph(left, i)+strings.Repeat(" ", length)+w.glyph(right, length-1-i))
+  }
+
+  own := " "
+  for i := 0; i < length; i++ {
+    own += w.glyph(bottom, i)
+  }
+  lines = append(lines, own)
+
+  lines = append(lines, fmt.Sprintf("wall broken at P%d's wall, stack %d; %d tiles remain, %d of them in the dead wall (%s)", w.BreakSide, w.BreakStack+1, w.Remaining, w.DeadTiles, DeadWallGlyph))
+  return lines
+}
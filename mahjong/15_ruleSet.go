@@ -0,0 +1,574 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// rule sets: built-in presets and rule files (json or toml) toggling the rules of play
+package mahjong
+
+import(
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "path/filepath"
+  "strconv"
+  "strings"
+)
+
+// rules of play; the zero value is not usable, start from a preset instead
+type RuleSet struct {
+  Name string `json:"name"`
+  // bonus tiles (flowers and seasons) in the wall, replaced as drawn
+  Flowers bool `json:"flowers"`
+  // the next player may claim a discard to form a sequence
+  ChowAllowed bool `json:"chowAllowed"`
+  // a discard from any player, not only the previous one, may complete a sequence for a win
+  WinOnAnySequence bool `json:"winOnAnySequence"`
+  // a win scoring less than this is refused
+  MinimumFaan int `json:"minimumFaan"`
+  // special (non-standard) winning hands accepted, e.g., thirteenOrphans
+  SpecialHands []string `json:"specialHands"`
+  // further special hands written as patterns (see ParseHandPattern), e.g., house rules
+  HandPatterns []string `json:"handPatterns"`
+  // order in which claims on a discard are offered: win, kong, pong and chow
+  ClaimPriority []string `json:"claimPriority"`
+  // after a drawn game: dealerStays, rotate or dealerReady
+  ExhaustiveDraw string `json:"exhaustiveDraw"`
+  // scoring system used for the minimum and for reporting wins
+  Scoring string `json:"scoring"`
+  // one five of each standard suit is red
+  RedFives bool `json:"redFives"`
+  // tiles at the end of the wall that are never drawn, other than as replacements
+  DeadWall int `json:"deadWall"`
+  // tiles at the end of the wall that are never drawn, not even as replacements; the game is drawn once only these remain
+  ReservedTiles int `json:"reservedTiles"`
+  // who pays for a win on a discard: discarderPays (the discarder pays every share) or allPay; empty for discarderPays
+  Payment string `json:"payment"`
+  // each share of a self-drawn win is multiplied by this; 0 is taken as 1
+  SelfDrawMultiplier int `json:"selfDrawMultiplier"`
+  // each share paid by or to the dealer is multiplied by this; 0 is taken as 1
+  DealerMultiplier int `json:"dealerMultiplier"`
+  // riichi declarations, furiten and dora indicators
+  Riichi bool `json:"riichi"`
+  // tiles in a hand before drawing: 13 (four sets and a pair) or 16 (five sets and a pair)
+  HandSize int `json:"handSize"`
+  // players at the table: 3 or 4
+  Players int `json:"players"`
+  // suit (p, s or m) played with only its terminals, the 2 to 8 being removed; empty to keep every suit whole
+  ShortSuit string `json:"shortSuit"`
+  // norths are bonus tiles, revealed and replaced like flowers
+  NorthBonus bool `json:"northBonus"`
+  // jokers in the wall (up to 8), standing in for any tile of a set of three or more in a card hand
+  Jokers int `json:"jokers"`
+  // flowers are kept in the hand as playing tiles rather than revealed and replaced
+  FlowersHeld bool `json:"flowersHeld"`
+  // tiles are passed between the players before the first discard
+  Charleston bool `json:"charleston"`
+  // file of the winning hands allowed (see LoadCard); relative paths in a rule file start from the rule file
+  Card string `json:"card"`
+  // hands read from the card; when present, only these hands win
+  CardHands []CardHand `json:"-"`
+}
+
+const (
+  // rule set used when none is chosen
+  DefaultRuleSet = "classic"
+
+  ExhaustiveDrawDealerStays = "dealerStays"
+  ExhaustiveDrawRotate = "rotate"
+  // dealer stays only with a ready hand
+  ExhaustiveDrawDealerReady = "dealerReady"
+
+  PaymentDiscarderPays = "discarderPays"
+  PaymentAllPay = "allPay"
+
+  // tiles in a hand before drawing: four sets and a pair, or five sets and a pair
+  StandardHandSize = 13
+  LongHandSize = 16
+)
+
+// built-in rule sets by name
+var RulePresets map[string]RuleSet
+// special hands recognized by the win check
+var KnownSpecialHands map[string]bool
+// claims that may be placed on a discard
+var KnownClaims map[string]bool
+
+func init() {
+  // filled with the built-in hand patterns
+  KnownSpecialHands = make(map[string]bool)
+
+  KnownClaims = make(map[string]bool)
+  KnownClaims["win"] = true
+  KnownClaims["kong"] = true
+  KnownClaims["pong"] = true
+  KnownClaims["chow"] = true
+
+  RulePresets = make(map[string]RuleSet)
+  // rules as originally played: any win counts
+  RulePresets["classic"] = RuleSet{
+    Name: "classic",
+    Flowers: true,
+    ChowAllowed: true,
+    MinimumFaan: 0,
+    SpecialHands: []string{ "thirteenOrphans" },
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawDealerStays,
+    Scoring: "hongkong",
+    HandSize: StandardHandSize,
+    Players: PlayersInGame }
+  // hong kong rules with the customary three faan minimum
+  RulePresets["hongkong"] = RuleSet{
+    Name: "hongkong",
+    Flowers: true,
+    ChowAllowed: true,
+    MinimumFaan: 3,
+    SpecialHands: []string{ "thirteenOrphans" },
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawDealerStays,
+    Scoring: "hongkong",
+    HandSize: StandardHandSize,
+    Players: PlayersInGame }
+  // three players: the 2 to 8 of characters are removed, norths are bonus tiles and there is no chow
+  RulePresets["sanma"] = RuleSet{
+    Name: "sanma",
+    Flowers: false,
+    ChowAllowed: false,
+    MinimumFaan: 0,
+    SpecialHands: []string{},
+    ClaimPriority: []string{ "win", "kong", "pong" },
+    ExhaustiveDraw: ExhaustiveDrawDealerStays,
+    Scoring: "hongkong",
+    HandSize: StandardHandSize,
+    Players: 3,
+    ShortSuit: "m",
+    NorthBonus: true }
+  // no bonus tiles, no chow and no special hands, for learning the basics
+  RulePresets["simple"] = RuleSet{
+    Name: "simple",
+    Flowers: false,
+    ChowAllowed: false,
+    MinimumFaan: 0,
+    SpecialHands: []string{},
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawRotate,
+    Scoring: "hongkong",
+    HandSize: StandardHandSize,
+    Players: PlayersInGame }
+}
+
+// copy of a preset; slices are not shared with the preset
+func PresetRules(name string) (RuleSet, bool) {
+  preset, found := RulePresets[name]
+  if !found {
+    return RuleSet{}, false
+  }
+  preset.SpecialHands = append([]string{}, preset.SpecialHands...)
+  preset.HandPatterns = append([]string{}, preset.HandPatterns...)
+  preset.ClaimPriority = append([]string{}, preset.ClaimPriority...)
+  return preset, true
+}
+
+// load a rule set by preset name or from a .json or .toml file; file values override the default preset
+func LoadRuleSet(source string) (RuleSet, error) {
+  if preset, found := PresetRules(source); found {
+    return preset.withCard("")
+  }
+
+  data, err := ioutil.ReadFile(source)
+  if err != nil {
+    return RuleSet{}, fmt.Errorf("%q is neither a preset nor a readable rule file: %v", source, err)
+  }
+
+  switch strings.ToLower(filepath.Ext(source)) {
+    case ".toml":
+      values, err := parseToml(data)
+      if err != nil {
+        return RuleSet{}, fmt.Errorf("%s: %v", source, err)
+      }
+      // reuse the json field names and checks
+      if data, err = json.Marshal(values); err != nil {
+        return RuleSet{}, err
+      }
+    case ".json":
+    default:
+      return RuleSet{}, fmt.Errorf("%s: rule files end in .json or .toml", source)
+  }
+  r, err := ParseRuleSet(data, strings.TrimSuffix(filepath.Base(source), filepath.Ext(source)))
+  if err != nil {
+    return RuleSet{}, err
+  }
+  return r.withCard(filepath.Dir(source))
+}
+
+// read the hands of the rule set's card, if any; a relative card path starts from the given directory
+func (r RuleSet) withCard(dir string) (RuleSet, error) {
+  if r.Card == "" {
+    return r, nil
+  }
+  path := r.Card
+  if dir != "" && !filepath.IsAbs(path) {
+    path = filepath.Join(dir, path)
+  }
+  hands, err := LoadCard(path)
+  if err != nil {
+    return RuleSet{}, fmt.Errorf("rule set %s: %v", r.Name, err)
+  }
+  r.CardHands = hands
+  return r, nil
+}
+
+// read json rules over the default preset
+func ParseRuleSet(data []byte, name string) (RuleSet, error) {
+  r, _ := PresetRules(DefaultRuleSet)
+  r.Name = name
+
+  decoder := json.NewDecoder(bytes.NewReader(data))
+  decoder.DisallowUnknownFields()
+  if err := decoder.Decode(&r); err != nil {
+    return RuleSet{}, fmt.Errorf("rule set %s: %v", name, err)
+  }
+  if err := r.Validate(); err != nil {
+    return RuleSet{}, fmt.Errorf("rule set %s: %v", name, err)
+  }
+  return r, nil
+}
+
+// check that every setting is understood
+func (r RuleSet) Validate() error {
+  if r.MinimumFaan < 0 {
+    return fmt.Errorf("minimumFaan of %d is negative", r.MinimumFaan)
+  }
+  for _, special := range r.SpecialHands {
+    if !KnownSpecialHands[special] {
+      return fmt.Errorf("unknown special hand %q", special)
+    }
+    // every north is set aside as a bonus tile, so no hand can hold one
+    if r.NorthBonus && (special == "thirteenOrphans" || special == "greaterHonorsKnitted") {
+      return fmt.Errorf("special hand %q needs a north, which northBonus sets aside", special)
+    }
+  }
+  if _, err := r.EnabledPatterns(); err != nil {
+    return err
+  }
+
+  seen := make(map[string]bool)
+  for _, claim := range r.ClaimPriority {
+    if !KnownClaims[claim] {
+      return fmt.Errorf("unknown claim %q in claimPriority", claim)
+    }
+    if seen[claim] {
+      return fmt.Errorf("claim %q is listed twice in claimPriority", claim)
+    }
+    seen[claim] = true
+  }
+  if !seen["win"] {
+    return fmt.Errorf("claimPriority must include win")
+  }
+
+  if r.ExhaustiveDraw != ExhaustiveDrawDealerStays && r.ExhaustiveDraw != ExhaustiveDrawRotate && r.ExhaustiveDraw != ExhaustiveDrawDealerReady {
+    return fmt.Errorf("exhaustiveDraw is %q, not %s, %s or %s", r.ExhaustiveDraw, ExhaustiveDrawDealerStays, ExhaustiveDrawRotate, ExhaustiveDrawDealerReady)
+  }
+  if r.HandSize != StandardHandSize && r.HandSize != LongHandSize {
+    return fmt.Errorf("handSize is %d, not 13 or 16", r.HandSize)
+  }
+  if r.Players != 3 && r.Players != PlayersInGame {
+    return fmt.Errorf("players is %d, not 3 or 4", r.Players)
+  }
+  if r.ShortSuit != "" && r.shortSuitIndex() == 0 {
+    return fmt.Errorf("shortSuit is %q, not p, s or m", r.ShortSuit)
+  }
+  if r.Jokers < 0 || r.Jokers > MaxJokers {
+    return fmt.Errorf("jokers is %d, not between 0 and %d", r.Jokers, MaxJokers)
+  }
+  if r.Jokers > 0 && r.Card == "" {
+    return fmt.Errorf("jokers are only played with a card of hands")
+  }
+  if r.FlowersHeld && !r.Flowers {
+    return fmt.Errorf("flowersHeld needs flowers in the wall")
+  }
+  if r.Charleston && r.Players != PlayersInGame {
+    return fmt.Errorf("the charleston needs %d players", PlayersInGame)
+  }
+  if r.DeadWall < 0 || r.DeadWall > r.TileCount()/2 {
+    return fmt.Errorf("deadWall of %d tiles does not fit the wall", r.DeadWall)
+  }
+  if r.Payment != "" && r.Payment != PaymentDiscarderPays && r.Payment != PaymentAllPay {
+    return fmt.Errorf("payment is %q, not %s or %s", r.Payment, PaymentDiscarderPays, PaymentAllPay)
+  }
+  if r.SelfDrawMultiplier < 0 || r.DealerMultiplier < 0 {
+    return fmt.Errorf("selfDrawMultiplier of %d and dealerMultiplier of %d cannot be negative", r.SelfDrawMultiplier, r.DealerMultiplier)
+  }
+  if r.ReservedTiles < 0 || r.DeadWall+r.ReservedTiles > r.TileCount()/2 {
+    return fmt.Errorf("reservedTiles of %d tiles, with a deadWall of %d, does not fit the wall", r.ReservedTiles, r.DeadWall)
+  }
+  if _, found := Scorers[r.Scoring]; !found {
+    return fmt.Errorf("unknown scoring %q", r.Scoring)
+  }
+  return nil
+}
+
+// determine if a special hand is accepted
+func (r RuleSet) SpecialHandEnabled(special string) bool {
+  for _, enabled := range r.SpecialHands {
+    if enabled == special {
+      return true
+    }
+  }
+  return false
+}
+
+// tiles in the wall
+func (r RuleSet) TileCount() int {
+  count := TilesInGame
+  if !r.Flowers {
+    count -= 8
+  }
+  if r.shortSuitIndex() != 0 {
+    // 2 to 8, four of each
+    count -= 7*4
+  }
+  return count + r.Jokers
+}
+
+// every tile in the wall, in order: the suits and honors, then flowers and jokers
+func (r RuleSet) Tiles() TileCollection {
+  tiles := make(TileCollection, 0, r.TileCount())
+  // TODO: clean magic numbers
+  for i := 1; i <= 4; i++ {
+    for j:= 1; j < 10; j++ {
+      for k:= 0; k < 4 && !r.Removed(i, j); k++ {
+        t := Tile { 
+          Suit: i, 
+          Value: j, 
+          Id: (i-1)*36+(j-1)*4+k+1, 
+          Ud: UnicodeDisplay[i-1][j],
+          Red: r.RedFives && i <= 3 && j == 5 && k == 0 }
+        tiles = append(tiles, t)
+      }
+      if i == 4 && j == 7 { // bail out for honor suit
+        break
+      }
+    }
+  }
+
+  // the bonus suit
+  for j:= 1; j <= 8 && r.Flowers; j++ {
+    tiles = append(tiles, NewTile(5, j, 0))
+  }
+  
+  for k := 0; k < r.Jokers; k++ {
+    tiles = append(tiles, NewTile(6, 1, k))
+  }
+  return tiles
+}
+
+// suit (1 to 3) played with only its terminals; zero if every suit is whole
+func (r RuleSet) shortSuitIndex() int {
+  for i := 0; i < 3 && r.ShortSuit != ""; i++ {
+    if SuitNotation[i] == r.ShortSuit {
+      return i+1
+    }
+  }
+  return 0
+}
+
+// determine if a tile is left out of the wall
+func (r RuleSet) Removed(suit int, value int) bool {
+  return suit == r.shortSuitIndex() && value >= 2 && value <= 8
+}
+
+// bonus tiles in the wall: flowers and seasons, and norths when they are bonus tiles
+func (r RuleSet) BonusTileCount() int {
+  count := 0
+  if r.Flowers && !r.FlowersHeld {
+    count += 8
+  }
+  if r.NorthBonus {
+    count += 4
+  }
+  return count
+}
+
+// sets in a complete hand, besides the pair
+func (r RuleSet) SetsInHand() int {
+  return r.HandSize/3
+}
+
+// determine if a tile is a bonus tile, revealed and replaced when drawn
+func (h PlayerHand) IsBonus(t Tile) bool {
+  return (t.IsSpecial() && !h.ruleSet().FlowersHeld) || (h.ruleSet().NorthBonus && t.Suit == 4 && t.Value == 4)
+}
+
+// rules a hand plays under; hands outside a game follow the default preset
+func (h PlayerHand) ruleSet() RuleSet {
+  if h.Rules == nil {
+    return RulePresets[DefaultRuleSet]
+  }
+  return *h.Rules
+}
+
+// parse the flat subset of toml used by rule files: key = value lines with strings, integers, booleans and arrays of strings,
+// which may run over several lines; tables, dotted keys, multi-line strings and other value types are refused
+func parseToml(data []byte) (map[string]interface{}, error) {
+  values := make(map[string]interface{})
+  lines := strings.Split(string(data), "\n")
+  for i := 0; i < len(lines); i++ {
+    number := i+1
+    line := strings.TrimSpace(stripTomlComment(lines[i]))
+    if line == "" {
+      continue
+    }
+    if strings.HasPrefix(line, "[") {
+      return nil, fmt.Errorf("line %d: tables such as %s are not supported; rule files are flat key = value lines", number, line)
+    }
+    separator := strings.Index(line, "=")
+    if separator < 1 {
+      return nil, fmt.Errorf("line %d: expected key = value", number)
+    }
+    key := strings.TrimSpace(line[:separator])
+    if strings.Trim(key, "\"'") != key || strings.ContainsAny(key, ". \t") {
+      return nil, fmt.Errorf("line %d: only bare keys are supported, not %s", number, key)
+    }
+    raw := strings.TrimSpace(line[separator+1:])
+
+    // an array continues until its closing bracket
+    for strings.HasPrefix(raw, "[") && !tomlArrayClosed(raw) {
+      i++
+      if i == len(lines) {
+        return nil, fmt.Errorf("line %d: array is not closed", number)
+      }
+      raw += " "+strings.TrimSpace(stripTomlComment(lines[i]))
+    }
+
+    value, err := parseTomlValue(raw)
+    if err != nil {
+      return nil, fmt.Errorf("line %d: %v", number, err)
+    }
+    if _, found := values[key]; found {
+      return nil, fmt.Errorf("line %d: %s is set twice", number, key)
+    }
+    values[key] = value
+  }
+  return values, nil
+}
+
+// index of the end of each string in a line: the closing quote of the string opened at each position
+// basic strings ("...") take backslash escapes, literal strings ('...') do not; -1 for a string left open
+func tomlStringEnd(line string, open int) int {
+  quote := line[open]
+  for i := open+1; i < len(line); i++ {
+    switch {
+      case quote == '"' && line[i] == '\\':
+        i++
+      case line[i] == quote:
+        return i
+    }
+  }
+  return -1
+}
+
+// the line without a comment: from # outside of a string
+func stripTomlComment(line string) string {
+  for i := 0; i < len(line); i++ {
+    switch line[i] {
+      case '"', '\'':
+        end := tomlStringEnd(line, i)
+        if end < 0 {
+          return line
+        }
+        i = end
+      case '#':
+        return line[:i]
+    }
+  }
+  return line
+}
+
+// split a line at a separator outside of strings
+func splitOutsideStrings(line string, separator byte) []string {
+  parts := make([]string, 0, 4)
+  from := 0
+  for i := 0; i < len(line); i++ {
+    switch line[i] {
+      case '"', '\'':
+        if end := tomlStringEnd(line, i); end >= 0 {
+          i = end
+        }
+      case separator:
+        parts = append(parts, line[from:i])
+        from = i+1
+    }
+  }
+  return append(parts, line[from:])
+}
+
+// determine if an array's closing bracket, outside of strings, has been reached
+func tomlArrayClosed(raw string) bool {
+  return len(splitOutsideStrings(raw, ']')) > 1
+}
+
+// parse a single toml string, basic or literal
+func parseTomlString(raw string) (string, error) {
+  if strings.HasPrefix(raw, "\"\"\"") || strings.HasPrefix(raw, "'''") {
+    return "", fmt.Errorf("multi-line strings are not supported")
+  }
+  if end := tomlStringEnd(raw, 0); end != len(raw)-1 {
+    return "", fmt.Errorf("%s is not a single string", raw)
+  }
+  if raw[0] == '\'' {
+    return raw[1:len(raw)-1], nil
+  }
+  return strconv.Unquote(raw)
+}
+
+// parse a single toml value, its comment removed
+func parseTomlValue(raw string) (interface{}, error) {
+  switch {
+    case raw == "true" || raw == "false":
+      return raw == "true", nil
+    case strings.HasPrefix(raw, "\"") || strings.HasPrefix(raw, "'"):
+      return parseTomlString(raw)
+    case strings.HasPrefix(raw, "{"):
+      return nil, fmt.Errorf("inline tables are not supported")
+    case strings.HasPrefix(raw, "["):
+      if !strings.HasSuffix(raw, "]") {
+        return nil, fmt.Errorf("unexpected text after the array %s", raw)
+      }
+      items := make([]string, 0, 4)
+      for _, item := range splitOutsideStrings(raw[1:len(raw)-1], ',') {
+        item = strings.TrimSpace(item)
+        if item == "" {
+          continue
+        }
+        if !strings.HasPrefix(item, "\"") && !strings.HasPrefix(item, "'") {
+          return nil, fmt.Errorf("array item %s is not a string; arrays of strings only are supported", item)
+        }
+        unquoted, err := parseTomlString(item)
+        if err != nil {
+          return nil, fmt.Errorf("array item %s: %v", item, err)
+        }
+        items = append(items, unquoted)
+      }
+      return items, nil
+  }
+  number, err := strconv.Atoi(strings.Replace(raw, "_", "", -1))
+  if err != nil {
+    return nil, fmt.Errorf("unsupported value %s; strings, integers, booleans and arrays of strings are supported", raw)
+  }
+  return number, nil
+}
@@ -35,10 +35,15 @@ const (
 
 // tiles that form a set
 type TileSet struct {
-// TODO: populate UnderlyingTiles for audit checks
+  // seq, triple, kong (claimed from a discard), concealedKong (four drawn tiles) or addedKong (a drawn tile added to a revealed triple)
   Kind string
   Tiles string
+  // the tiles themselves, once revealed
   UnderlyingTiles []Tile
+  // discard claimed to make the set, the seat that discarded it and its position in the discard pile; an empty tile for a set of drawn tiles
+  ClaimedTile Tile
+  ClaimedFrom int
+  ClaimedTurn int
 }
 
 // hand of a player
@@ -50,6 +55,62 @@ type PlayerHand struct {
   Player int
   LastNewTile Tile
   ComputerPlayer bool
+  // discard strategy of a computer player, by name; empty for the default
+  Strategy string
+  // rules of the game; nil for the default preset
+  Rules *RuleSet
+  // riichi declared; the hand is locked and each drawn tile is discarded unless it wins
+  Riichi bool
+  // passed on a win since the last discard (temporary furiten; for the rest of the game after riichi)
+  MissedWin bool
+}
+
+// determine if the set is a kong of any kind
+func (s TileSet) IsKong() bool {
+  return s.Kind == "kong" || s.Kind == "concealedKong" || s.Kind == "addedKong"
+}
+
+// tiles of the set as shown at the table by the given seat; a concealed kong has its outer tiles face-down
+// a claimed tile is bracketed, as if turned, on the side of the player it came from: left for the previous player, in the middle from across and right for the next
+func (s TileSet) Display(seat int, players int) string {
+  glyphs := make([]string, 0, 4)
+  for _, runeValue := range s.Tiles {
+    glyphs = append(glyphs, string(runeValue))
+  }
+  if s.Kind == "concealedKong" && len(glyphs) == 4 {
+    return TileBack+glyphs[1]+glyphs[2]+TileBack
+  }
+  if s.ClaimedTile == EmptyTile {
+    return s.Tiles
+  }
+
+  others := make([]string, 0, 3)
+  removed := false
+  for _, glyph := range glyphs {
+    if !removed && glyph == s.ClaimedTile.Ud {
+      removed = true
+      continue
+    }
+    others = append(others, glyph)
+  }
+  position := 1
+  switch (s.ClaimedFrom-seat+players) % players {
+    case players-1:
+      position = 0
+    case 1:
+      position = len(others)
+  }
+  return strings.Join(others[:position], "")+"["+s.ClaimedTile.Ud+"]"+strings.Join(others[position:], "")
+}
+
+// determine if the hand is concealed: no revealed sets other than concealed kongs
+func (h PlayerHand) Concealed() bool {
+  for i := 0; i < h.RevealedSets; i++ {
+    if i >= len(h.RevealedTileSets) || h.RevealedTileSets[i].Kind != "concealedKong" {
+      return false
+    }
+  }
+  return true
 }
 
 // max value for each suit
@@ -72,7 +133,7 @@ func (h PlayerHand) OutputHand(showHidden bool, tileOnly bool) {
   specialTileLine := ""
   
   specialTileLine += fmt.Sprintf("P%d-R: ", h.Player)
-  for i := 0; i < 8; i++ {
+  for i := 0; i < len(h.Revealed); i++ {
     if h.Revealed[i] != EmptyTile {
       if tileOnly {
         specialTileLine += fmt.Sprintf("%v", h.Revealed[i].UdString())
@@ -89,7 +150,7 @@ func (h PlayerHand) OutputHand(showHidden bool, tileOnly bool) {
       if i > 0 {
         fmt.Printf(", ")
       }
-      fmt.Printf("%v", h.RevealedTileSets[i].Tiles)
+      fmt.Printf("%v", h.RevealedTileSets[i].Display(h.Player, h.ruleSet().Players))
     }
     fmt.Println()
   }
@@ -97,7 +158,7 @@ func (h PlayerHand) OutputHand(showHidden bool, tileOnly bool) {
   // private
   if showHidden {
     fmt.Printf("P%d-H: ", h.Player)
-    for i := 0; i < 14; i++ {
+    for i := 0; i < len(h.Hidden); i++ {
       if h.Hidden[i] != EmptyTile {
         if tileOnly {
           fmt.Printf("%v", h.Hidden[i].UdString())
@@ -115,11 +176,11 @@ func (h PlayerHand) OutputHand(showHidden bool, tileOnly bool) {
 // sort hand for readability/keep clear the last tile for new tiles
 func (h PlayerHand) Sort() {
   // TODO: sort more efficiently or just sort on tile serial?
-  for i := 0; i < 14; i++ {
+  for i := 0; i < len(h.Hidden); i++ {
     baseTile := EmptyTile
     baseItem := i
     
-    for j := i; j < 14; j++ {
+    for j := i; j < len(h.Hidden); j++ {
       if baseTile == EmptyTile && h.Hidden[j] != EmptyTile {
         baseTile = h.Hidden[j]
         baseItem = j
@@ -140,15 +201,17 @@ func (h PlayerHand) Sort() {
 
 // add tile to hand
 func (h PlayerHand) Receive(t Tile) error {
+  last := len(h.Hidden)-1
+  
   // sort if needed
-  if h.Hidden[13] != EmptyTile {
+  if h.Hidden[last] != EmptyTile {
     h.Sort()
   }
   
-  if h.Hidden[13] == EmptyTile {
-    h.Hidden[13] = t
+  if h.Hidden[last] == EmptyTile {
+    h.Hidden[last] = t
   } else {
-    return fmt.Errorf("tile %v could not be placed in hand as the last position was occupied by %v", t, h.Hidden[13])
+    return fmt.Errorf("tile %v could not be placed in hand as the last position was occupied by %v", t, h.Hidden[last])
   }
   h.Sort()
   return nil
@@ -156,8 +219,8 @@ func (h PlayerHand) Receive(t Tile) error {
 
 // determine if hand has at least one special tile
 func (h PlayerHand) HasUnreplacedSpecialTile() bool {
-  for i := 0; i < 14; i++ {
-    if h.Hidden[i].IsSpecial() {
+  for i := 0; i < len(h.Hidden); i++ {
+    if h.IsBonus(h.Hidden[i]) {
       return true
     }
   }
@@ -166,8 +229,8 @@ func (h PlayerHand) HasUnreplacedSpecialTile() bool {
 
 // return the first special tile
 func (h PlayerHand) GetFirstSpecialTile() (Tile, error) {
-  for i := 0; i < 14; i++ {
-    if h.Hidden[i].IsSpecial() {
+  for i := 0; i < len(h.Hidden); i++ {
+    if h.IsBonus(h.Hidden[i]) {
       foundTile := h.Hidden[i]
       h.Hidden[i] = EmptyTile
       return foundTile, nil
@@ -178,7 +241,7 @@ func (h PlayerHand) GetFirstSpecialTile() (Tile, error) {
 
 // reveal special tile
 func (h PlayerHand) RevealSpecialTile (t Tile) error {
-  for k:= 0; k < 8; k++ {
+  for k:= 0; k < len(h.Revealed); k++ {
     if h.Revealed[k] == EmptyTile {
       h.Revealed[k] = t
       
@@ -202,15 +265,15 @@ func (h PlayerHand) CountHiddenTiles(consider Tile) ([][]int, []int, []int) {
   // sum of values, applicable only to the first three suits
   tileValuesSum := make([]int, 4, 4)
   
-  // begin counting tiles
-  if consider != EmptyTile {
+  // begin counting tiles; bonus tiles held in the hand and jokers are not counted
+  if consider != EmptyTile && consider.Suit <= 4 {
     tileCounts[consider.Suit-1][consider.Value]++
     tileCountsSum[consider.Suit-1]++
     tileValuesSum[consider.Suit-1] += consider.Value
   }
   
   for i:= 0; i < len(h.Hidden); i++ {
-    if h.Hidden[i] != EmptyTile {
+    if h.Hidden[i] != EmptyTile && h.Hidden[i].Suit <= 4 {
       tmpTile := h.Hidden[i]
       tileCounts[tmpTile.Suit-1][tmpTile.Value]++
       tileCountsSum[tmpTile.Suit-1]++
@@ -242,7 +305,8 @@ func (d DiscardPile) CountDiscardTiles(ignoreLast bool) ([][]int, []int, []int)
   }
 
   for i:= 0; i < tileCount; i++ {
-    if d[i].Item != EmptyTile {
+    // a claimed discard is counted in the set made with it
+    if d[i].Item != EmptyTile && d[i].Item.Suit <= 4 && !d[i].Claimed {
       tmpTile := d[i].Item
       tileCounts[tmpTile.Suit-1][tmpTile.Value]++
       tileCountsSum[tmpTile.Suit-1]++
@@ -297,7 +361,7 @@ func checkSuitTiles(tmpTileCountsSum int, tmpTileCountsSource []int, suit int) (
   
   tmpSetCount := 0
   tmpSuitSuccess := true
-  tmpTileSet := make([]TileSet, 4, 4)
+  tmpTileSet := make([]TileSet, tmpTileCountsSum/3, tmpTileCountsSum/3)
   
   copy(tmpTileCounts, tmpTileCountsSource)
   
@@ -356,45 +420,14 @@ func (h PlayerHand) HaveWin(consider Tile, tileSource string) bool {
     fmt.Printf("[vd] HaveWin invocation for Player %d with tile %v from %s\n", h.Player, consider, tileSource)
   }
   
-  // check for special case
-  //if tileSource == "draw" { // tile can be from any source
-  // check for special win case
-  // suit 4, one of each (1 to 7)
-  // suit 1, one and 9
-  // suit 2, one and 9
-  // suit 3, one and 9
-  // one of any previous
-  
-  specialWin := make([]int, 14, 14)
-
-  if consider != EmptyTile {
-    specialWin[SpecialWinTiles[consider.Ud]]++
-  }
-  
-  // suffices to check hidden only as none can be revealed as standalone sets
-  for i:= 0; i < len(h.Hidden); i++ {
-    if h.Hidden[i] != EmptyTile {
-      specialWin[SpecialWinTiles[h.Hidden[i].Ud]]++
-    }
-  }
-  
-  haveAtLeastOneOfEach := true
-  haveTwoOfOne := false
-  
-  for i:= 1; i <= 13; i++ {
-    if specialWin[i] == 0 {
-      haveAtLeastOneOfEach = false
-    }
-    if specialWin[i] == 2 {
-      haveTwoOfOne = true
-    }
+  // a card of hands replaces the standard winning hands
+  if len(h.ruleSet().CardHands) > 0 {
+    return h.haveCardWin(consider, tileSource)
   }
   
-  if haveAtLeastOneOfEach && haveTwoOfOne {
+  if len(h.SpecialWins(consider)) > 0 {
     return true
   }
-
-  //}
   
   // check for ordinary win
   setCount := h.RevealedSets
@@ -606,7 +639,7 @@ func (h PlayerHand) HaveWin(consider Tile, tileSource string) bool {
       if newTileSets[i].Kind == "triple" && strings.Contains(newTileSets[i].Tiles, consider.Ud) {
         suitableUse = true
         break
-      } else if tileSource == "previous" && newTileSets[i].Kind == "seq" && strings.Contains(newTileSets[i].Tiles, consider.Ud) {
+      } else if (tileSource == "previous" || h.ruleSet().WinOnAnySequence) && newTileSets[i].Kind == "seq" && strings.Contains(newTileSets[i].Tiles, consider.Ud) {
         suitableUse = true
       }
     }
@@ -623,7 +656,7 @@ func (h PlayerHand) HaveWin(consider Tile, tileSource string) bool {
     suitableUse = true
   }
   
-  if setCount == 4 && suitableUse == true {
+  if setCount == h.ruleSet().SetsInHand() && suitableUse == true {
     if VerboseDebug {
       fmt.Printf("[vd] Possible WIN: %v: %v\n", eye, newTileSets)
     }
@@ -638,13 +671,23 @@ func (h PlayerHand) HaveKong(consider Tile, tileSource string) (bool, []TileSet)
   kongFound := false
   kongSets := make([]TileSet, 0, 0)
   
+  // a hand locked by riichi is not changed; under a card, kongs are only formed from discards
+  if h.Riichi || (len(h.ruleSet().CardHands) > 0 && tileSource == "draw") {
+    return kongFound, kongSets
+  }
+  
   tileCounts, _, _ := h.CountHiddenTiles(consider)
   
   for i:= 0; i < 4; i++ {
     for j := 1; j < 10; j++ {
       if tileCounts[i][j] == 4 && (consider == EmptyTile || (consider.Suit-1 == i && consider.Value == j)) {
+        // four drawn tiles make a concealed kong; with a discard, the kong is claimed
+        kind := "kong"
+        if tileSource == "draw" {
+          kind = "concealedKong"
+        }
         kongFound = true
-        kongSets = append(kongSets, TileSet{ Kind: "kong", Tiles: UnicodeDisplay[i][j]+UnicodeDisplay[i][j]+UnicodeDisplay[i][j]+UnicodeDisplay[i][j] })
+        kongSets = append(kongSets, TileSet{ Kind: kind, Tiles: UnicodeDisplay[i][j]+UnicodeDisplay[i][j]+UnicodeDisplay[i][j]+UnicodeDisplay[i][j] })
       }
       if i == 3 && j == 7 {
         break
@@ -658,7 +701,7 @@ func (h PlayerHand) HaveKong(consider Tile, tileSource string) (bool, []TileSet)
         for k := 1; k < 10; k++ {
           if tileCounts[j][k] == 1 && strings.Contains(h.RevealedTileSets[i].Tiles, UnicodeDisplay[j][k]) {
             kongFound = true
-            kongSets = append(kongSets, TileSet{ Kind: "kong", Tiles: UnicodeDisplay[j][k]+UnicodeDisplay[j][k]+UnicodeDisplay[j][k]+UnicodeDisplay[j][k] })
+            kongSets = append(kongSets, TileSet{ Kind: "addedKong", Tiles: UnicodeDisplay[j][k]+UnicodeDisplay[j][k]+UnicodeDisplay[j][k]+UnicodeDisplay[j][k] })
           }
           if j == 3 && k == 7 {
             break
@@ -672,9 +715,28 @@ func (h PlayerHand) HaveKong(consider Tile, tileSource string) (bool, []TileSet)
   return kongFound, kongSets
 }
 
+// give up the tile added to a revealed triple to a player robbing the kong; the set is a triple again,
+// of its original three tiles and with the discard claimed for it
+func (h PlayerHand) RobKong(t Tile) {
+  for i := 0; i < h.RevealedSets; i++ {
+    if h.RevealedTileSets[i].Kind == "addedKong" && strings.Contains(h.RevealedTileSets[i].Tiles, t.Ud) {
+      triple := make([]Tile, 0, 3)
+      for _, x := range h.RevealedTileSets[i].UnderlyingTiles {
+        if x != t {
+          triple = append(triple, x)
+        }
+      }
+      h.RevealedTileSets[i].Kind = "triple"
+      h.RevealedTileSets[i].Tiles = t.Ud+t.Ud+t.Ud
+      h.RevealedTileSets[i].UnderlyingTiles = triple
+      return
+    }
+  }
+}
+
 // check to see if the player has a set of three with an extra tile
 func (h PlayerHand) HavePong(consider Tile, tileSource string) (bool, string) {
-  if tileSource != "previous" && tileSource != "other" {
+  if (tileSource != "previous" && tileSource != "other") || h.Riichi {
     return false, ""
   }
   
@@ -699,7 +761,7 @@ func (h PlayerHand) HaveSeq(consider Tile, tileSource string) (bool, []TileSet)
   seqFound := false
   seqSets := make([]TileSet, 0, 0)
   
-  if tileSource != "previous" {
+  if tileSource != "previous" || !h.ruleSet().ChowAllowed || h.Riichi {
     return seqFound, seqSets
   }
   
@@ -726,12 +788,18 @@ func (h PlayerHand) TakeWin(discard []DiscardedTile, considerLastDiscard bool, h
 // computer player: take kong?
 // naively, yes
 func (h PlayerHand) TakeKong(discard []DiscardedTile, considerLastDiscard bool, hands []PlayerHand) string {
+  if len(h.ruleSet().CardHands) > 0 {
+    return h.cardClaim(discard)
+  }
   return "y"
 }
 
 // computer player: take pong?
 // naively, yes
 func (h PlayerHand) TakePong(discard []DiscardedTile, considerLastDiscard bool, hands []PlayerHand) string {
+  if len(h.ruleSet().CardHands) > 0 {
+    return h.cardClaim(discard)
+  }
   return "y"
 }
 
@@ -741,18 +809,28 @@ func (h PlayerHand) TakeSeq(discard []DiscardedTile, considerLastDiscard bool, h
   return "0"
 }      
 
+// computer player: declare riichi?
+// naively, yes
+func (h PlayerHand) TakeRiichi(discard []DiscardedTile, hands []PlayerHand) string {
+  return "y"
+}
+
 // computer player: what to discard?
 // naively, the first tile
 func (h PlayerHand) Discard(discard DiscardPile, considerLastDiscard bool, hands []PlayerHand) string {
+  if len(h.ruleSet().CardHands) > 0 {
+    return strconv.Itoa(h.cardDiscard())
+  }
+  
   // count internal hand
   tileCounts, tileCountsSum, tileValuesSum := h.CountHiddenTiles(EmptyTile)
   // count discarded
   dtileCounts, dtileCountsSum, dtileValuesSum := discard.CountDiscardTiles(false)
   // count unusable tiles (aside from discard)
-  u0tileCounts, u0tileCountsSum, u0tileValuesSum := hands[0].CountPublicSetTiles()
-  u1tileCounts, u1tileCountsSum, u1tileValuesSum := hands[1].CountPublicSetTiles()
-  u2tileCounts, u2tileCountsSum, u2tileValuesSum := hands[2].CountPublicSetTiles()
-  u3tileCounts, u3tileCountsSum, u3tileValuesSum := hands[3].CountPublicSetTiles()
+  publicTileCounts := make([][][]int, len(hands), len(hands))
+  for p := range hands {
+    publicTileCounts[p], _, _ = hands[p].CountPublicSetTiles()
+  }
 
   // merge unavailable tiles
   unavailableTileCounts := make([][]int, 4, 4)
@@ -763,20 +841,18 @@ func (h PlayerHand) Discard(discard DiscardPile, considerLastDiscard bool, hands
   for i := 0; i < 4; i++ {
     for j := 0; j < 10; j++ {
       unavailableTileCounts[i][j] += dtileCounts[i][j]
-      unavailableTileCounts[i][j] += u0tileCounts[i][j]
-      unavailableTileCounts[i][j] += u1tileCounts[i][j]
-      unavailableTileCounts[i][j] += u2tileCounts[i][j]
-      unavailableTileCounts[i][j] += u3tileCounts[i][j]
+      for p := range hands {
+        unavailableTileCounts[i][j] += publicTileCounts[p][i][j]
+      }
     }
   }
 
   if (VerboseDebug) {
     fmt.Println("[vd] internal hand", tileCounts, tileCountsSum, tileValuesSum)
     fmt.Println("[vd] discard", dtileCounts, dtileCountsSum, dtileValuesSum)
-    fmt.Println("[vd] p0 public sets", u0tileCounts, u0tileCountsSum, u0tileValuesSum)
-    fmt.Println("[vd] p1 public sets", u1tileCounts, u1tileCountsSum, u1tileValuesSum)
-    fmt.Println("[vd] p2 public sets", u2tileCounts, u2tileCountsSum, u2tileValuesSum)
-    fmt.Println("[vd] p3 public sets", u3tileCounts, u3tileCountsSum, u3tileValuesSum)
+    for p := range hands {
+      fmt.Printf("[vd] p%d public sets %v\n", p, publicTileCounts[p])
+    }
     fmt.Println("[vd] unavailable tiles", unavailableTileCounts)    
   }
   
@@ -921,17 +997,19 @@ func (h PlayerHand) Discard(discard DiscardPile, considerLastDiscard bool, hands
 
 // process the initial deal; as presentation is important, the dealing processing is followed strictly
 func (g *Game) InitialDeal() {
+  rounds := g.Rules.HandSize/4
+  
   // rounds of dealing
-  for k:= 0; k < 3; k++ {
+  for k:= 0; k < rounds; k++ {
     // each player
-    for i:= 0; i < PlayersInGame; i++ {
+    for i:= 0; i < g.Rules.Players; i++ {
       // each of four tiles
       for j:= 0; j < 4; j++ {
         curTile, err := g.GetInitialTile(k, i, j)
         if err != nil {
           log.Fatal(err)
         }
-        err = g.Hands[(i+g.CurrentPlayer)%4].Receive(curTile)
+        err = g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].Receive(curTile)
         if err != nil {
           log.Fatal(err)
         }
@@ -940,7 +1018,7 @@ func (g *Game) InitialDeal() {
   }
   
   // special allocation for dealer
-  curTile, err := g.GetInitialTile(3, 0, 0)
+  curTile, err := g.GetInitialTile(rounds, 0, 0)
   if err != nil {
     log.Fatal(err)
   }
@@ -949,8 +1027,13 @@ func (g *Game) InitialDeal() {
   if err != nil {
     log.Fatal(err)
   }
+  
+  // a hand of a multiple of four tiles is complete after the rounds; only the dealer takes another
+  if g.Rules.HandSize % 4 == 0 {
+    return
+  }
 
-  curTile, err = g.GetInitialTile(3, 1, 0)
+  curTile, err = g.GetInitialTile(rounds, 0, g.Rules.Players)
   if err != nil {
     log.Fatal(err)
   }
@@ -961,13 +1044,13 @@ func (g *Game) InitialDeal() {
   }
   
   // final allocation for others
-  for i := 1; i < PlayersInGame; i++ {
-    curTile, err := g.GetInitialTile(3, 0, i)
+  for i := 1; i < g.Rules.Players; i++ {
+    curTile, err := g.GetInitialTile(rounds, 0, i)
     if err != nil {
       log.Fatal(err)
     }
 
-    err = g.Hands[(i+g.CurrentPlayer)%4].Receive(curTile)
+    err = g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].Receive(curTile)
     if err != nil {
       log.Fatal(err)
     }
@@ -976,14 +1059,14 @@ func (g *Game) InitialDeal() {
 
 // process special tiles occurring as part of the initial deal; this completes the initialization and is the begin object
 func (g *Game) InitialHandleSpecialTiles() {
-  for i := 0; i < PlayersInGame; i++ {
-    for g.Hands[(i+g.CurrentPlayer)%4].HasUnreplacedSpecialTile() {
-      specialTile, err := g.Hands[(i+g.CurrentPlayer)%4].GetFirstSpecialTile()
+  for i := 0; i < g.Rules.Players; i++ {
+    for g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].HasUnreplacedSpecialTile() {
+      specialTile, err := g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].GetFirstSpecialTile()
       if err != nil {
         log.Fatal(err)
       }
       
-      err = g.Hands[(i+g.CurrentPlayer)%4].RevealSpecialTile(specialTile)
+      err = g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].RevealSpecialTile(specialTile)
       if err != nil {
         log.Fatal(err)
       }
@@ -993,13 +1076,15 @@ func (g *Game) InitialHandleSpecialTiles() {
         log.Fatal(err)
       }
             
-      g.Hands[(i+g.CurrentPlayer)%4].Receive(curTile)
+      g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].Receive(curTile)
       if err != nil {
         log.Fatal(err)
       }
       
+      g.LogAction(g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].Player, "special", []Tile{ specialTile, curTile }, "initial", "")
+      
       if VerboseDebug {
-        fmt.Printf("[vd] Player %d had special tile %v, which was replaced with tile %v\n", g.Hands[(i+g.CurrentPlayer)%4].Player, specialTile, curTile)
+        fmt.Printf("[vd] Player %d had special tile %v, which was replaced with tile %v\n", g.Hands[(i+g.CurrentPlayer)%g.Rules.Players].Player, specialTile, curTile)
       }
 
     }
@@ -0,0 +1,87 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// named discard strategies for computer players, so that heuristics can be compared in play
+package mahjong
+
+import(
+  "fmt"
+  "sort"
+  "strconv"
+)
+
+// # Strategies
+// choose a discard: the position in the hidden tiles, as a string
+type DiscardStrategy func(h PlayerHand, discard DiscardPile, hands []PlayerHand) string
+
+// discard strategies by name
+var DiscardStrategies map[string]DiscardStrategy
+
+const (
+  // strategy of computer players not given one
+  DefaultStrategy = "sets"
+)
+
+func init() {
+  DiscardStrategies = map[string]DiscardStrategy {
+    "sets": func(h PlayerHand, discard DiscardPile, hands []PlayerHand) string {
+      return h.Discard(discard, false, hands)
+    },
+    "efficiency": PlayerHand.EfficiencyDiscard,
+  }
+}
+
+// names of the discard strategies in order
+func StrategyNames() []string {
+  names := make([]string, 0, len(DiscardStrategies))
+  for name := range DiscardStrategies {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// check that a strategy is known
+func ValidateStrategy(name string) error {
+  if _, found := DiscardStrategies[name]; !found {
+    return fmt.Errorf("unknown strategy %q; expected one of %v", name, StrategyNames())
+  }
+  return nil
+}
+
+// computer player: what to discard, by the hand's strategy
+func (h PlayerHand) StrategyDiscard(discard DiscardPile, hands []PlayerHand) string {
+  strategy, found := DiscardStrategies[h.Strategy]
+  if !found {
+    strategy = DiscardStrategies[DefaultStrategy]
+  }
+  return strategy(h, discard, hands)
+}
+
+// computer player: what to discard?
+// naively, the tile leaving the hand closest to ready, then accepting the most unseen tiles
+func (h PlayerHand) EfficiencyDiscard(discard DiscardPile, hands []PlayerHand) string {
+  if len(h.ruleSet().CardHands) > 0 {
+    return strconv.Itoa(h.cardDiscard())
+  }
+  options := h.DiscardOptions(h.UnseenTileCounts(discard, hands))
+  if len(options) == 0 {
+    return h.Discard(discard, false, hands)
+  }
+  return strconv.Itoa(h.tilePosition(options[0].Item))
+}
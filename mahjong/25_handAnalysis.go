@@ -0,0 +1,687 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// hand analysis: tile parsing, shanten, waits, decompositions and discard efficiency
+package mahjong
+
+import(
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+// # tile parsing
+// hands out distinct tiles so that parsed tiles keep unique ids
+type TilePool struct {
+  used map[int]bool
+}
+
+func NewTilePool() *TilePool {
+  return &TilePool{ used: make(map[int]bool) }
+}
+
+// take an unused copy of a tile; the first copy of each five is left for last as it is the red five
+func (p *TilePool) Take(suit int, value int) (Tile, error) {
+  if suit < 1 || suit > 6 || value < 1 || value >= len(UnicodeDisplay[suit-1]) {
+    return EmptyTile, fmt.Errorf("there is no tile with value %d in suit %d", value, suit)
+  }
+  copies := 4
+  if suit == 5 {
+    copies = 1
+  } else if suit == 6 {
+    copies = MaxJokers
+  }
+  for k := 0; k < copies; k++ {
+    instance := k
+    if value == 5 && suit <= 3 {
+      instance = (k+1) % copies
+    }
+    t := NewTile(suit, value, instance)
+    if !p.used[t.Id] {
+      p.used[t.Id] = true
+      return t, nil
+    }
+  }
+  return EmptyTile, fmt.Errorf("all %d copies of %s are already in use", copies, UnicodeDisplay[suit-1][value])
+}
+
+// take the red five of a standard suit
+func (p *TilePool) TakeRed(suit int) (Tile, error) {
+  if suit < 1 || suit > 3 {
+    return EmptyTile, fmt.Errorf("suit %d has no red five", suit)
+  }
+  t := NewTile(suit, 5, 0)
+  t.Red = true
+  if p.used[t.Id] {
+    return EmptyTile, fmt.Errorf("the red five %s is already in use", t.Ud)
+  }
+  p.used[t.Id] = true
+  return t, nil
+}
+
+// parse tiles given in compact notation (e.g., 123m456p77z, with 0 for a red five and 1j for a joker), as glyphs, or a mix of both
+func (p *TilePool) Parse(input string) ([]Tile, error) {
+  tiles := make([]Tile, 0, 14)
+  pending := make([]int, 0, 14)
+
+  for _, r := range input {
+    switch {
+      case unicode.IsSpace(r) || r == ',':
+        continue
+      case r >= '0' && r <= '9':
+        pending = append(pending, int(r-'0'))
+      case strings.ContainsRune("psmzfj", r):
+        if len(pending) == 0 {
+          return nil, fmt.Errorf("suit letter %c is not preceded by any values", r)
+        }
+        suit := strings.IndexRune("psmzfj", r)+1
+        for _, value := range pending {
+          var t Tile
+          var err error
+          if value == 0 {
+            t, err = p.TakeRed(suit)
+          } else {
+            t, err = p.Take(suit, value)
+          }
+          if err != nil {
+            return nil, err
+          }
+          tiles = append(tiles, t)
+        }
+        pending = pending[:0]
+      default:
+        suit, value := glyphTile(string(r))
+        if suit == 0 {
+          return nil, fmt.Errorf("unrecognized tile %q", string(r))
+        }
+        t, err := p.Take(suit, value)
+        if err != nil {
+          return nil, err
+        }
+        tiles = append(tiles, t)
+    }
+  }
+
+  if len(pending) > 0 {
+    return nil, fmt.Errorf("values %v are missing a suit letter", pending)
+  }
+  return tiles, nil
+}
+
+// parse sets separated by commas (e.g., "555z,123m"); the kind follows from the tiles
+func (p *TilePool) ParseSets(input string) ([]TileSet, error) {
+  sets := make([]TileSet, 0, 4)
+  for _, portion := range strings.Split(input, ",") {
+    if len(strings.TrimSpace(portion)) == 0 {
+      continue
+    }
+    tiles, err := p.Parse(portion)
+    if err != nil {
+      return nil, err
+    }
+    kind := setKind(tiles)
+    if kind == "" {
+      return nil, fmt.Errorf("%q is not a kong, pong or sequence", portion)
+    }
+    set := TileSet{ Kind: kind, UnderlyingTiles: tiles }
+    for _, t := range tiles {
+      set.Tiles += t.Ud
+    }
+    sets = append(sets, set)
+  }
+  return sets, nil
+}
+
+// compact notation for tiles, grouping consecutive tiles of a suit (e.g., 123m456p77z)
+func TilesNotation(tiles []Tile) string {
+  notation := ""
+  pending := ""
+  for i, t := range tiles {
+    pending += strconv.Itoa(t.Value)
+    if i == len(tiles)-1 || tiles[i+1].Suit != t.Suit {
+      notation += pending + SuitNotation[t.Suit-1]
+      pending = ""
+    }
+  }
+  return notation
+}
+
+// order tiles by suit and value
+func sortTiles(tiles []Tile) {
+  sort.SliceStable(tiles, func(a, b int) bool {
+    if tiles[a].Suit != tiles[b].Suit {
+      return tiles[a].Suit < tiles[b].Suit
+    }
+    return tiles[a].Value < tiles[b].Value
+  })
+}
+
+// suit and value of a glyph; zero if unknown
+func glyphTile(glyph string) (int, int) {
+  for i := 0; i < len(UnicodeDisplay); i++ {
+    for j := 1; j < len(UnicodeDisplay[i]); j++ {
+      if UnicodeDisplay[i][j] == glyph {
+        return i+1, j
+      }
+    }
+  }
+  return 0, 0
+}
+
+// kind of set formed by the tiles, if any
+func setKind(tiles []Tile) string {
+  if len(tiles) < 3 || len(tiles) > 4 {
+    return ""
+  }
+  same := true
+  for _, t := range tiles {
+    if t.Suit != tiles[0].Suit || t.Value != tiles[0].Value {
+      same = false
+    }
+  }
+  if same && len(tiles) == 4 {
+    return "kong"
+  } else if same {
+    return "triple"
+  }
+
+  if len(tiles) == 4 || tiles[0].Suit > 3 || tiles[1].Suit != tiles[0].Suit || tiles[2].Suit != tiles[0].Suit {
+    return ""
+  }
+  values := []int{ tiles[0].Value, tiles[1].Value, tiles[2].Value }
+  sort.Ints(values)
+  if values[1] == values[0]+1 && values[2] == values[1]+1 {
+    return "seq"
+  }
+  return ""
+}
+
+// # counting helpers
+// allocate counts in the layout used by CountHiddenTiles
+func newTileCounts() [][]int {
+  tileCounts := make([][]int, 4, 4)
+  for i := 0; i < 4; i++ {
+    tileCounts[i] = make([]int, 10, 10)
+  }
+  return tileCounts
+}
+
+// number of tiles represented by the counts
+func totalTileCount(tileCounts [][]int) int {
+  total := 0
+  for i := 0; i < 4; i++ {
+    for j := 1; j < 10; j++ {
+      total += tileCounts[i][j]
+    }
+  }
+  return total
+}
+
+// copies of each tile not visible to the player: four less the player's hidden tiles, the discards and every revealed set
+func (h PlayerHand) UnseenTileCounts(discard DiscardPile, hands []PlayerHand) [][]int {
+  unseen := newTileCounts()
+  hiddenCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+  discardCounts, _, _ := discard.CountDiscardTiles(false)
+
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      unseen[i][j] = 4 - hiddenCounts[i][j] - discardCounts[i][j]
+    }
+  }
+  for _, hand := range hands {
+    publicCounts, _, _ := hand.CountPublicSetTiles()
+    for i := 0; i < 4; i++ {
+      for j := 1; j <= MaxTileIndex[i]; j++ {
+        unseen[i][j] -= publicCounts[i][j]
+      }
+    }
+  }
+
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if unseen[i][j] < 0 {
+        unseen[i][j] = 0
+      }
+    }
+  }
+  return unseen
+}
+
+// # shanten
+// tiles away from a ready hand of sets and a pair: -1 is a complete hand, 0 is ready
+func ShantenNumber(tileCounts [][]int, revealedSets int) int {
+  // every three hidden tiles make a set, whatever the hand size
+  return regularShanten(tileCounts, totalTileCount(tileCounts)/3)
+}
+
+// shanten under a rule set: as ShantenNumber, or closer through thirteen orphans or a hand of pairs, where the rule set accepts them
+func (r RuleSet) Shanten(tileCounts [][]int, revealedSets int) int {
+  best := ShantenNumber(tileCounts, revealedSets)
+  if revealedSets > 0 {
+    return best
+  }
+  for _, special := range r.SpecialHands {
+    if special == "thirteenOrphans" && totalTileCount(tileCounts) <= StandardHandSize+1 {
+      if orphans := thirteenOrphansShanten(tileCounts); orphans < best {
+        best = orphans
+      }
+    }
+  }
+  for _, p := range r.enabledPatterns() {
+    if !p.AllPairs() || totalTileCount(tileCounts) > p.TileCount() {
+      continue
+    }
+    if pairs := pairsShanten(tileCounts, p.TileCount()/2, p.Distinct); pairs < best {
+      best = pairs
+    }
+  }
+  return best
+}
+
+// shanten for a number of pairs; distinct pairs need as many different tiles
+func pairsShanten(tileCounts [][]int, pairsNeeded int, distinct bool) int {
+  pairs, kinds := 0, 0
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if tileCounts[i][j] > 0 {
+        kinds++
+      }
+      if distinct && tileCounts[i][j] > 1 {
+        pairs++
+      } else if !distinct {
+        pairs += tileCounts[i][j]/2
+      }
+    }
+  }
+  if pairs > pairsNeeded {
+    pairs = pairsNeeded
+  }
+  shanten := pairsNeeded-1 - pairs
+  if distinct && kinds < pairsNeeded {
+    shanten += pairsNeeded - kinds
+  }
+  return shanten
+}
+
+// shanten for the thirteen orphans special win
+func thirteenOrphansShanten(tileCounts [][]int) int {
+  distinct := 0
+  havePair := false
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if !terminalOrHonor(i+1, j) {
+        continue
+      }
+      if tileCounts[i][j] > 0 {
+        distinct++
+      }
+      if tileCounts[i][j] > 1 {
+        havePair = true
+      }
+    }
+  }
+  shanten := 13 - distinct
+  if havePair {
+    shanten--
+  }
+  return shanten
+}
+
+// shanten for a number of sets and a pair; partial sets are pairs and two-tile sequences
+func regularShanten(tileCounts [][]int, setsNeeded int) int {
+  counts := make([][]int, 4, 4)
+  for i := 0; i < 4; i++ {
+    counts[i] = make([]int, 12, 12) // padding to simplify sequence checks
+    copy(counts[i], tileCounts[i])
+  }
+
+  best := 2*setsNeeded
+  var search func(suit int, value int, sets int, partials int, pair bool)
+  search = func(suit int, value int, sets int, partials int, pair bool) {
+    // advance to the next tile present
+    for suit < 4 && (value > MaxTileIndex[suit] || counts[suit][value] == 0) {
+      if value > MaxTileIndex[suit] {
+        suit++
+        value = 1
+      } else {
+        value++
+      }
+    }
+
+    if suit == 4 {
+      usablePartials := partials
+      if sets+usablePartials > setsNeeded {
+        usablePartials = setsNeeded-sets
+      }
+      shanten := 2*setsNeeded - 2*sets - usablePartials
+      if pair {
+        shanten--
+      }
+      if shanten < best {
+        best = shanten
+      }
+      return
+    }
+
+    c := counts[suit]
+    sequential := suit != 3
+
+    if c[value] >= 3 {
+      c[value] -= 3
+      search(suit, value, sets+1, partials, pair)
+      c[value] += 3
+    }
+    if sequential && c[value+1] > 0 && c[value+2] > 0 {
+      c[value]--; c[value+1]--; c[value+2]--
+      search(suit, value, sets+1, partials, pair)
+      c[value]++; c[value+1]++; c[value+2]++
+    }
+    if c[value] >= 2 {
+      c[value] -= 2
+      if !pair {
+        search(suit, value, sets, partials, true)
+      }
+      search(suit, value, sets, partials+1, pair)
+      c[value] += 2
+    }
+    if sequential && c[value+1] > 0 {
+      c[value]--; c[value+1]--
+      search(suit, value, sets, partials+1, pair)
+      c[value]++; c[value+1]++
+    }
+    if sequential && c[value+2] > 0 {
+      c[value]--; c[value+2]--
+      search(suit, value, sets, partials+1, pair)
+      c[value]++; c[value+2]++
+    }
+
+    // leave the tile isolated
+    c[value]--
+    search(suit, value, sets, partials, pair)
+    c[value]++
+  }
+
+  search(0, 1, 0, 0, false)
+  return best
+}
+
+// # waits and decompositions
+// winning tile with the number of copies still live
+type WaitingTile struct {
+  Item Tile
+  Live int
+}
+
+// one way to split a complete hand into an eye and sets
+type HandDecomposition struct {
+  Eye TileSet
+  Sets []TileSet
+}
+
+func (d HandDecomposition) String() string {
+  parts := make([]string, 0, len(d.Sets)+1)
+  parts = append(parts, d.Eye.Tiles)
+  for _, set := range d.Sets {
+    parts = append(parts, set.Tiles)
+  }
+  return strings.Join(parts, " ")
+}
+
+// tiles that complete the hand; a tile is only considered if a copy could still be drawn
+func (h PlayerHand) Waits(unseen [][]int) []WaitingTile {
+  waits := make([]WaitingTile, 0, 13)
+  hiddenCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if hiddenCounts[i][j] >= 4 {
+        continue
+      }
+      candidate := NewTile(i+1, j, 0)
+      if h.HaveWin(candidate, "draw") {
+        waits = append(waits, WaitingTile{ Item: candidate, Live: unseen[i][j] })
+      }
+    }
+  }
+  return waits
+}
+
+// every split of hidden tiles into one eye and sets; empty unless the counts form a complete hand
+func Decompositions(tileCounts [][]int) []HandDecomposition {
+  decompositions := make([]HandDecomposition, 0, 1)
+  if totalTileCount(tileCounts) % 3 != 2 {
+    return decompositions
+  }
+
+  counts := make([][]int, 4, 4)
+  for i := 0; i < 4; i++ {
+    counts[i] = make([]int, 12, 12)
+    copy(counts[i], tileCounts[i])
+  }
+
+  seen := make(map[string]bool)
+  var search func(suit int, value int, sets []TileSet, eye TileSet)
+  search = func(suit int, value int, sets []TileSet, eye TileSet) {
+    for suit < 4 && (value > MaxTileIndex[suit] || counts[suit][value] == 0) {
+      if value > MaxTileIndex[suit] {
+        suit++
+        value = 1
+      } else {
+        value++
+      }
+    }
+    if suit == 4 {
+      found := HandDecomposition{ Eye: eye, Sets: make([]TileSet, len(sets)) }
+      copy(found.Sets, sets)
+      sort.Slice(found.Sets, func(a, b int) bool { return found.Sets[a].Tiles < found.Sets[b].Tiles })
+      // taking a set before or after another at the same tile yields the same split
+      if !seen[found.String()] {
+        seen[found.String()] = true
+        decompositions = append(decompositions, found)
+      }
+      return
+    }
+
+    c := counts[suit]
+    glyph := UnicodeDisplay[suit]
+    if c[value] >= 3 {
+      c[value] -= 3
+      search(suit, value, append(sets, TileSet{ Kind: "triple", Tiles: glyph[value]+glyph[value]+glyph[value] }), eye)
+      c[value] += 3
+    }
+    if suit != 3 && c[value+1] > 0 && c[value+2] > 0 {
+      c[value]--; c[value+1]--; c[value+2]--
+      search(suit, value, append(sets, TileSet{ Kind: "seq", Tiles: glyph[value]+glyph[value+1]+glyph[value+2] }), eye)
+      c[value]++; c[value+1]++; c[value+2]++
+    }
+  }
+
+  // each possible eye, followed by sets only
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if counts[i][j] >= 2 {
+        counts[i][j] -= 2
+        search(0, 1, make([]TileSet, 0, 4), TileSet{ Kind: "pair", Tiles: UnicodeDisplay[i][j]+UnicodeDisplay[i][j] })
+        counts[i][j] += 2
+      }
+    }
+  }
+  return decompositions
+}
+
+// # discard efficiency
+// outcome of discarding one tile
+type DiscardOption struct {
+  Item Tile
+  Shanten int
+  // number of live tiles that would reduce the shanten number
+  Ukeire int
+  Accepted []Tile
+}
+
+// live tiles that would reduce the shanten number of the counts
+func (r RuleSet) ukeire(tileCounts [][]int, revealedSets int, unseen [][]int) (int, int, []Tile) {
+  shanten := r.Shanten(tileCounts, revealedSets)
+  total := 0
+  accepted := make([]Tile, 0, 8)
+
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      if unseen[i][j] == 0 || tileCounts[i][j] >= 4 {
+        continue
+      }
+      tileCounts[i][j]++
+      if r.Shanten(tileCounts, revealedSets) < shanten {
+        total += unseen[i][j]
+        accepted = append(accepted, NewTile(i+1, j, 0))
+      }
+      tileCounts[i][j]--
+    }
+  }
+  return shanten, total, accepted
+}
+
+// evaluate each distinct discard, best first: lowest shanten, then most accepting tiles
+func (h PlayerHand) DiscardOptions(unseen [][]int) []DiscardOption {
+  options := make([]DiscardOption, 0, 14)
+  tileCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+
+  seen := make(map[string]bool)
+  for _, t := range h.Hidden {
+    if t == EmptyTile || t.Suit > 4 || seen[t.Ud] {
+      continue
+    }
+    seen[t.Ud] = true
+
+    tileCounts[t.Suit-1][t.Value]--
+    shanten, total, accepted := h.ruleSet().ukeire(tileCounts, h.RevealedSets, unseen)
+    tileCounts[t.Suit-1][t.Value]++
+
+    options = append(options, DiscardOption{ Item: t, Shanten: shanten, Ukeire: total, Accepted: accepted })
+  }
+
+  sort.SliceStable(options, func(a, b int) bool {
+    if options[a].Shanten != options[b].Shanten {
+      return options[a].Shanten < options[b].Shanten
+    }
+    if options[a].Ukeire != options[b].Ukeire {
+      return options[a].Ukeire > options[b].Ukeire
+    }
+    if options[a].Item.Suit != options[b].Item.Suit {
+      return options[a].Item.Suit < options[b].Item.Suit
+    }
+    return options[a].Item.Value < options[b].Item.Value
+  })
+  return options
+}
+
+// # analysis
+// summary of a hand outside of a game
+type HandAnalysis struct {
+  HiddenTiles int
+  Shanten int
+  Win bool
+  Waits []WaitingTile
+  Decompositions []HandDecomposition
+  // for hands awaiting a discard, best first
+  DiscardOptions []DiscardOption
+  // for hands awaiting a tile
+  Ukeire int
+  Accepted []Tile
+}
+
+// analyze a hand given the discards and every hand whose revealed sets are visible (including this one)
+func (h PlayerHand) Analyze(discard DiscardPile, hands []PlayerHand) HandAnalysis {
+  unseen := h.UnseenTileCounts(discard, hands)
+  tileCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+
+  analysis := HandAnalysis{ HiddenTiles: totalTileCount(tileCounts) }
+  analysis.Shanten = h.ruleSet().Shanten(tileCounts, h.RevealedSets)
+
+  if analysis.HiddenTiles % 3 == 2 {
+    // awaiting a discard
+    analysis.Win = h.HaveWin(EmptyTile, "draw")
+    analysis.Decompositions = Decompositions(tileCounts)
+    if !analysis.Win {
+      analysis.DiscardOptions = h.DiscardOptions(unseen)
+    }
+  } else {
+    // awaiting a tile
+    _, analysis.Ukeire, analysis.Accepted = h.ruleSet().ukeire(tileCounts, h.RevealedSets, unseen)
+    analysis.Waits = h.Waits(unseen)
+    for _, wait := range analysis.Waits {
+      tileCounts[wait.Item.Suit-1][wait.Item.Value]++
+      analysis.Decompositions = append(analysis.Decompositions, Decompositions(tileCounts)...)
+      tileCounts[wait.Item.Suit-1][wait.Item.Value]--
+    }
+  }
+  return analysis
+}
+
+// glyphs for a list of tiles
+func tileGlyphs(tiles []Tile) string {
+  line := ""
+  for _, t := range tiles {
+    line += t.Ud
+  }
+  return line
+}
+
+// output analysis
+func (a HandAnalysis) OutputAnalysis() {
+  switch {
+    case a.Shanten < 0:
+      fmt.Printf("Shanten: -1 (complete hand)\n")
+    case a.Shanten == 0:
+      fmt.Printf("Shanten: 0 (ready)\n")
+    default:
+      fmt.Printf("Shanten: %d\n", a.Shanten)
+  }
+
+  if a.HiddenTiles % 3 == 1 {
+    if len(a.Waits) == 0 {
+      fmt.Printf("Waits: none\n")
+    } else {
+      fmt.Printf("Waits:\n")
+      for _, wait := range a.Waits {
+        fmt.Printf("  %v (%s): %d live\n", wait.Item.Ud, wait.Item.Notation(), wait.Live)
+      }
+    }
+    fmt.Printf("Ukeire: %d tiles (%s)\n", a.Ukeire, tileGlyphs(a.Accepted))
+  }
+
+  if len(a.Decompositions) > 0 {
+    fmt.Printf("Decompositions:\n")
+    for _, d := range a.Decompositions {
+      fmt.Printf("  %v\n", d)
+    }
+  } else if a.Win {
+    fmt.Printf("Decompositions: special win\n")
+  }
+
+  if len(a.DiscardOptions) > 0 {
+    best := a.DiscardOptions[0]
+    fmt.Printf("Recommended discard: %v (%s), shanten %d, ukeire %d (%s)\n", best.Item.Ud, best.Item.Notation(), best.Shanten, best.Ukeire, tileGlyphs(best.Accepted))
+    fmt.Printf("Discard options:\n")
+    for _, option := range a.DiscardOptions {
+      fmt.Printf("  %v: shanten %d, ukeire %d\n", option.Item.Ud, option.Shanten, option.Ukeire)
+    }
+  }
+}
@@ -24,6 +24,7 @@ import(
   "strconv"
   "strings"
   "log"
+  "unicode/utf8"
 )
 
 // state unit
@@ -35,6 +36,8 @@ type StateUnit struct {
 
 // end states
 var EndStates map[string]bool
+// state in discard processing for each claim
+var ClaimStates map[string]string
 
 // initialize end states
 func init() {  
@@ -44,17 +47,164 @@ func init() {
   EndStates["WinGameP2"] = true
   EndStates["WinGameP3"] = true
   EndStates["DrawGame"] = true
+  
+  ClaimStates = make(map[string]string)
+  ClaimStates["win"] = "HaveWin"
+  ClaimStates["kong"] = "HaveKong"
+  ClaimStates["pong"] = "HavePong"
+  ClaimStates["chow"] = "HaveSeq"
+}
+
+// first state of the claim following the given one in the rule set's priority order; an empty claim starts with the highest priority
+// once no claims remain, the player following the discarder draws
+func (g *Game) claimStateAfter(discarder int, claim string) StateUnit {
+  next := 0
+  for i, priority := range g.Rules.ClaimPriority {
+    if priority == claim {
+      next = i+1
+    }
+  }
+  for ; next < len(g.Rules.ClaimPriority); next++ {
+    if g.Rules.ClaimPriority[next] == "chow" && !g.Rules.ChowAllowed {
+      continue
+    }
+    return StateUnit { Player: (discarder+1) % g.Rules.Players, State: ClaimStates[g.Rules.ClaimPriority[next]], Phase: "DiscardProcessing" }
+  }
+  return StateUnit { Player: (discarder+1) % g.Rules.Players, State: "DrawTile", Phase: "DrawProcessing" }
+}
+
+// next state once a player passes on a claim: the same claim for the next player or, once all have passed, the next claim
+func (g *Game) nextClaimState(curState StateUnit, claim string) StateUnit {
+  discarder := g.Discard[len(g.Discard)-1].Player
+  
+  // only the next player may chow
+  if claim != "chow" && (curState.Player + 1) % g.Rules.Players != discarder {
+    return StateUnit { Player: (curState.Player + 1) % g.Rules.Players, State: curState.State, Phase: "DiscardProcessing" }
+  }
+  return g.claimStateAfter(discarder, claim)
+}
+
+// mark the latest discard as claimed and record it, with the tiles used, in the set made with it
+func (g *Game) claimDiscard(set TileSet, tiles []Tile) TileSet {
+  latest := len(g.Discard)-1
+  g.Discard[latest].Claimed = true
+  set.UnderlyingTiles = tiles
+  set.ClaimedTile = g.Discard[latest].Item
+  set.ClaimedFrom = g.Discard[latest].Player
+  set.ClaimedTurn = latest
+  return set
+}
+
+// dealer for the next game following a drawn game
+func (g *Game) DealerAfterDraw() int {
+  switch g.Rules.ExhaustiveDraw {
+    case ExhaustiveDrawRotate:
+      return (g.StartPlayer + 1) % g.Rules.Players
+    case ExhaustiveDrawDealerReady:
+      tileCounts, _, _ := g.Hands[g.StartPlayer].CountHiddenTiles(EmptyTile)
+      if g.Rules.Shanten(tileCounts, g.Hands[g.StartPlayer].RevealedSets) > 0 {
+        return (g.StartPlayer + 1) % g.Rules.Players
+      }
+  }
+  return g.StartPlayer
 }
 
 func (g *Game) handToPlayer(newPlayer int) {
   if g.CurrentPlayer != newPlayer {
     // clear screen + request to be handed over to new player
-    fmt.Printf("\u001b[2J")
-    fmt.Printf("Next action is to be completed by player %d. Please have them drop by.\n", newPlayer)
+    g.announcePlayer(fmt.Sprintf("Next action is to be completed by player %d. Please have them drop by.", newPlayer))
+    g.CurrentPlayer = newPlayer
+  }
+}
+
+// clear the screen and wait for acknowledgement before showing a player's hand
+func (g *Game) announcePlayer(message string) {
+  if g.Tui != nil {
+    g.Tui.Announce(message)
+    return
+  }
+  fmt.Printf("\u001b[2J")
+  fmt.Printf("%s\n", message)
+  var input string
+  fmt.Scanln(&input)
+  fmt.Printf("\u001b[2J")
+}
+
+// ask a human player to accept or decline an opportunity; an empty response accepts
+func (g *Game) promptAccept(player int, claim string, message string) bool {
+  if g.Tui != nil {
+    accepted, _ := g.Tui.ChooseClaim(g, player, claim, message, nil)
+    return accepted
+  }
+  
+  for {
     var input string
+    fmt.Printf("%s (y/n) [y]\n", message)
     fmt.Scanln(&input)
-    g.CurrentPlayer = newPlayer
-    fmt.Printf("\u001b[2J")
+    
+    if input == "" || input == "y" {
+      return true
+    } else if input == "n" {
+      return false
+    }
+    fmt.Printf("Invalid response %q; please enter y or n.\n", input)
+  }
+}
+
+// ask a human player to choose one of several sets; an empty response declines
+func (g *Game) promptOption(player int, claim string, message string, options []TileSet) (bool, int) {
+  if g.Tui != nil {
+    return g.Tui.ChooseClaim(g, player, claim, message, options)
+  }
+  
+  for {
+    var input string
+    fmt.Printf("%s (#) [n]\n", message)
+    for i := 0; i < len(options); i++ {
+      fmt.Printf("Option %d: Set of %d: %v\n", i, utf8.RuneCountInString(options[i].Tiles), options[i].Tiles)
+    }
+    fmt.Scanln(&input)
+    
+    if input == "" || input == "n" {
+      return false, 0
+    }
+    selection, err := strconv.Atoi(input)
+    if err == nil && selection >= 0 && selection < len(options) {
+      return true, selection
+    }
+    fmt.Printf("Invalid response %q; please enter an option number or n.\n", input)
+  }
+}
+
+// ask a human player which tile to discard; an empty response takes the suggestion
+func (g *Game) promptDiscard(player int, suggestion int) int {
+  if g.Tui != nil {
+    return g.Tui.ChooseDiscard(g, player, suggestion)
+  }
+  
+  helperLine := ""
+  for i := 0; i < len(g.Hands[player].Hidden); i++ {
+    if g.Hands[player].Hidden[i] != EmptyTile {
+      helperLine += fmt.Sprintf("(%s%d)", g.Hands[player].Hidden[i].Ud, i)
+    }
+  }
+  
+  for {
+    var input string
+    fmt.Printf("%s\n", helperLine)
+    
+    // which tile does the player wish to discard?
+    fmt.Printf("Player %d: What do you want to discard? # [%d]\n", player, suggestion)
+    fmt.Scanln(&input)
+    
+    if len(input) == 0 {
+      return suggestion
+    }
+    selection, err := strconv.Atoi(input)
+    if err == nil && selection >= 0 && selection < len(g.Hands[player].Hidden) && g.Hands[player].Hidden[selection] != EmptyTile {
+      return selection
+    }
+    fmt.Printf("Invalid selection %q; please enter one of the numbers shown after each tile.\n", input)
   }
 }
 
@@ -62,17 +212,18 @@ func (g *Game) handToPlayer(newPlayer int) {
 // begin game
 func (g *Game) BeginGame()(bool, int) {
   stateObj := StateUnit { Player: g.CurrentPlayer, State: "HaveWin", Phase: "DrawProcessing" }
+  if g.Rules.Charleston {
+    stateObj = StateUnit { Player: g.CurrentPlayer, State: "Charleston", Phase: "PassProcessing" }
+  }
   
-  g.OutputLog.Println("gameplay begins with player", g.CurrentPlayer)
+  for i := range g.Hands {
+    wind := WindNames[g.SeatWind(i)]
+    g.LogAction(i, "seat", nil, wind, fmt.Sprintf("player %d (%s) sits as %s", i, g.PlayerName(i), wind))
+  }
+  g.LogAction(g.CurrentPlayer, "begin", nil, "", fmt.Sprintf("gameplay begins with player %d", g.CurrentPlayer))
   
   if !g.Hands[g.CurrentPlayer].ComputerPlayer {
-    // clear screen
-    // TODO: deduplicate code with handToPlayer
-    fmt.Printf("\u001b[2J")
-    fmt.Printf("Game is to be started by player %d. Please have them drop by.\n", g.CurrentPlayer)
-    var input string
-    fmt.Scanln(&input)
-    fmt.Printf("\u001b[2J")
+    g.announcePlayer(fmt.Sprintf("Game is to be started by player %d (%s, sitting as %s). Please have them drop by.", g.CurrentPlayer, g.PlayerName(g.CurrentPlayer), WindNames[g.SeatWind(g.CurrentPlayer)]))
   }
   
   var nextState StateUnit
@@ -83,13 +234,24 @@ func (g *Game) BeginGame()(bool, int) {
     
     if EndStates[nextState.State] {
       fmt.Printf("Game ended: %v\n", nextState.State)    
-      g.OutputLog.Println("gameplay ends with outcome", nextState.State)
+      if nextState.State != "DrawGame" {
+        fmt.Printf("Score: %v\n", g.Win)
+      } else {
+        fmt.Printf("Drawn: %s\n", g.DrawReason)
+      }
+      if g.Rules.Riichi {
+        fmt.Printf("Riichi sticks on the table: %d\n", g.RiichiSticks)
+      }
+      outcome := fmt.Sprintf("gameplay ends with outcome %s", nextState.State)
+      if nextState.State == "DrawGame" {
+        outcome += ": "+g.DrawReason
+      }
+      g.LogAction(nextState.Player, "end", nil, nextState.State, outcome)
       
       g.OutputDiscardedTiles()
-      g.Hands[0].OutputHand(true,true)
-      g.Hands[1].OutputHand(true,true)
-      g.Hands[2].OutputHand(true,true)
-      g.Hands[3].OutputHand(true,true)
+      for i := range g.Hands {
+        g.Hands[i].OutputHand(true,true)
+      }
       break;
     } else {
       stateObj = nextState
@@ -106,50 +268,98 @@ func (g *Game) BeginGame()(bool, int) {
     case "WinGameP3": 
       return true, 3
     case "DrawGame": 
-      return false, g.StartPlayer
+      return false, g.DealerAfterDraw()
   }
   return false, g.StartPlayer
 }
 
 // show game state
 func (g *Game) ShowGameState(reveal bool, player int, showLatestTile bool) {
+  // the terminal ui redraws the table along with each prompt
+  if g.Tui != nil {
+    return
+  }
+  
   // clear screen
   fmt.Printf("\u001b[2J")
   
   g.OutputDiscardedTiles()
-  fmt.Printf("%d new tiles remain\n\n", g.UndealtTileCount)
+  fmt.Printf("%s; %d new tiles remain\n", g.RoundLabel(), g.UndealtTileCount)
+  if g.ShowWall {
+    for _, line := range g.Wall().Render(player) {
+      fmt.Printf("%s\n", line)
+    }
+  }
+  for _, line := range g.RiichiLines() {
+    fmt.Printf("%s\n", line)
+  }
+  fmt.Println()
   
-  g.Hands[(player+3)%4].OutputHand(false,true)
-  g.Hands[(player+2)%4].OutputHand(false,true)
-  g.Hands[(player+1)%4].OutputHand(false,true)
+  for offset := g.Rules.Players-1; offset > 0; offset-- {
+    opponent := (player+offset)%g.Rules.Players
+    fmt.Printf("P%d: %s\n", opponent, g.SeatLabel(opponent))
+    g.Hands[opponent].OutputHand(false,true)
+  }
 
+  fmt.Printf("P%d: %s\n", player, g.SeatLabel(player))
   g.Hands[player].OutputHand(true,true)
   
   if showLatestTile && g.Hands[player].LastNewTile != EmptyTile {
     fmt.Printf("P%d-N: %v\n", player, g.Hands[player].LastNewTile.Ud)
   }
   
+  if g.Assist {
+    for _, line := range g.ReadyHandLines(player) {
+      fmt.Printf("%s\n", line)
+    }
+  }
 }
 
-// process state to get next state
+// end the game without a winner, recording how it came about
+func (g *Game) drawGame(player int, reason string) StateUnit {
+  g.DrawReason = reason
+  if VerboseDebug {
+    fmt.Printf("[vd] Player %d: the game is drawn: %s\n", player, reason)
+  }
+  return StateUnit { Player: player, State: "DrawGame", Phase: "DrawProcessing" }
+}
+
+// process state to get next state, auditing the tiles afterwards in audit mode
+// tables set up without a deal, as for some tests, are not audited
 func (g *Game) processState(curState StateUnit) StateUnit {
+  nextState := g.transition(curState)
+  
+  if AuditMode && g.DrawLocationsSet {
+    if err := g.Audit(); err != nil {
+      log.Fatalf("moving from %v to %v: %v", curState, nextState, err)
+    }
+  }
+  return nextState
+}
+
+// carry out a state and determine the next one
+func (g *Game) transition(curState StateUnit) StateUnit {
   if curState.State == "HaveWin" && curState.Phase == "DrawProcessing" {
     // does the player have a winning hand?
-    if g.Hands[curState.Player].HaveWin(EmptyTile, "draw") {
+    if win, score := g.HaveQualifyingWin(curState.Player, EmptyTile, "draw"); win {
       var input string
       
       if !g.Hands[curState.Player].ComputerPlayer {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
-          
-        fmt.Printf("Player %d: You appear to have a win. Do you take it? (y/n) [y]\n", curState.Player)
-        fmt.Scanln(&input)
+        
+        if g.promptAccept(curState.Player, "win", fmt.Sprintf("Player %d: You appear to have a win. Do you take it?", curState.Player)) {
+          input = "y"
+        } else {
+          input = "n"
+        }
       } else {
         input = g.Hands[curState.Player].TakeWin(g.Discard, false, g.Hands)
       }
       
       if input == "" || input == "y" {
-        g.OutputLog.Printf("player %d chose to take the win\n", curState.Player)
+        g.Win, g.Winner, g.DealtIn = score, curState.Player, -1
+        g.LogAction(curState.Player, "win", []Tile{ g.Hands[curState.Player].LastNewTile }, "draw", fmt.Sprintf("player %d chose to take the win worth %v", curState.Player, score))
         
         return StateUnit { Player: curState.Player, State: "WinGameP"+strconv.Itoa(curState.Player), Phase: "DrawProcessing" }
       }
@@ -161,29 +371,31 @@ func (g *Game) processState(curState StateUnit) StateUnit {
     return StateUnit { Player: curState.Player, State: "HaveKong", Phase: "DrawProcessing" }
   } else if curState.State == "HaveKong" && curState.Phase == "DrawProcessing" {
     
-    if kongResult, kongOptions := g.Hands[curState.Player].HaveKong(EmptyTile, "draw"); kongResult {
+    if kongResult, kongOptions := g.Hands[curState.Player].HaveKong(EmptyTile, "draw"); kongResult && g.KongAllowed() {
     
       var input string
       
       if !g.Hands[curState.Player].ComputerPlayer {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
-          
-        fmt.Printf("Player %d: You appear to have at least one set of four. Do you take it, if so, which? (#) [n]\n", curState.Player)
-        for i := 0; i < len(kongOptions); i++ {
-          fmt.Printf("Option %d: Set of 4: %v\n", i, kongOptions[i].Tiles)
+        
+        if taken, option := g.promptOption(curState.Player, "kong", fmt.Sprintf("Player %d: You appear to have at least one set of four. Do you take it, if so, which?", curState.Player), kongOptions); taken {
+          input = strconv.Itoa(option)
+        } else {
+          input = "n"
         }
-        fmt.Scanln(&input)
       } else {
         input = g.Hands[curState.Player].TakeKong(g.Discard, true, g.Hands)
       }
       
       selection, _ := strconv.Atoi(input)
       
-      if input != "n" && (selection >= 0 || selection < len(kongOptions)) {
+      if input != "n" && selection >= 0 && selection < len(kongOptions) {
         counter := 0
-        for i := 0; i < 14; i++ {
+        kongTiles := make([]Tile, 0, 4)
+        for i := 0; i < len(g.Hands[curState.Player].Hidden); i++ {
           if g.Hands[curState.Player].Hidden[i] != EmptyTile && strings.Contains(kongOptions[selection].Tiles, g.Hands[curState.Player].Hidden[i].Ud) {
+            kongTiles = append(kongTiles, g.Hands[curState.Player].Hidden[i])
             g.Hands[curState.Player].Hidden[i] = EmptyTile
             counter++
           }
@@ -193,23 +405,36 @@ func (g *Game) processState(curState StateUnit) StateUnit {
         
         if counter > 2 {
           // move set away
-          g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, kongOptions[selection])
+          kongSet := kongOptions[selection]
+          kongSet.UnderlyingTiles = kongTiles
+          g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, kongSet)
           g.Hands[curState.Player].RevealedSets++
         } else {
           //g.OutputLog.Printf("kong needs update\n")
-          // update set
+          // update set, keeping the tile claimed for the triple
           for i := 0; i < g.Hands[curState.Player].RevealedSets; i++ {
             if g.Hands[curState.Player].RevealedTileSets[i].Kind == "triple" && strings.Contains(kongOptions[selection].Tiles, g.Hands[curState.Player].RevealedTileSets[i].Tiles) {
               // update
-              g.Hands[curState.Player].RevealedTileSets[i] = kongOptions[selection]
+              triple := g.Hands[curState.Player].RevealedTileSets[i]
+              triple.Kind = kongOptions[selection].Kind
+              triple.Tiles = kongOptions[selection].Tiles
+              triple.UnderlyingTiles = append(append([]Tile{}, triple.UnderlyingTiles...), kongTiles...)
+              g.Hands[curState.Player].RevealedTileSets[i] = triple
               //g.OutputLog.Printf("kong updated\n")
               break
             }
           }
         }
         
-        g.OutputLog.Printf("player %d reveals kong comprising %s\n", curState.Player, kongOptions[selection].Tiles)
+        g.KongCount++
+        g.LogAction(curState.Player, "kong", kongTiles, "draw", fmt.Sprintf("player %d reveals kong comprising %s", curState.Player, kongOptions[selection].Tiles))
         
+        // a tile added to a revealed triple may be robbed by the other players
+        if kongOptions[selection].Kind == "addedKong" {
+          g.KongTile = kongTiles[0]
+          g.KongPlayer = curState.Player
+          return StateUnit { Player: (curState.Player + 1) % g.Rules.Players, State: "RobKong", Phase: "KongProcessing" }
+        }
         return StateUnit { Player: curState.Player, State: "DrawReplacementTile", Phase: "DrawProcessing" }
 
       }
@@ -220,11 +445,60 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       fmt.Printf("[vd] Player %d: No kong at this time; moving on to discard processing.\n", curState.Player)
     }
     return StateUnit { Player: curState.Player, State: "Discard", Phase: "DrawProcessing" }
+  } else if curState.State == "RobKong" && curState.Phase == "KongProcessing" {
+    // every other player has passed: the kong stands and its player draws a replacement
+    if curState.Player == g.KongPlayer {
+      g.KongTile = EmptyTile
+      return StateUnit { Player: g.KongPlayer, State: "DrawReplacementTile", Phase: "DrawProcessing" }
+    }
+    
+    relationship := "other"
+    if (g.KongPlayer + 1) % g.Rules.Players == curState.Player {
+      relationship = "previous"
+    }
+    
+    // does the player win with the tile added to the kong?
+    if win, score := g.HaveQualifyingWin(curState.Player, g.KongTile, relationship); win {
+      var input string
+      
+      if !g.Hands[curState.Player].ComputerPlayer {
+        g.handToPlayer(curState.Player)
+        g.ShowGameState(false, curState.Player, true)
+        
+        if g.promptAccept(curState.Player, "win", fmt.Sprintf("Player %d: You appear to have a win if you rob the kong of player %d of the tile %v. Do you take it?", curState.Player, g.KongPlayer, g.KongTile)) {
+          input = "y"
+        } else {
+          input = "n"
+        }
+      } else {
+        input = g.Hands[curState.Player].TakeWin(g.Discard, true, g.Hands)
+      }
+      
+      if input == "" || input == "y" {
+        g.Hands[g.KongPlayer].RobKong(g.KongTile)
+        err := g.Hands[curState.Player].Receive(g.KongTile)
+        if err != nil {
+          log.Fatal(err)
+        }
+        g.Win, g.Winner, g.DealtIn = score, curState.Player, g.KongPlayer
+        g.LogAction(curState.Player, "win", []Tile{ g.KongTile }, "kong", fmt.Sprintf("player %d chose to take the win by robbing the kong of player %d, worth %v", curState.Player, g.KongPlayer, score))
+        
+        return StateUnit { Player: curState.Player, State: "WinGameP"+strconv.Itoa(curState.Player), Phase: "KongProcessing" }
+      }
+      
+      // passing on a win leaves the player furiten
+      g.Hands[curState.Player].MissedWin = true
+    }
+    
+    if VerboseDebug {
+      fmt.Printf("[vd] Player %d: No win by robbing the kong; moving on to next player.\n", curState.Player)
+    }
+    return StateUnit { Player: (curState.Player + 1) % g.Rules.Players, State: "RobKong", Phase: "KongProcessing" }
   } else if curState.State == "DrawReplacementTile" {
     newTile, err := g.GetNewTile(&g.ReplacementPointer, true) 
     
     if err != nil {
-      return StateUnit { Player: curState.Player, State: "DrawGame", Phase: "DrawProcessing" }
+      return g.drawGame(curState.Player, err.Error())
     }
     
     err = g.Hands[curState.Player].Receive(newTile)
@@ -232,6 +506,9 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       log.Fatal(err)
     }
     
+    g.LogAction(curState.Player, "replacement", []Tile{ newTile }, "", "")
+    g.KongReplacement = true
+    
     if VerboseDebug {
       fmt.Printf("[vd] Player %d drew as replacement %v\n", curState.Player, newTile)
     }
@@ -250,11 +527,11 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       if err != nil {
         log.Fatal(err)
       }
-      g.OutputLog.Printf("player %d reveals special tile %s\n", curState.Player, specialTile.Ud)
+      g.LogAction(curState.Player, "special", []Tile{ specialTile }, "", fmt.Sprintf("player %d reveals special tile %s", curState.Player, specialTile.Ud))
       
       newTile, err := g.GetNewTile(&g.ReplacementPointer, true)
       if err != nil {
-        return StateUnit { Player: curState.Player, State: "DrawGame", Phase: "DrawProcessing" }
+        return g.drawGame(curState.Player, err.Error())
       }
             
       g.Hands[curState.Player].Receive(newTile)
@@ -272,10 +549,11 @@ func (g *Game) processState(curState StateUnit) StateUnit {
   } else if curState.State == "DrawTile" {
     newTile, err := g.GetNewTile(&g.DrawPointer, false)
     if err != nil {
-      return StateUnit { Player: curState.Player, State: "DrawGame", Phase: "DrawProcessing" }
+      return g.drawGame(curState.Player, err.Error())
     }
     
-    g.OutputLog.Printf("player %d drew a tile\n", curState.Player)
+    g.LogAction(curState.Player, "draw", []Tile{ newTile }, "", fmt.Sprintf("player %d drew a tile", curState.Player))
+    g.KongReplacement = false
     
     err = g.Hands[curState.Player].Receive(newTile)
     if err != nil {
@@ -292,26 +570,46 @@ func (g *Game) processState(curState StateUnit) StateUnit {
   } else if curState.State == "Discard" {
     var input string
     var discardSuggestion = g.Hands[curState.Player].Discard(g.Discard, false, g.Hands)
-      
-    if !g.Hands[curState.Player].ComputerPlayer {
+    riichiDiscards := g.RiichiDiscards(curState.Player)
+    declareRiichi := false
+    
+    if g.Hands[curState.Player].Riichi {
+      // after riichi, the drawn tile is discarded
+      input = strconv.Itoa(g.Hands[curState.Player].tilePosition(g.Hands[curState.Player].LastNewTile))
+    } else if !g.Hands[curState.Player].ComputerPlayer {
       g.handToPlayer(curState.Player)
       g.ShowGameState(false, curState.Player, true)
-  
-      helperLine := ""
-      for i := 0; i < 14; i++ {
-        if g.Hands[curState.Player].Hidden[i] != EmptyTile {
-          helperLine += fmt.Sprintf("(%s%d)", g.Hands[curState.Player].Hidden[i].Ud, i)
-        }
-      }
-      fmt.Printf("%s\n", helperLine)
       
-      // which tile does the player wish to discard?
+      if len(riichiDiscards) > 0 {
+        declareRiichi = g.promptAccept(curState.Player, "riichi", fmt.Sprintf("Player %d: Your concealed hand can be made ready. Do you declare riichi?", curState.Player))
+      }
       
-      fmt.Printf("Player %d: What do you want to discard? # [%s]\n", curState.Player, discardSuggestion)
-
-      fmt.Scanln(&input)
+      suggestion, _ := strconv.Atoi(discardSuggestion)
+      if declareRiichi {
+        suggestion = g.bestRiichiDiscard(curState.Player)
+      }
+      for {
+        position := g.promptDiscard(curState.Player, suggestion)
+        input = strconv.Itoa(position)
+        
+        if declareRiichi && !containsPosition(riichiDiscards, position) {
+          fmt.Printf("Discarding %v does not leave your hand ready; choose another tile to declare riichi.\n", g.Hands[curState.Player].Hidden[position].Ud)
+          continue
+        }
+        if !g.Assist || declareRiichi {
+          break
+        }
+        // warn before giving up a ready hand
+        if breaks, keepReady := g.BreaksReadyHand(curState.Player, position); !breaks || g.promptAccept(curState.Player, "discard", fmt.Sprintf("Player %d: Discarding %v breaks your ready hand (discarding one of %s keeps it). Discard anyway?", curState.Player, g.Hands[curState.Player].Hidden[position].Ud, keepReady)) {
+          break
+        }
+      }
     } else {
-      input = discardSuggestion
+      input = g.Hands[curState.Player].StrategyDiscard(g.Discard, g.Hands)
+      if len(riichiDiscards) > 0 && g.Hands[curState.Player].TakeRiichi(g.Discard, g.Hands) == "y" {
+        declareRiichi = true
+        input = strconv.Itoa(g.bestRiichiDiscard(curState.Player))
+      }
     }
     
     if len(input) == 0 {
@@ -320,7 +618,7 @@ func (g *Game) processState(curState StateUnit) StateUnit {
     
     selection, _ := strconv.Atoi(input)
     
-    if selection < 0 || selection > 13 || g.Hands[curState.Player].Hidden[selection] == EmptyTile {
+    if selection < 0 || selection >= len(g.Hands[curState.Player].Hidden) || g.Hands[curState.Player].Hidden[selection] == EmptyTile {
       selection = 0
     }
     
@@ -330,85 +628,105 @@ func (g *Game) processState(curState StateUnit) StateUnit {
    
     g.Discard = append(g.Discard, newDiscard)
     
-    g.OutputLog.Printf("player %d discards tile %s\n", curState.Player, newDiscard.Item.Ud)
+    g.LogAction(curState.Player, "discard", []Tile{ newDiscard.Item }, "", fmt.Sprintf("player %d discards tile %s", curState.Player, newDiscard.Item.Ud))
+    
+    // temporary furiten ends with the player's own discard
+    if !g.Hands[curState.Player].Riichi {
+      g.Hands[curState.Player].MissedWin = false
+    }
+    if declareRiichi {
+      g.Hands[curState.Player].Riichi = true
+      g.RiichiSticks++
+      g.LogAction(curState.Player, "riichi", []Tile{ newDiscard.Item }, "", fmt.Sprintf("player %d declares riichi", curState.Player))
+    }
+    
+    if g.Assist && !g.Hands[curState.Player].ComputerPlayer && g.Tui == nil {
+      for _, line := range g.ReadyHandLines(curState.Player) {
+        fmt.Printf("%s\n", line)
+      }
+    }
     
     g.Hands[curState.Player].LastNewTile = EmptyTile
+    g.KongReplacement = false
     
     if VerboseDebug {
       fmt.Printf("[vd] Player %d chose to discard %v\n", curState.Player, newDiscard.Item.Ud)
     }
 
-    return StateUnit { Player: (curState.Player+1) % 4, State: "HaveWin", Phase: "DiscardProcessing" }
+    return g.claimStateAfter(curState.Player, "")
   } else if curState.State == "HaveWin" && curState.Phase == "DiscardProcessing" {
     relationship := ""
-    if (g.Discard[len(g.Discard)-1].Player + 1) % 4 == curState.Player {
+    if (g.Discard[len(g.Discard)-1].Player + 1) % g.Rules.Players == curState.Player {
       relationship = "previous"
     } else {
       relationship = "other"
     }
     
     // does the player have a winning hand?
-    if g.Hands[curState.Player].HaveWin(g.Discard[len(g.Discard)-1].Item, relationship) {
+    if win, score := g.HaveQualifyingWin(curState.Player, g.Discard[len(g.Discard)-1].Item, relationship); win {
       var input string
       
       if !g.Hands[curState.Player].ComputerPlayer {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
         
-        fmt.Printf("Player %d: You appear to have a win if you add in the discarded tile %v. Do you take it? (y/n) [y]\n", curState.Player, g.Discard[len(g.Discard)-1].Item)
-
-        fmt.Scanln(&input)
+        if g.promptAccept(curState.Player, "win", fmt.Sprintf("Player %d: You appear to have a win if you add in the discarded tile %v. Do you take it?", curState.Player, g.Discard[len(g.Discard)-1].Item)) {
+          input = "y"
+        } else {
+          input = "n"
+        }
       } else {
         input = g.Hands[curState.Player].TakeWin(g.Discard, true, g.Hands)
       }
       
       if input == "" || input == "y" {
-        g.OutputLog.Printf("player %d chose to take the win with use of the discarded tile\n", curState.Player)
+        g.Win, g.Winner, g.DealtIn = score, curState.Player, g.Discard[len(g.Discard)-1].Player
+        g.LogAction(curState.Player, "win", []Tile{ g.Discard[len(g.Discard)-1].Item }, "discard", fmt.Sprintf("player %d chose to take the win with use of the discarded tile, worth %v", curState.Player, score))
         
         return StateUnit { Player: curState.Player, State: "WinGameP"+strconv.Itoa(curState.Player), Phase: "DiscardProcessing" }
       }
+      
+      // passing on a win leaves the player furiten
+      g.Hands[curState.Player].MissedWin = true
     }
 
     if VerboseDebug {
       fmt.Printf("[vd] Player %d: No win at this time; moving on to next player.\n", curState.Player)
     }
     
-    if (curState.Player + 1) % 4 == g.Discard[len(g.Discard)-1].Player {
-      return StateUnit { Player: (curState.Player + 2) % 4, State: "HaveKong", Phase: "DiscardProcessing" }
-    } else {
-      return StateUnit { Player: (curState.Player + 1) % 4, State: "HaveWin", Phase: "DiscardProcessing" }
-    }
+    return g.nextClaimState(curState, "win")
   } else if curState.State == "HaveKong" && curState.Phase == "DiscardProcessing" {
     relationship := ""
-    if (g.Discard[len(g.Discard)-1].Player + 1) % 4 == curState.Player {
+    if (g.Discard[len(g.Discard)-1].Player + 1) % g.Rules.Players == curState.Player {
       relationship = "previous"
     } else {
       relationship = "other"
     }
     
-    if kongResult, kongOptions := g.Hands[curState.Player].HaveKong(g.Discard[len(g.Discard)-1].Item, relationship); kongResult {
+    if kongResult, kongOptions := g.Hands[curState.Player].HaveKong(g.Discard[len(g.Discard)-1].Item, relationship); kongResult && g.KongAllowed() {
       var input string
       
       if !g.Hands[curState.Player].ComputerPlayer {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
         
-        fmt.Printf("Player %d: You appear to have one set of four. Do you take it? (#) [n]\n", curState.Player)
-        for i := 0; i < len(kongOptions); i++ {
-          fmt.Printf("Option %d: Set of 4: %v\n", i, kongOptions[i].Tiles)
+        if taken, option := g.promptOption(curState.Player, "kong", fmt.Sprintf("Player %d: You appear to have one set of four. Do you take it?", curState.Player), kongOptions); taken {
+          input = strconv.Itoa(option)
+        } else {
+          input = "n"
         }
       } else {
         input = g.Hands[curState.Player].TakeKong(g.Discard, true, g.Hands)
       }
       
-      fmt.Scanln(&input)
-      
       selection, _ := strconv.Atoi(input)
       
-      if input != "n" && (selection >= 0 || selection < len(kongOptions)) {
+      if input != "n" && selection >= 0 && selection < len(kongOptions) {
         counter := 0
-        for i := 0; i < 14; i++ {
+        kongTiles := make([]Tile, 0, 4)
+        for i := 0; i < len(g.Hands[curState.Player].Hidden); i++ {
           if g.Hands[curState.Player].Hidden[i] != EmptyTile && strings.Contains(kongOptions[selection].Tiles, g.Hands[curState.Player].Hidden[i].Ud) {
+            kongTiles = append(kongTiles, g.Hands[curState.Player].Hidden[i])
             g.Hands[curState.Player].Hidden[i] = EmptyTile
             counter++
           }
@@ -416,9 +734,10 @@ func (g *Game) processState(curState StateUnit) StateUnit {
         
         //g.OutputLog.Printf("kong? %d\n", counter)
         
+        kongTiles = append(kongTiles, g.Discard[len(g.Discard)-1].Item)
         if counter > 2 {
           // can only move set away on discard
-          g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, kongOptions[selection])
+          g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, g.claimDiscard(kongOptions[selection], kongTiles))
           g.Hands[curState.Player].RevealedSets++
         } else {
           //g.OutputLog.Printf("kong needs update\n")
@@ -433,7 +752,8 @@ func (g *Game) processState(curState StateUnit) StateUnit {
           }
         }
         
-        g.OutputLog.Printf("player %d reveals kong comprising %s\n", curState.Player, kongOptions[selection].Tiles)
+        g.KongCount++
+        g.LogAction(curState.Player, "kong", kongTiles, "discard", fmt.Sprintf("player %d reveals kong comprising %s", curState.Player, kongOptions[selection].Tiles))
         
         return StateUnit { Player: curState.Player, State: "DrawReplacementTile", Phase: "DrawProcessing" }
       }
@@ -444,14 +764,10 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       fmt.Printf("[vd] Player %d: No kong at this time; moving on to next player.\n", curState.Player)
     }
 
-    if (curState.Player + 1) % 4 == g.Discard[len(g.Discard)-1].Player {
-      return StateUnit { Player: (curState.Player + 2) % 4, State: "HavePong", Phase: "DiscardProcessing" }
-    } else {
-      return StateUnit { Player: (curState.Player + 1) % 4, State: "HaveKong", Phase: "DiscardProcessing" }
-    }
+    return g.nextClaimState(curState, "kong")
   } else if curState.State == "HavePong" && curState.Phase == "DiscardProcessing" {
     relationship := ""
-    if (g.Discard[len(g.Discard)-1].Player + 1) % 4 == curState.Player {
+    if (g.Discard[len(g.Discard)-1].Player + 1) % g.Rules.Players == curState.Player {
       relationship = "previous"
     } else {
       relationship = "other"
@@ -464,31 +780,32 @@ func (g *Game) processState(curState StateUnit) StateUnit {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
         
-        fmt.Printf("Player %d: You can have a pong of %v with the most recent discard. Do you take it? (y/n) [y]\n", curState.Player, pong)
-        fmt.Scanln(&input)
+        if g.promptAccept(curState.Player, "pong", fmt.Sprintf("Player %d: You can have a pong of %v with the most recent discard. Do you take it?", curState.Player, pong)) {
+          input = "y"
+        } else {
+          input = "n"
+        }
       } else {
         input = g.Hands[curState.Player].TakePong(g.Discard, true, g.Hands)
       }
       
       if input == "" || input == "y" {
-        // move set away
-        pongSet := TileSet{ Kind: "triple", Tiles: pong+pong+pong }
-        
-        g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, pongSet)
-
-        g.Hands[curState.Player].RevealedSets++
         counter := 0
-        for i := 0; i < 14 && counter < 2; i++ {
+        pongTiles := []Tile{ g.Discard[len(g.Discard)-1].Item }
+        for i := 0; i < len(g.Hands[curState.Player].Hidden) && counter < 2; i++ {
           if g.Hands[curState.Player].Hidden[i].Ud == pong {
+            pongTiles = append(pongTiles, g.Hands[curState.Player].Hidden[i])
             g.Hands[curState.Player].Hidden[i] = EmptyTile
             counter++
           }
         }
-                
-        // remove last discard
-        g.Discard = g.Discard[:len(g.Discard)-1]
+        
+        // move set away
+        pongSet := g.claimDiscard(TileSet{ Kind: "triple", Tiles: pong+pong+pong }, pongTiles)
+        g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, pongSet)
+        g.Hands[curState.Player].RevealedSets++
 
-        g.OutputLog.Printf("player %d reveals pong comprising %s\n", curState.Player, pongSet.Tiles)
+        g.LogAction(curState.Player, "pong", pongTiles, "", fmt.Sprintf("player %d reveals pong comprising %s", curState.Player, pongSet.Tiles))
 
         return StateUnit { Player: curState.Player, State: "Discard", Phase: "DrawProcessing" }
       }
@@ -498,14 +815,10 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       fmt.Printf("[vd] Player %d: No pong at this time; moving on to next player.\n", curState.Player)
     }
     
-    if (curState.Player + 1) % 4 == g.Discard[len(g.Discard)-1].Player {
-      return StateUnit { Player: (curState.Player + 2) % 4, State: "HaveSeq", Phase: "DiscardProcessing" }
-    } else {
-      return StateUnit { Player: (curState.Player + 1) % 4, State: "HavePong", Phase: "DiscardProcessing" }
-    }
+    return g.nextClaimState(curState, "pong")
   } else if curState.State == "HaveSeq" && curState.Phase == "DiscardProcessing" {
     relationship := ""
-    if (g.Discard[len(g.Discard)-1].Player + 1) % 4 == curState.Player {
+    if (g.Discard[len(g.Discard)-1].Player + 1) % g.Rules.Players == curState.Player {
       relationship = "previous"
     } else {
       relationship = "other"
@@ -517,39 +830,37 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       if !g.Hands[curState.Player].ComputerPlayer {
         g.handToPlayer(curState.Player)
         g.ShowGameState(false, curState.Player, true)
-      
-        fmt.Printf("Player %d: Using the most recent discard %v, you can form the following sequence(s): Do you take it, if so, which? (#) [n]\n", curState.Player, g.Discard[len(g.Discard)-1].Item.Ud)
-      
-        for i := 0; i < len(seqOptions); i++ {
-          fmt.Printf("Option %d: Set of 3: %v\n", i, seqOptions[i].Tiles)
-        }      
-        fmt.Scanln(&input)
+        
+        if taken, option := g.promptOption(curState.Player, "seq", fmt.Sprintf("Player %d: Using the most recent discard %v, you can form the following sequence(s): Do you take it, if so, which?", curState.Player, g.Discard[len(g.Discard)-1].Item.Ud), seqOptions); taken {
+          input = strconv.Itoa(option)
+        } else {
+          input = "n"
+        }
       } else {
         input = g.Hands[curState.Player].TakeSeq(g.Discard, true, g.Hands, seqOptions)
       }
       
       selection, _ := strconv.Atoi(input)
     
-      if input != "n" && (selection >= 0 || selection < len(seqOptions)) {        
-        // move set away
-        g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, seqOptions[selection])
-        g.Hands[curState.Player].RevealedSets++
-        
+      if input != "n" && selection >= 0 && selection < len(seqOptions) {        
         // remove tiles from hand
+        seqTiles := []Tile{ g.Discard[len(g.Discard)-1].Item }
         for _, runeValue := range seqOptions[selection].Tiles {
           counter := 0
-          for i := 0; i < 14 && counter < 1; i++ {
+          for i := 0; i < len(g.Hands[curState.Player].Hidden) && counter < 1; i++ {
             if g.Hands[curState.Player].Hidden[i].Ud == string(runeValue) && string(runeValue) != g.Discard[len(g.Discard)-1].Item.Ud {
+              seqTiles = append(seqTiles, g.Hands[curState.Player].Hidden[i])
               g.Hands[curState.Player].Hidden[i] = EmptyTile
               counter++
             }
           }
         }
         
-        // remove last discard
-        g.Discard = g.Discard[:len(g.Discard)-1]
+        // move set away
+        g.Hands[curState.Player].RevealedTileSets = append(g.Hands[curState.Player].RevealedTileSets, g.claimDiscard(seqOptions[selection], seqTiles))
+        g.Hands[curState.Player].RevealedSets++
         
-        g.OutputLog.Printf("player %d reveals seq comprising %s\n", curState.Player, seqOptions[selection].Tiles)
+        g.LogAction(curState.Player, "seq", seqTiles, "", fmt.Sprintf("player %d reveals seq comprising %s", curState.Player, seqOptions[selection].Tiles))
         
         return StateUnit { Player: curState.Player, State: "Discard", Phase: "DrawProcessing" }
       }
@@ -559,11 +870,13 @@ func (g *Game) processState(curState StateUnit) StateUnit {
       fmt.Printf("[vd] Player %d: No seq at this time; moving on to next player.\n", curState.Player)
     }
     
-    return StateUnit { Player: curState.Player, State: "DrawTile", Phase: "DrawProcessing" }
+    return g.nextClaimState(curState, "chow")
+  } else if curState.State == "Charleston" && curState.Phase == "PassProcessing" {
+    return g.processCharleston(curState)
   } else {
     fmt.Printf("Unknown state: %v", curState)
     // default outcome for a missing state
-    return StateUnit { Player: curState.Player, State: "DrawGame", Phase: "DrawProcessing" } 
+    return g.drawGame(curState.Player, fmt.Sprintf("unknown state %v", curState))
   }
 }
 
@@ -0,0 +1,403 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// full-screen terminal interface with keyboard tile selection
+package mahjong
+
+import(
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "os/exec"
+  "strings"
+  "unicode/utf8"
+)
+
+// terminal interface state
+type TerminalUi struct {
+  // key source
+  In io.Reader
+  // terminal whose settings are changed while keys are read; nil when keys do not come from a terminal
+  Terminal *os.File
+  // screen output
+  Out io.Writer
+  // terminal settings saved by Start, to be restored by Stop; empty while the terminal is unchanged
+  saved string
+  // bytes read but not yet decoded, e.g., keys typed ahead or pasted
+  pending []byte
+}
+
+// hotkeys for each claim
+var ClaimHotkeys map[string]string
+
+// labels for the opponent panels, relative to the viewing player
+var RelativeSeatLabels []string
+
+func init() {
+  ClaimHotkeys = make(map[string]string)
+  ClaimHotkeys["win"] = "w"
+  ClaimHotkeys["kong"] = "k"
+  ClaimHotkeys["pong"] = "p"
+  ClaimHotkeys["seq"] = "c"
+  ClaimHotkeys["discard"] = "d"
+  ClaimHotkeys["riichi"] = "r"
+  ClaimHotkeys["charleston"] = "y"
+
+  RelativeSeatLabels = []string {"you", "right", "across", "left"}
+}
+
+func NewTerminalUi() *TerminalUi {
+  return &TerminalUi{ In: os.Stdin, Terminal: os.Stdin, Out: os.Stdout }
+}
+
+// run stty on the terminal; stty is used so that no dependencies are needed
+func (t *TerminalUi) stty(args ...string) (string, error) {
+  cmd := exec.Command("stty", args...)
+  cmd.Stdin = t.Terminal
+  output, err := cmd.Output()
+  return strings.TrimSpace(string(output)), err
+}
+
+// save the terminal settings, then read each key as it is pressed, without echo, until Stop;
+// output is processed as usual, and ctrl-c is read as a key so that the settings are restored
+func (t *TerminalUi) Start() {
+  if t.Terminal == nil || t.saved != "" {
+    return
+  }
+  saved, err := t.stty("-g")
+  if err != nil || saved == "" {
+    return
+  }
+  if _, err := t.stty("-icanon", "-echo", "-isig", "min", "1", "time", "0"); err != nil {
+    return
+  }
+  t.saved = saved
+}
+
+// restore the terminal settings saved by Start
+func (t *TerminalUi) Stop() {
+  if t.saved == "" {
+    return
+  }
+  t.stty(t.saved)
+  t.saved = ""
+}
+
+// wait for a single key press; keys already read are taken first
+// without a terminal, input comes a line at a time, so the newline ending a key's line is dropped
+func (t *TerminalUi) ReadKey() string {
+  t.Start()
+  if len(t.pending) == 0 {
+    buffer := make([]byte, 64)
+    n, err := t.In.Read(buffer)
+    if n == 0 && err != nil {
+      t.Stop()
+      log.Fatal(err)
+    }
+    t.pending = append(t.pending, buffer[:n]...)
+  }
+
+  key, length := nextKey(t.pending)
+  t.pending = t.pending[length:]
+  if t.saved == "" && key != "enter" && len(t.pending) > 0 && t.pending[0] == '\n' {
+    t.pending = t.pending[1:]
+  }
+  if key == "interrupt" {
+    t.Stop()
+    log.Fatal("game interrupted at the keyboard")
+  }
+  return key
+}
+
+// translate raw terminal input into the name of its first key
+func decodeKey(input []byte) string {
+  key, _ := nextKey(input)
+  return key
+}
+
+// name of the first key of raw terminal input, and how many bytes it takes
+func nextKey(input []byte) (string, int) {
+  if len(input) == 0 {
+    return "", 0
+  }
+
+  if input[0] == 27 {
+    if len(input) >= 3 && (input[1] == '[' || input[1] == 'O') {
+      switch input[2] {
+        case 'A':
+          return "up", 3
+        case 'B':
+          return "down", 3
+        case 'C':
+          return "right", 3
+        case 'D':
+          return "left", 3
+      }
+      // other sequences, e.g., ESC [ 3 ~, run to their final byte
+      for i := 2; i < len(input); i++ {
+        if input[i] >= 0x40 && input[i] <= 0x7e {
+          return "", i+1
+        }
+      }
+      return "", len(input)
+    }
+    return "escape", 1
+  }
+
+  switch input[0] {
+    case '\r', '\n', ' ':
+      return "enter", 1
+    case 3:
+      return "interrupt", 1
+    case 'h':
+      return "left", 1
+    case 'l':
+      return "right", 1
+  }
+
+  r, length := utf8.DecodeRune(input)
+  return strings.ToLower(string(r)), length
+}
+
+// move the cursor to the next occupied hand position in the given direction, wrapping at the ends
+func moveCursor(hidden []Tile, cursor int, step int) int {
+  for i := 1; i <= len(hidden); i++ {
+    position := ((cursor+step*i)%len(hidden) + len(hidden)) % len(hidden)
+    if hidden[position] != EmptyTile {
+      return position
+    }
+  }
+  return cursor
+}
+
+// number of occupied positions
+func occupiedCount(tiles []Tile) int {
+  count := 0
+  for _, tile := range tiles {
+    if tile != EmptyTile {
+      count++
+    }
+  }
+  return count
+}
+
+// clear the screen and wait for a key press
+func (t *TerminalUi) Announce(message string) {
+  fmt.Fprintf(t.Out, "\u001b[2J\u001b[H%s\n\nPress any key to continue.\n", message)
+  t.ReadKey()
+}
+
+// let the player move a cursor over the hidden tiles and discard with enter
+func (t *TerminalUi) ChooseDiscard(g *Game, player int, suggestion int) int {
+  return t.chooseTile(g, player, suggestion, "choose a discard", "discard")
+}
+
+// let the player move a cursor over the hidden tiles and pick one to pass in the charleston
+func (t *TerminalUi) ChoosePass(g *Game, player int, direction string, suggestion int) int {
+  return t.chooseTile(g, player, suggestion, fmt.Sprintf("choose a tile to pass %s", direction), "pass")
+}
+
+// move a cursor over the hidden tiles until one is chosen with enter
+func (t *TerminalUi) chooseTile(g *Game, player int, suggestion int, prompt string, action string) int {
+  hidden := g.Hands[player].Hidden
+  // a suggestion outside the hand or at an empty slot is ignored
+  validSuggestion := suggestion >= 0 && suggestion < len(hidden) && hidden[suggestion] != EmptyTile
+  cursor := suggestion
+  if !validSuggestion {
+    cursor = moveCursor(hidden, -1, 1)
+  }
+
+  suggestedTile := "-"
+  if validSuggestion {
+    suggestedTile = hidden[suggestion].Ud
+  }
+
+  for {
+    footer := []string {
+      fmt.Sprintf("Player %d: %s (suggested: %v)", player, prompt, suggestedTile),
+      fmt.Sprintf("[←/→] move  [enter] %s  [s] suggested tile", action),
+    }
+    fmt.Fprint(t.Out, g.RenderTable(player, cursor, footer))
+
+    switch t.ReadKey() {
+      case "left":
+        cursor = moveCursor(hidden, cursor, -1)
+      case "right":
+        cursor = moveCursor(hidden, cursor, 1)
+      case "s":
+        if validSuggestion {
+          cursor = suggestion
+        }
+      case "enter":
+        return cursor
+    }
+  }
+}
+
+// offer a claim; the claim's hotkey (or enter) accepts, n or escape passes
+// options, if any, are selected with the arrow keys
+func (t *TerminalUi) ChooseClaim(g *Game, player int, claim string, message string, options []TileSet) (bool, int) {
+  hotkey := ClaimHotkeys[claim]
+  selected := 0
+
+  for {
+    footer := []string { message }
+    if len(options) > 0 {
+      optionLine := ""
+      for i, option := range options {
+        if i == selected {
+          optionLine += fmt.Sprintf(" \u001b[7m[%s]\u001b[0m", option.Tiles)
+        } else {
+          optionLine += fmt.Sprintf("  %s ", option.Tiles)
+        }
+      }
+      footer = append(footer, "Options:"+optionLine)
+      footer = append(footer, fmt.Sprintf("[←/→] choose  [%s/enter] %s  [n/esc] pass", hotkey, claim))
+    } else {
+      footer = append(footer, fmt.Sprintf("[%s/enter] %s  [n/esc] pass", hotkey, claim))
+    }
+    fmt.Fprint(t.Out, g.RenderTable(player, -1, footer))
+
+    switch key := t.ReadKey(); key {
+      case "left":
+        if len(options) > 0 {
+          selected = (selected + len(options) - 1) % len(options)
+        }
+      case "right":
+        if len(options) > 0 {
+          selected = (selected + 1) % len(options)
+        }
+      case "enter", hotkey:
+        return true, selected
+      case "n", "escape":
+        return false, 0
+    }
+  }
+}
+
+// label of the seat at an offset from the player; at a three-player table the second seat is on the left
+func relativeSeatLabel(offset int, players int) string {
+  if offset > 0 && offset == players-1 {
+    return RelativeSeatLabels[len(RelativeSeatLabels)-1]
+  }
+  return RelativeSeatLabels[offset]
+}
+
+// render a player's public tiles: special tiles and revealed sets
+func (h PlayerHand) publicTiles() string {
+  line := ""
+  for _, tile := range h.Revealed {
+    if tile != EmptyTile {
+      line += tile.Ud
+    }
+  }
+  if len(line) == 0 {
+    line = "-"
+  }
+
+  line += "  sets: "
+  if h.RevealedSets == 0 {
+    line += "-"
+  }
+  for i := 0; i < h.RevealedSets; i++ {
+    if i > 0 {
+      line += " "
+    }
+    line += h.RevealedTileSets[i].Display(h.Player, h.ruleSet().Players)
+  }
+  return line
+}
+
+// render the table from a player's point of view; a negative cursor hides the cursor
+func (g *Game) RenderTable(player int, cursor int, footer []string) string {
+  var screen strings.Builder
+
+  fmt.Fprintf(&screen, "\u001b[2J\u001b[H")
+  fmt.Fprintf(&screen, "═══ Mah Jong ═══ %s ═══ %d new tiles remain\n", g.RoundLabel(), g.UndealtTileCount)
+  if g.ShowWall {
+    for _, line := range g.Wall().Render(player) {
+      fmt.Fprintf(&screen, "%s\n", line)
+    }
+  }
+  for _, line := range g.RiichiLines() {
+    fmt.Fprintf(&screen, "%s\n", line)
+  }
+  fmt.Fprintf(&screen, "\n")
+
+  // opponent panels
+  for offset := 1; offset < g.Rules.Players; offset++ {
+    opponent := (player+offset)%g.Rules.Players
+    h := g.Hands[opponent]
+    fmt.Fprintf(&screen, "┌ P%d (%s) %s ─ %d hidden\n", opponent, relativeSeatLabel(offset, g.Rules.Players), g.SeatLabel(opponent), occupiedCount(h.Hidden))
+    fmt.Fprintf(&screen, "│ special: %s\n", h.publicTiles())
+  }
+  fmt.Fprintf(&screen, "\n")
+
+  // discard river, attributed to each player
+  fmt.Fprintf(&screen, "┌ Discard river\n")
+  for offset := 0; offset < g.Rules.Players; offset++ {
+    discarder := (player+offset)%g.Rules.Players
+    line := ""
+    for _, d := range g.Discard {
+      if d.Player == discarder && d.Claimed {
+        line += "["+d.Item.Ud+"]"
+      } else if d.Player == discarder {
+        line += d.Item.Ud
+      }
+    }
+    fmt.Fprintf(&screen, "│ P%d %-6s %s\n", discarder, relativeSeatLabel(offset, g.Rules.Players), line)
+  }
+  if len(g.Discard) > 0 {
+    last := g.Discard[len(g.Discard)-1]
+    fmt.Fprintf(&screen, "│ latest: %s from P%d\n", last.Item.Ud, last.Player)
+  }
+  fmt.Fprintf(&screen, "\n")
+
+  // own hand
+  h := g.Hands[player]
+  fmt.Fprintf(&screen, "┌ P%d (you) %s\n", player, g.SeatLabel(player))
+  fmt.Fprintf(&screen, "│ special: %s\n", h.publicTiles())
+  handLine := ""
+  for i, tile := range h.Hidden {
+    if tile == EmptyTile {
+      continue
+    }
+    if i == cursor {
+      handLine += fmt.Sprintf("\u001b[7m[%s]\u001b[0m", tile.Ud)
+    } else {
+      handLine += fmt.Sprintf(" %s ", tile.Ud)
+    }
+  }
+  fmt.Fprintf(&screen, "│ hidden: %s\n", handLine)
+  if h.LastNewTile != EmptyTile {
+    fmt.Fprintf(&screen, "│ new tile: %s\n", h.LastNewTile.Ud)
+  }
+  if g.Assist {
+    for _, line := range g.ReadyHandLines(player) {
+      fmt.Fprintf(&screen, "│ %s\n", line)
+    }
+  }
+  fmt.Fprintf(&screen, "\n")
+
+  for _, line := range footer {
+    fmt.Fprintf(&screen, "%s\n", line)
+  }
+
+  return screen.String()
+}
@@ -0,0 +1,99 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// assist mode: ready-hand (tenpai) waits and discard warnings for human players
+package mahjong
+
+import(
+  "fmt"
+)
+
+// list waits with the copies not yet seen by the player
+func formatWaits(waits []WaitingTile) string {
+  if len(waits) == 0 {
+    return "no winning tile remains"
+  }
+  line := ""
+  for i, wait := range waits {
+    if i > 0 {
+      line += " "
+    }
+    line += fmt.Sprintf("%s×%d", wait.Item.Ud, wait.Live)
+  }
+  return line
+}
+
+// ready-hand summary for a player; unseen counts exclude the discards, all revealed sets and the player's own hand
+func (g *Game) ReadyHandLines(player int) []string {
+  lines := make([]string, 0, 4)
+  h := g.Hands[player]
+  unseen := h.UnseenTileCounts(g.Discard, g.Hands)
+  tileCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+
+  if totalTileCount(tileCounts) % 3 == 1 {
+    // awaiting a tile
+    if g.Rules.Shanten(tileCounts, h.RevealedSets) == 0 {
+      lines = append(lines, fmt.Sprintf("P%d-W: ready, waiting on %s", player, formatWaits(h.Waits(unseen))))
+    }
+    return lines
+  }
+
+  // awaiting a discard: which discards keep the hand ready?
+  for _, option := range h.DiscardOptions(unseen) {
+    if option.Shanten != 0 {
+      break
+    }
+    remaining := h
+    remaining.Hidden = withoutTile(h.Hidden, option.Item)
+    lines = append(lines, fmt.Sprintf("P%d-W: discard %s to wait on %s", player, option.Item.Ud, formatWaits(remaining.Waits(unseen))))
+  }
+  return lines
+}
+
+// copy of the tiles with one copy of the given tile removed
+func withoutTile(tiles []Tile, removal Tile) []Tile {
+  remaining := make([]Tile, 0, len(tiles))
+  removed := false
+  for _, t := range tiles {
+    if !removed && t.Suit == removal.Suit && t.Value == removal.Value {
+      removed = true
+      continue
+    }
+    remaining = append(remaining, t)
+  }
+  return remaining
+}
+
+// determine if discarding the tile at a hand position gives up a ready hand that another discard would keep
+// also returns the discards that would keep the hand ready
+func (g *Game) BreaksReadyHand(player int, position int) (bool, string) {
+  h := g.Hands[player]
+  unseen := h.UnseenTileCounts(g.Discard, g.Hands)
+  discarded := h.Hidden[position]
+
+  keepReady := ""
+  breaks := false
+  for _, option := range h.DiscardOptions(unseen) {
+    if option.Shanten == 0 {
+      keepReady += option.Item.Ud
+    } else if option.Item.Suit == discarded.Suit && option.Item.Value == discarded.Value {
+      breaks = true
+    }
+  }
+  return breaks && len(keepReady) > 0, keepReady
+}
@@ -20,6 +20,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package mahjong
 
 import(
+  "fmt"
   "log"
 )
 
@@ -45,72 +46,103 @@ type Game struct {
   // # playerOps
   // player state
   Hands []PlayerHand
+  // player names by seat, when seated by a session
+  Names []string
+  // discard strategies of the computer players by seat, when seated by a session
+  Strategies []string
 
   // # stateMachineOps
   // current player
   CurrentPlayer int
   // dealer
   StartPlayer int
+  // passes of the charleston completed
+  CharlestonPass int
   
   // # throughout
   // output log
   OutputLog *log.Logger
+  // output log format: text or json
+  LogFormat string
+  // every action logged, whether or not an output log is set
+  Events []GameAction
+  // identifier included with each json log entry
+  GameId string
+  // sum of the three dice used to set the deal locations
+  DiceRoll int
+  // full-screen terminal interface; nil for line-based prompts
+  Tui *TerminalUi
+  // show ready-hand waits and warn before breaking a ready hand
+  Assist bool
+  // draw the walls around the table with the game state
+  ShowWall bool
+  // rules of play
+  Rules RuleSet
+  // honor value of the prevailing wind (1 east to 4 north)
+  PrevailingWind int
+  // seat of the session's first east, from which the hands of each round are counted
+  FirstEast int
+  // bonus sticks on the table from kept deals and drawn games
+  BonusSticks int
+  // score of the winning hand, once taken
+  Win ScoredWin
+  // seat of the winner; -1 until a win is taken
+  Winner int
+  // seat of the player whose discard, or tile added to a kong, completed the win; -1 when self-drawn
+  DealtIn int
+  // how a game without a winner ended, e.g., the live wall being exhausted
+  DrawReason string
+  // riichi sticks deposited on the table
+  RiichiSticks int
+  // kongs declared by all players
+  KongCount int
+  // tile added to a revealed triple to make a kong, while other players may rob it; empty otherwise
+  KongTile Tile
+  // player who added the tile
+  KongPlayer int
+  // the current player's tiles this turn have come from the replacement pointer after a kong
+  KongReplacement bool
 }
 
 func New() *Game {
-  return &Game{}
+  rules, _ := PresetRules(DefaultRuleSet)
+  return &Game{ Rules: rules, PrevailingWind: 1, Winner: -1, DealtIn: -1 }
+}
+
+// name of the player at a seat
+func (g *Game) PlayerName(player int) string {
+  if player >= 0 && player < len(g.Names) {
+    return g.Names[player]
+  }
+  return PlaceholderName(player)
 }
 
 // per game init
 func (g *Game) Initialize(dealer int, computerPlayers []bool) {
+  if g.GameId == "" {
+    g.GameId = NewGameId()
+  }
+  
   // # tileCollection
-  g.UndealtTileCount = TilesInGame
+  tileCount := g.Rules.TileCount()
+  g.UndealtTileCount = tileCount
   g.ReplacementPointer = -1 // to be initialized later
   g.DrawPointer = -1 // to be initialized later
   
   // allocate tile set
-  g.Undealt = make([]Tile, TilesInGame, TilesInGame)
-  // keep track of current tile
-  p := 0 // position in tileCollection slice
-  // create tiles for the standard suits and honor suit
-  // TODO: clean magic numbers
-  for i := 1; i <= 4; i++ {
-    for j:= 1; j < 10; j++ {
-      for k:= 0; k < 4; k++ {
-        t := Tile { 
-          Suit: i, 
-          Value: j, 
-          Id: (i-1)*36+(j-1)*4+k+1, 
-          Ud: UnicodeDisplay[i-1][j] }
-        g.Undealt[p] = t
-        p++
-      }
-      if i == 4 && j == 7 { // bail out for honor suit
-        break
-      }
-    }
-  }
-
-  // create tiles for the bonus suit
-  for i := 5; i <= 5; i++ {
-    for j:= 1; j <= 8; j++ {
-      t := Tile{ 
-          Suit: i, 
-          Value: j, 
-          Id: 3*36+7*4+j,
-          Ud: UnicodeDisplay[i-1][j] }
-      g.Undealt[p] = t
-      p++
-    }
-  }
+  g.Undealt = g.Rules.Tiles()
   
   // # playerOps
-  g.Hands = make([]PlayerHand, PlayersInGame, PlayersInGame)
-  for i := 0; i < PlayersInGame; i++ {
-    g.Hands[i].Hidden = make([]Tile, 14, 14)
-    g.Hands[i].Revealed = make([]Tile, 8, 8)
+  g.Hands = make([]PlayerHand, g.Rules.Players, g.Rules.Players)
+  for i := 0; i < g.Rules.Players; i++ {
+    g.Hands[i].Hidden = make([]Tile, g.Rules.HandSize+1, g.Rules.HandSize+1)
+    g.Hands[i].Revealed = make([]Tile, g.Rules.BonusTileCount(), g.Rules.BonusTileCount())
     g.Hands[i].Player = i
     g.Hands[i].ComputerPlayer = computerPlayers[i]
+    if i < len(g.Strategies) {
+      g.Hands[i].Strategy = g.Strategies[i]
+    }
+    g.Hands[i].Rules = &g.Rules
   }
 
   // # stateMachineOps
@@ -133,7 +165,7 @@ func (g *Game) Initialize(dealer int, computerPlayers []bool) {
     
   // simulate dice roll to set deal locations
   diceRoll := RollOneDice(DeterministicRand)+RollOneDice(DeterministicRand)+RollOneDice(DeterministicRand)
-  g.OutputLog.Println("dice roll:", diceRoll)
+  g.DiceRoll = diceRoll
   
   err = g.SetDealLocations(diceRoll)
   if err != nil {
@@ -141,10 +173,12 @@ func (g *Game) Initialize(dealer int, computerPlayers []bool) {
   }
   
   if dealer == -1 {
-    g.CurrentPlayer = (diceRoll-1) % 4
+    g.CurrentPlayer = (diceRoll-1) % g.Rules.Players
     g.StartPlayer = g.CurrentPlayer
   }
   
+  g.LogAction(-1, "dice", nil, "", fmt.Sprintf("dice roll: %d", diceRoll))
+  
   // deal initial set of tiles
   g.InitialDeal()
 
@@ -153,7 +187,7 @@ func (g *Game) Initialize(dealer int, computerPlayers []bool) {
   
   // dump hands
   if VerboseDebug {
-    for i := 0; i < 4; i++ {
+    for i := 0; i < g.Rules.Players; i++ {
       g.Hands[i].OutputHand(false, true) // do not show hidden
       //mahjong.Hands[i].OutputHand(true, true) // show hidden
     }    
@@ -0,0 +1,140 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// invariant checks run after each state transition
+package mahjong
+
+import(
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// check tile conservation, hand sizes and wall pointers after every state transition
+var AuditMode bool
+
+func init() {
+  AuditMode = false
+}
+
+// every tile of the rules must be in exactly one place: the wall, a hand, a revealed set or the discard pile
+// claimed discards are not counted, as the tile is held by the set that claimed it
+func (g *Game) Audit() error {
+  var problems []string
+
+  locations := make(map[int][]string)
+  record := func(t Tile, location string) {
+    if t != EmptyTile {
+      locations[t.Id] = append(locations[t.Id], location)
+    }
+  }
+
+  for i, t := range g.Undealt {
+    record(t, fmt.Sprintf("wall %d", i))
+  }
+  for _, h := range g.Hands {
+    for i, t := range h.Hidden {
+      record(t, fmt.Sprintf("P%d hidden %d", h.Player, i))
+    }
+    for i, t := range h.Revealed {
+      record(t, fmt.Sprintf("P%d special %d", h.Player, i))
+    }
+    for i := 0; i < h.RevealedSets && i < len(h.RevealedTileSets); i++ {
+      for _, t := range h.RevealedTileSets[i].UnderlyingTiles {
+        record(t, fmt.Sprintf("P%d set %d %s", h.Player, i, h.RevealedTileSets[i].Tiles))
+      }
+    }
+  }
+  for i, d := range g.Discard {
+    if !d.Claimed {
+      record(d.Item, fmt.Sprintf("discard %d", i))
+    }
+  }
+
+  expected := make(map[int]Tile)
+  for _, t := range g.Rules.Tiles() {
+    expected[t.Id] = t
+    switch len(locations[t.Id]) {
+      case 0:
+        problems = append(problems, fmt.Sprintf("- %v missing", t))
+      case 1:
+      default:
+        problems = append(problems, fmt.Sprintf("+ %v held %d times: %s", t, len(locations[t.Id]), strings.Join(locations[t.Id], ", ")))
+    }
+  }
+  for id, places := range locations {
+    if _, ok := expected[id]; !ok {
+      problems = append(problems, fmt.Sprintf("+ unknown tile %d at %s", id, strings.Join(places, ", ")))
+    }
+  }
+
+  problems = append(problems, g.auditHands()...)
+  problems = append(problems, g.auditWall()...)
+
+  if len(problems) == 0 {
+    return nil
+  }
+  sort.Strings(problems)
+  return errors.New("audit failed:\n"+strings.Join(problems, "\n"))
+}
+
+// hidden tiles make up the hand size, or one more, once each revealed set is counted as three
+func (g *Game) auditHands() []string {
+  var problems []string
+  for _, h := range g.Hands {
+    tiles := occupiedCount(h.Hidden) + 3*h.RevealedSets
+    if tiles != g.Rules.HandSize && tiles != g.Rules.HandSize+1 {
+      problems = append(problems, fmt.Sprintf("! P%d holds %d hidden tiles with %d revealed sets; expected %d or %d in all, not %d", h.Player, occupiedCount(h.Hidden), h.RevealedSets, g.Rules.HandSize, g.Rules.HandSize+1, tiles))
+    }
+    if h.RevealedSets > len(h.RevealedTileSets) {
+      problems = append(problems, fmt.Sprintf("! P%d counts %d revealed sets but has %d", h.Player, h.RevealedSets, len(h.RevealedTileSets)))
+    }
+  }
+  return problems
+}
+
+// the tiles left in the wall run, without gaps, from the draw pointer forward to the replacement pointer
+func (g *Game) auditWall() []string {
+  var problems []string
+  if !g.DrawLocationsSet || len(g.Undealt) == 0 {
+    return problems
+  }
+
+  n := len(g.Undealt)
+  if remaining := occupiedCount(g.Undealt); remaining != g.UndealtTileCount {
+    problems = append(problems, fmt.Sprintf("! the wall holds %d tiles but the count is %d", remaining, g.UndealtTileCount))
+  }
+
+  draw := (g.DrawPointer%n + n) % n
+  replacement := (g.ReplacementPointer%n + n) % n
+  span := (replacement - draw + n) % n + 1
+  if g.UndealtTileCount == 0 {
+    span = (replacement + 1 - draw + n) % n
+  }
+  if span != g.UndealtTileCount {
+    problems = append(problems, fmt.Sprintf("! draw pointer %d and replacement pointer %d span %d positions for %d tiles", g.DrawPointer, g.ReplacementPointer, span, g.UndealtTileCount))
+  }
+  for i := 0; i < g.UndealtTileCount && i < n; i++ {
+    if g.Undealt[(draw+i)%n] == EmptyTile {
+      problems = append(problems, fmt.Sprintf("! wall position %d, between the draw and replacement pointers, is empty", (draw+i)%n))
+      break
+    }
+  }
+  return problems
+}
@@ -0,0 +1,99 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// game action logging in text or json form
+package mahjong
+
+import(
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "time"
+)
+
+const (
+  LogFormatText = "text"
+  LogFormatJson = "json"
+)
+
+// one logged action; game-level actions use a seat of -1
+type GameAction struct {
+  Timestamp string `json:"timestamp"`
+  GameId string `json:"gameId"`
+  Seat int `json:"seat"`
+  Action string `json:"action"`
+  TileIds []int `json:"tileIds"`
+  Tiles []string `json:"tiles"`
+  WallCount int `json:"wallCount"`
+  DiceRoll int `json:"diceRoll"`
+  DrawPointer int `json:"drawPointer"`
+  ReplacementPointer int `json:"replacementPointer"`
+  Detail string `json:"detail,omitempty"`
+}
+
+// random identifier to correlate the actions of a game
+func NewGameId() string {
+  b := make([]byte, 8)
+  if _, err := rand.Read(b); err != nil {
+    return time.Now().UTC().Format("20060102150405.000000000")
+  }
+  return hex.EncodeToString(b)
+}
+
+// log an action; in text mode only the message is written (and nothing if it is empty)
+// every action is also kept in the game's event record
+func (g *Game) LogAction(seat int, action string, tiles []Tile, detail string, message string) {
+  entry := GameAction {
+    Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+    GameId: g.GameId,
+    Seat: seat,
+    Action: action,
+    TileIds: make([]int, 0, len(tiles)),
+    Tiles: make([]string, 0, len(tiles)),
+    WallCount: g.UndealtTileCount,
+    DiceRoll: g.DiceRoll,
+    DrawPointer: g.DrawPointer,
+    ReplacementPointer: g.ReplacementPointer,
+    Detail: detail,
+  }
+  for _, tile := range tiles {
+    if tile != EmptyTile {
+      entry.TileIds = append(entry.TileIds, tile.Id)
+      entry.Tiles = append(entry.Tiles, tile.Notation())
+    }
+  }
+  g.Events = append(g.Events, entry)
+
+  if g.OutputLog == nil {
+    return
+  }
+
+  if g.LogFormat != LogFormatJson {
+    if len(message) > 0 {
+      g.OutputLog.Println(message)
+    }
+    return
+  }
+
+  encoded, err := json.Marshal(entry)
+  if err != nil {
+    g.OutputLog.Println(err)
+    return
+  }
+  g.OutputLog.Println(string(encoded))
+}
@@ -0,0 +1,493 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// scoring of winning hands; scoring systems are registered by name and chosen by the rule set
+package mahjong
+
+import(
+  "fmt"
+  "strings"
+)
+
+// everything a scoring system needs to know about a win
+type WinContext struct {
+  Hand PlayerHand
+  // tile added to the hidden tiles (the claimed discard); empty for a self-drawn win
+  Consider Tile
+  // tile completing the hand
+  WinningTile Tile
+  // draw, previous or other, as for HaveWin
+  Source string
+  // honor values: 1 east, 2 south, 3 west, 4 north
+  SeatWind int
+  PrevailingWind int
+  Rules RuleSet
+  // revealed dora indicators and, for a riichi hand, the indicators beneath them
+  DoraIndicators []Tile
+  UraIndicators []Tile
+  // won on the last tile of the live wall
+  LastTile bool
+  // every other copy of the winning tile is visible
+  LastCopy bool
+  // won with the tile another player added to a revealed triple to make a kong
+  RobbedKong bool
+  // won with a tile drawn to replace a kong
+  KongReplacement bool
+}
+
+// one scoring element
+type ScoredPattern struct {
+  Name string
+  Points int
+  // counts towards the score but not towards the minimum (e.g., dora)
+  Bonus bool
+}
+
+// score of a winning hand
+type ScoredWin struct {
+  Scoring string
+  // unit of the points (e.g., faan)
+  Unit string
+  Points int
+  Patterns []ScoredPattern
+  // points were capped at the limit
+  Limit bool
+  // riichi: minipoints, basic points before payment multipliers and the limit reached, if any
+  Fu int
+  BasePoints int
+  LimitName string
+}
+
+// set of a hand reading
+type readingSet struct {
+  // seq, triple, kong or eye
+  Kind string
+  Suit int
+  // value of the first tile
+  Value int
+  // claimed from a discard (or, for a triple, completed by one)
+  Open bool
+}
+
+// one way of reading a winning hand: its sets, eye and the wait completed by the winning tile
+type handReading struct {
+  Sets []readingSet
+  EyeSuit int
+  EyeValue int
+  // ryanmen, kanchan, penchan, shanpon, tanki or empty if unknown
+  Wait string
+}
+
+// scoring system: score the best reading of a winning hand
+type ScoreFunc func(w WinContext) ScoredWin
+
+// scoring systems by name
+var Scorers map[string]ScoreFunc
+
+const (
+  // hong kong limit hand
+  HongKongLimit = 13
+)
+
+func init() {
+  Scorers = make(map[string]ScoreFunc)
+  Scorers["hongkong"] = scoreHongKong
+}
+
+// human readable summary (e.g., 4 faan: self-drawn 1, mixed one suit 3)
+func (s ScoredWin) String() string {
+  parts := make([]string, 0, len(s.Patterns))
+  for _, pattern := range s.Patterns {
+    parts = append(parts, fmt.Sprintf("%s %d", pattern.Name, pattern.Points))
+  }
+  summary := fmt.Sprintf("%d %s", s.Points, s.Unit)
+  if s.Fu > 0 {
+    summary += fmt.Sprintf(" %d fu", s.Fu)
+  }
+  if s.LimitName != "" {
+    summary += fmt.Sprintf(" (%s)", s.LimitName)
+  } else if s.Limit {
+    summary += " (limit)"
+  }
+  if len(parts) > 0 {
+    summary += ": " + strings.Join(parts, ", ")
+  }
+  return summary
+}
+
+// add a pattern to the score
+func (s *ScoredWin) add(name string, points int) {
+  s.Patterns = append(s.Patterns, ScoredPattern{ Name: name, Points: points })
+  s.Points += points
+}
+
+// add a pattern counting towards the score only
+func (s *ScoredWin) addBonus(name string, points int) {
+  s.Patterns = append(s.Patterns, ScoredPattern{ Name: name, Points: points, Bonus: true })
+  s.Points += points
+}
+
+// points counting towards the rule set's minimum
+func (s ScoredWin) QualifyingPoints() int {
+  points := s.Points
+  for _, pattern := range s.Patterns {
+    if pattern.Bonus {
+      points -= pattern.Points
+    }
+  }
+  return points
+}
+
+// score a win under the rule set's scoring system
+func ScoreWin(w WinContext) ScoredWin {
+  scorer, found := Scorers[w.Rules.Scoring]
+  if !found {
+    return ScoredWin{ Scoring: w.Rules.Scoring }
+  }
+  return scorer(w)
+}
+
+// suit and value of the first tile of a set
+func setTile(s TileSet) (int, int) {
+  for _, runeValue := range s.Tiles {
+    return glyphTile(string(runeValue))
+  }
+  return 0, 0
+}
+
+// revealed sets followed by the sets of a decomposition
+func allSets(h PlayerHand, d HandDecomposition) []TileSet {
+  sets := make([]TileSet, 0, 4)
+  sets = append(sets, h.RevealedTileSets[:h.RevealedSets]...)
+  return append(sets, d.Sets...)
+}
+
+// determine if a tile is a terminal (1 or 9) or an honor
+func terminalOrHonor(suit int, value int) bool {
+  return suit == 4 || value == 1 || value == 9
+}
+
+// suit and value of each tile of a set
+func (s readingSet) tiles() [][2]int {
+  tiles := make([][2]int, 0, 4)
+  switch s.Kind {
+    case "seq":
+      for i := 0; i < 3; i++ {
+        tiles = append(tiles, [2]int{ s.Suit, s.Value+i })
+      }
+    case "kong":
+      for i := 0; i < 4; i++ {
+        tiles = append(tiles, [2]int{ s.Suit, s.Value })
+      }
+    case "eye":
+      tiles = append(tiles, [2]int{ s.Suit, s.Value }, [2]int{ s.Suit, s.Value })
+    default:
+      for i := 0; i < 3; i++ {
+        tiles = append(tiles, [2]int{ s.Suit, s.Value })
+      }
+  }
+  return tiles
+}
+
+// pairs of the counted tiles, as eyes; four of a tile are two pairs
+func pairSets(tileCounts [][]int) []readingSet {
+  pairs := make([]readingSet, 0, 7)
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      for k := 0; k < tileCounts[i][j]/2; k++ {
+        pairs = append(pairs, readingSet{ Kind: "eye", Suit: i+1, Value: j })
+      }
+    }
+  }
+  return pairs
+}
+
+// distinct tiles the hand waited on before the winning tile
+func waitingTileCount(w WinContext) int {
+  before := w.Hand
+  if w.Source == "draw" {
+    before.Hidden = withoutTile(w.Hand.Hidden, w.WinningTile)
+  }
+  return len(before.Waits(newTileCounts()))
+}
+
+// every reading of a winning hand, with each set the winning tile could have completed
+func handReadings(w WinContext) []handReading {
+  readings := make([]handReading, 0, 4)
+  revealed := make([]readingSet, 0, 4)
+  for _, set := range w.Hand.RevealedTileSets[:w.Hand.RevealedSets] {
+    suit, value := setTile(set)
+    kind := set.Kind
+    if set.IsKong() {
+      kind = "kong"
+    }
+    revealed = append(revealed, readingSet{ Kind: kind, Suit: suit, Value: value, Open: set.Kind != "concealedKong" })
+  }
+
+  tileCounts, _, _ := w.Hand.CountHiddenTiles(w.Consider)
+  for _, d := range Decompositions(tileCounts) {
+    base := handReading{ Sets: append([]readingSet{}, revealed...) }
+    base.EyeSuit, base.EyeValue = setTile(d.Eye)
+    for _, set := range d.Sets {
+      suit, value := setTile(set)
+      base.Sets = append(base.Sets, readingSet{ Kind: set.Kind, Suit: suit, Value: value })
+    }
+
+    found := false
+    if base.EyeSuit == w.WinningTile.Suit && base.EyeValue == w.WinningTile.Value {
+      reading := base
+      reading.Wait = "tanki"
+      readings = append(readings, reading)
+      found = true
+    }
+    for i := len(revealed); i < len(base.Sets); i++ {
+      set := base.Sets[i]
+      if set.Suit != w.WinningTile.Suit || w.WinningTile.Value < set.Value || (set.Kind != "seq" && w.WinningTile.Value != set.Value) || w.WinningTile.Value > set.Value+2 {
+        continue
+      }
+      reading := base
+      reading.Sets = append([]readingSet{}, base.Sets...)
+      switch {
+        case set.Kind != "seq":
+          reading.Wait = "shanpon"
+          // a triple completed by a discard counts as open
+          reading.Sets[i].Open = w.Source != "draw"
+        case w.WinningTile.Value == set.Value+1:
+          reading.Wait = "kanchan"
+        case (set.Value == 1 && w.WinningTile.Value == 3) || (set.Value == 7 && w.WinningTile.Value == 7):
+          reading.Wait = "penchan"
+        default:
+          reading.Wait = "ryanmen"
+      }
+      readings = append(readings, reading)
+      found = true
+    }
+    if !found {
+      readings = append(readings, base)
+    }
+  }
+  return readings
+}
+
+// norths revealed as bonus tiles count one each towards the score only
+func addNorthBonus(w WinContext, s *ScoredWin) {
+  norths := 0
+  for _, t := range w.Hand.Revealed {
+    if t.Suit == 4 && t.Value == 4 {
+      norths++
+    }
+  }
+  if norths > 0 {
+    s.addBonus("north", norths)
+  }
+}
+
+// hong kong faan for one reading of the hand
+func hongKongPatterns(w WinContext, d HandDecomposition) ScoredWin {
+  s := ScoredWin{ Scoring: "hongkong", Unit: "faan" }
+  sets := allSets(w.Hand, d)
+
+  hongKongWinPatterns(w, &s)
+  if w.Hand.Concealed() {
+    s.add("concealed hand", 1)
+  }
+
+  // bonus tiles: flower and season matching the seat
+  if w.Rules.Flowers {
+    bonusTiles := 0
+    for _, t := range w.Hand.Revealed {
+      if !t.IsSpecial() {
+        continue
+      }
+      bonusTiles++
+      if t.Value == w.SeatWind || t.Value == w.SeatWind+4 {
+        s.add("seat flower", 1)
+      }
+    }
+    if bonusTiles == 0 {
+      s.add("no flowers", 1)
+    }
+  }
+  addNorthBonus(w, &s)
+
+  // set structure
+  sequences, triplets, dragonTriplets := 0, 0, 0
+  suits := make(map[int]bool)
+  eyeSuit, eyeValue := setTile(d.Eye)
+  suits[eyeSuit] = true
+  for _, set := range sets {
+    suit, value := setTile(set)
+    suits[suit] = true
+    if set.Kind == "seq" {
+      sequences++
+      continue
+    }
+    triplets++
+    if suit == 4 && value >= 5 {
+      dragonTriplets++
+    }
+    if suit == 4 && value == w.SeatWind {
+      s.add("seat wind", 1)
+    }
+    if suit == 4 && value == w.PrevailingWind {
+      s.add("prevailing wind", 1)
+    }
+  }
+
+  if sequences == len(sets) {
+    s.add("all sequences", 1)
+  }
+  if triplets == len(sets) {
+    s.add("all triplets", 3)
+  }
+
+  switch {
+    case dragonTriplets == 3:
+      s.add("great dragons", 8)
+    case dragonTriplets == 2 && eyeSuit == 4 && eyeValue >= 5:
+      s.add("small dragons", 5)
+    default:
+      for i := 0; i < dragonTriplets; i++ {
+        s.add("dragon", 1)
+      }
+  }
+
+  switch {
+    case len(suits) == 1 && suits[4]:
+      s.add("all honors", HongKongLimit)
+    case len(suits) == 1:
+      s.add("all one suit", 7)
+    case len(suits) == 2 && suits[4]:
+      s.add("mixed one suit", 3)
+  }
+  return s
+}
+
+// hong kong faan for how the hand was won
+func hongKongWinPatterns(w WinContext, s *ScoredWin) {
+  if w.Source == "draw" {
+    s.add("self-drawn", 1)
+  }
+  if w.KongReplacement {
+    s.add("win on a kong replacement", 1)
+  }
+  if w.RobbedKong {
+    s.add("robbing a kong", 1)
+  }
+}
+
+// hong kong faan added to a hand of pairs: how it was won and the suits used
+func hongKongPairPatterns(w WinContext, pairs []readingSet, s *ScoredWin) {
+  hongKongWinPatterns(w, s)
+  suits := make(map[int]bool)
+  for _, pair := range pairs {
+    suits[pair.Suit] = true
+  }
+  switch {
+    case len(suits) == 1 && suits[4]:
+      s.add("all honors", HongKongLimit)
+    case len(suits) == 1:
+      s.add("all one suit", 7)
+    case len(suits) == 2 && suits[4]:
+      s.add("mixed one suit", 3)
+  }
+}
+
+// hong kong scoring: the highest-scoring reading, capped at the limit
+func scoreHongKong(w WinContext) ScoredWin {
+  best := ScoredWin{ Scoring: "hongkong", Unit: "faan" }
+
+  tileCounts, _, _ := w.Hand.CountHiddenTiles(w.Consider)
+  for _, p := range w.Rules.SpecialWins(w.Hand, w.Consider) {
+    s := ScoredWin{ Scoring: "hongkong", Unit: "faan" }
+    s.add(p.Name, p.PointsFor("hongkong"))
+    if p.AllPairs() {
+      hongKongPairPatterns(w, pairSets(tileCounts), &s)
+    }
+    if s.Points > best.Points {
+      best = s
+    }
+  }
+
+  for _, d := range Decompositions(tileCounts) {
+    if s := hongKongPatterns(w, d); s.Points > best.Points {
+      best = s
+    }
+  }
+
+  if best.Points >= HongKongLimit {
+    best.Points = HongKongLimit
+    best.Limit = true
+  }
+  return best
+}
+
+// seat wind of a player (1 east to 4 north) given the dealer
+func (g *Game) SeatWind(player int) int {
+  return (player-g.StartPlayer+g.Rules.Players) % g.Rules.Players + 1
+}
+
+// determine if the player has a win that meets the rule set's minimum; also returns its score
+func (g *Game) HaveQualifyingWin(player int, consider Tile, tileSource string) (bool, ScoredWin) {
+  h := g.Hands[player]
+  if !h.HaveWin(consider, tileSource) {
+    return false, ScoredWin{}
+  }
+  
+  // no win on a discard while furiten
+  if g.Rules.Riichi && tileSource != "draw" && g.Furiten(player) {
+    if VerboseDebug {
+      fmt.Printf("[vd] Player %d: furiten; win on a discard refused\n", player)
+    }
+    return false, ScoredWin{}
+  }
+
+  winningTile := consider
+  if consider == EmptyTile {
+    winningTile = h.LastNewTile
+  }
+  w := WinContext{
+    Hand: h,
+    Consider: consider,
+    WinningTile: winningTile,
+    Source: tileSource,
+    SeatWind: g.SeatWind(player),
+    PrevailingWind: g.PrevailingWind,
+    Rules: g.Rules,
+    LastTile: g.LiveTiles() <= 0,
+    RobbedKong: consider != EmptyTile && consider == g.KongTile,
+    KongReplacement: tileSource == "draw" && g.KongReplacement }
+  if winningTile.Suit >= 1 && winningTile.Suit <= 4 {
+    w.LastCopy = h.UnseenTileCounts(g.Discard, g.Hands)[winningTile.Suit-1][winningTile.Value] == 0
+  }
+  if g.Rules.Riichi {
+    w.DoraIndicators = g.DoraIndicators()
+    if h.Riichi {
+      w.UraIndicators = g.UraDoraIndicators()
+    }
+  }
+  score := ScoreWin(w)
+
+  if score.QualifyingPoints() < g.Rules.MinimumFaan {
+    if VerboseDebug {
+      fmt.Printf("[vd] Player %d: win of %s is below the minimum of %d\n", player, score, g.Rules.MinimumFaan)
+    }
+    return false, score
+  }
+  return true, score
+}
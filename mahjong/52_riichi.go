@@ -0,0 +1,563 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// japanese riichi rules: dead wall and dora indicators, riichi declarations, furiten, and yaku and fu scoring
+package mahjong
+
+import(
+  "fmt"
+)
+
+const (
+  // tiles of the dead wall: four replacement tiles, then pairs of dora and ura dora indicators
+  RiichiDeadWall = 14
+  // kongs before the replacement tiles run out
+  RiichiMaxKongs = 4
+  // yakuman and its basic points
+  RiichiYakuman = 13
+  RiichiYakumanPoints = 8000
+  // fu of seven pairs, also used for the other special hands
+  RiichiSevenPairsFu = 25
+)
+
+func init() {
+  RulePresets["riichi"] = RuleSet{
+    Name: "riichi",
+    Flowers: false,
+    ChowAllowed: true,
+    WinOnAnySequence: true,
+    // at least one yaku; dora do not count
+    MinimumFaan: 1,
+    SpecialHands: []string{ "thirteenOrphans", "sevenPairs" },
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawDealerReady,
+    Scoring: "riichi",
+    RedFives: true,
+    DeadWall: RiichiDeadWall,
+    // with the basic points as the value of a win: 4 from the discarder, 6 to the dealer, and 1 and 2 from each on a self-draw
+    DealerMultiplier: 2,
+    Riichi: true,
+    HandSize: StandardHandSize,
+    Players: PlayersInGame }
+
+  Scorers["riichi"] = scoreRiichi
+}
+
+// # dead wall
+// position in the wall counting back from the first replacement tile
+func (g *Game) deadWallPosition(offset int) int {
+  n := len(g.Undealt)
+  return ((g.AllocationStart-1-offset) % n + n) % n
+}
+
+// revealed dora indicators: one, plus one for each kong
+func (g *Game) DoraIndicators() []Tile {
+  return g.deadWallTiles(4)
+}
+
+// ura dora indicators, beneath the dora indicators
+func (g *Game) UraDoraIndicators() []Tile {
+  return g.deadWallTiles(5)
+}
+
+// indicators starting at a dead wall offset
+func (g *Game) deadWallTiles(offset int) []Tile {
+  indicators := make([]Tile, 0, RiichiMaxKongs+1)
+  if !g.Rules.Riichi || g.Rules.DeadWall < RiichiDeadWall || len(g.Undealt) == 0 {
+    return indicators
+  }
+  for i := 0; i <= g.KongCount && i <= RiichiMaxKongs; i++ {
+    if t := g.Undealt[g.deadWallPosition(offset+2*i)]; t != EmptyTile {
+      indicators = append(indicators, t)
+    }
+  }
+  return indicators
+}
+
+// suit and value of the dora shown by an indicator: the next tile in its suit, winds and dragons cycling separately
+func DoraFromIndicator(indicator Tile) (int, int) {
+  switch {
+    case indicator.Suit <= 3:
+      return indicator.Suit, indicator.Value % 9 + 1
+    case indicator.Value <= 4:
+      return indicator.Suit, indicator.Value % 4 + 1
+  }
+  return indicator.Suit, (indicator.Value-4) % 3 + 5
+}
+
+// determine if another kong may be declared without using up the replacement tiles
+func (g *Game) KongAllowed() bool {
+  return !g.Rules.Riichi || g.KongCount < RiichiMaxKongs
+}
+
+// # riichi and furiten
+// determine if a player may not win on a discard: a winning tile is among their own discards, or they passed on a win since their last discard
+func (g *Game) Furiten(player int) bool {
+  h := g.Hands[player]
+  if h.MissedWin {
+    return true
+  }
+  for _, wait := range h.Waits(newTileCounts()) {
+    for _, d := range g.Discard {
+      if d.Player == player && d.Item.Suit == wait.Item.Suit && d.Item.Value == wait.Item.Value {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+// hand positions whose discard leaves a concealed hand ready, allowing riichi; none if riichi cannot be declared
+func (g *Game) RiichiDiscards(player int) []int {
+  positions := make([]int, 0, 14)
+  h := g.Hands[player]
+  if !g.Rules.Riichi || h.Riichi || !h.Concealed() || g.LiveTiles() < g.Rules.Players {
+    return positions
+  }
+  for i, t := range h.Hidden {
+    if t == EmptyTile {
+      continue
+    }
+    remaining := h
+    remaining.Hidden = withoutTile(h.Hidden, t)
+    tileCounts, _, _ := remaining.CountHiddenTiles(EmptyTile)
+    if g.Rules.Shanten(tileCounts, h.RevealedSets) == 0 {
+      positions = append(positions, i)
+    }
+  }
+  return positions
+}
+
+// hand position of a tile, by id; -1 if not held
+func (h PlayerHand) tilePosition(t Tile) int {
+  for i, held := range h.Hidden {
+    if held != EmptyTile && held.Id == t.Id {
+      return i
+    }
+  }
+  return -1
+}
+
+// hand position of the riichi discard leaving the most live winning tiles
+func (g *Game) bestRiichiDiscard(player int) int {
+  h := g.Hands[player]
+  for _, option := range h.DiscardOptions(h.UnseenTileCounts(g.Discard, g.Hands)) {
+    if option.Shanten != 0 {
+      break
+    }
+    for i, t := range h.Hidden {
+      if t != EmptyTile && t.Suit == option.Item.Suit && t.Value == option.Item.Value {
+        return i
+      }
+    }
+  }
+  return g.RiichiDiscards(player)[0]
+}
+
+// determine if a hand position is among the given positions
+func containsPosition(positions []int, position int) bool {
+  for _, p := range positions {
+    if p == position {
+      return true
+    }
+  }
+  return false
+}
+
+// riichi status for display: dora indicators, sticks on the table and declared players
+func (g *Game) RiichiLines() []string {
+  if !g.Rules.Riichi {
+    return []string{}
+  }
+  declared := ""
+  for _, h := range g.Hands {
+    if h.Riichi {
+      declared += fmt.Sprintf(" P%d", h.Player)
+    }
+  }
+  if declared == "" {
+    declared = " none"
+  }
+  return []string{ fmt.Sprintf("Dora indicators: %s  riichi sticks: %d  riichi:%s", tileGlyphs(g.DoraIndicators()), g.RiichiSticks, declared) }
+}
+
+// # scoring
+// yakuman present in a reading
+func riichiYakuman(w WinContext, r handReading) ScoredWin {
+  s := ScoredWin{ Scoring: "riichi", Unit: "han" }
+  dragons, winds, concealedTriples := 0, 0, 0
+  allHonors, allTerminals, allGreen := r.EyeSuit == 4, r.EyeSuit != 4 && terminalOrHonor(r.EyeSuit, r.EyeValue), isGreen(r.EyeSuit, r.EyeValue)
+  for _, set := range r.Sets {
+    if set.Kind != "seq" {
+      if set.Suit == 4 && set.Value >= 5 {
+        dragons++
+      } else if set.Suit == 4 {
+        winds++
+      }
+      if !set.Open {
+        concealedTriples++
+      }
+    }
+    for _, t := range set.tiles() {
+      allHonors = allHonors && t[0] == 4
+      allTerminals = allTerminals && t[0] != 4 && terminalOrHonor(t[0], t[1])
+      allGreen = allGreen && isGreen(t[0], t[1])
+    }
+  }
+
+  if dragons == 3 {
+    s.add("big three dragons", RiichiYakuman)
+  }
+  if concealedTriples == 4 {
+    s.add("four concealed triplets", RiichiYakuman)
+  }
+  if allHonors {
+    s.add("all honors", RiichiYakuman)
+  }
+  if allTerminals {
+    s.add("all terminals", RiichiYakuman)
+  }
+  if allGreen {
+    s.add("all green", RiichiYakuman)
+  }
+  if winds == 4 {
+    s.add("big four winds", RiichiYakuman)
+  } else if winds == 3 && r.EyeSuit == 4 && r.EyeValue <= 4 {
+    s.add("little four winds", RiichiYakuman)
+  }
+  return s
+}
+
+// determine if a tile is green: bamboo 2, 3, 4, 6, 8 and the green dragon
+func isGreen(suit int, value int) bool {
+  return (suit == 2 && (value == 2 || value == 3 || value == 4 || value == 6 || value == 8)) || (suit == 4 && value == 6)
+}
+
+// han and fu for a reading without yakuman
+func riichiYaku(w WinContext, r handReading) ScoredWin {
+  s := ScoredWin{ Scoring: "riichi", Unit: "han" }
+  closed := w.Hand.Concealed()
+  // yaku worth one han less when open
+  openPenalty := 0
+  if !closed {
+    openPenalty = 1
+  }
+  valuable := func(suit int, value int) int {
+    worth := 0
+    if suit == 4 && (value >= 5 || value == w.SeatWind) {
+      worth++
+    }
+    if suit == 4 && value == w.PrevailingWind {
+      worth++
+    }
+    return worth
+  }
+
+  sequences, triples, concealedTriples, kongs, dragonTriples := 0, 0, 0, 0, 0
+  simples, outside, honors := !terminalOrHonor(r.EyeSuit, r.EyeValue), terminalOrHonor(r.EyeSuit, r.EyeValue), r.EyeSuit == 4
+  suits := map[int]bool{ r.EyeSuit: true }
+  seqCounts := make(map[[2]int]int)
+  for _, set := range r.Sets {
+    suits[set.Suit] = true
+    setOutside := false
+    for _, t := range set.tiles() {
+      if terminalOrHonor(t[0], t[1]) {
+        simples = false
+        setOutside = true
+      }
+      honors = honors || t[0] == 4
+    }
+    outside = outside && setOutside
+    if set.Kind == "seq" {
+      sequences++
+      seqCounts[[2]int{ set.Suit, set.Value }]++
+      continue
+    }
+    triples++
+    if set.Kind == "kong" {
+      kongs++
+    }
+    if !set.Open {
+      concealedTriples++
+    }
+    if set.Suit == 4 && set.Value >= 5 {
+      dragonTriples++
+    }
+    if worth := valuable(set.Suit, set.Value); worth > 0 {
+      s.add(fmt.Sprintf("yakuhai %s", UnicodeDisplay[3][set.Value]), worth)
+    }
+  }
+
+  if w.Hand.Riichi {
+    s.add("riichi", 1)
+  }
+  if closed && w.Source == "draw" {
+    s.add("menzen tsumo", 1)
+  }
+  if simples {
+    s.add("all simples", 1)
+  }
+  pinfu := closed && sequences == 4 && valuable(r.EyeSuit, r.EyeValue) == 0 && r.Wait == "ryanmen"
+  if pinfu {
+    s.add("pinfu", 1)
+  }
+  if closed {
+    pairs := 0
+    for _, count := range seqCounts {
+      pairs += count/2
+    }
+    if pairs == 2 {
+      s.add("twice pure double sequence", 3)
+    } else if pairs == 1 {
+      s.add("pure double sequence", 1)
+    }
+  }
+  riichiTimingYaku(w, &s)
+
+  for value := 1; value <= 7; value++ {
+    if seqCounts[[2]int{ 1, value }] > 0 && seqCounts[[2]int{ 2, value }] > 0 && seqCounts[[2]int{ 3, value }] > 0 {
+      s.add("mixed triple sequence", 2-openPenalty)
+      break
+    }
+  }
+  for suit := 1; suit <= 3; suit++ {
+    if seqCounts[[2]int{ suit, 1 }] > 0 && seqCounts[[2]int{ suit, 4 }] > 0 && seqCounts[[2]int{ suit, 7 }] > 0 {
+      s.add("pure straight", 2-openPenalty)
+    }
+  }
+  if triples == 4 {
+    s.add("all triplets", 2)
+  }
+  if concealedTriples == 3 {
+    s.add("three concealed triplets", 2)
+  }
+  if kongs == 3 {
+    s.add("three kongs", 2)
+  }
+  switch {
+    case outside && sequences == 0:
+      s.add("all terminals and honors", 2)
+    case outside && honors:
+      s.add("half outside hand", 2-openPenalty)
+    case outside:
+      s.add("fully outside hand", 3-openPenalty)
+  }
+  if dragonTriples == 2 && r.EyeSuit == 4 && r.EyeValue >= 5 {
+    s.add("little three dragons", 2)
+  }
+  if len(suits) == 1 && !honors {
+    s.add("full flush", 6-openPenalty)
+  } else if len(suits) == 2 && honors {
+    s.add("half flush", 3-openPenalty)
+  }
+
+  // fu
+  if pinfu && w.Source == "draw" {
+    s.Fu = 20
+    return s
+  }
+  fu := 20
+  if closed && w.Source != "draw" {
+    fu += 10
+  }
+  if w.Source == "draw" {
+    fu += 2
+  }
+  for _, set := range r.Sets {
+    if set.Kind == "seq" {
+      continue
+    }
+    setFu := 2
+    if terminalOrHonor(set.Suit, set.Value) {
+      setFu *= 2
+    }
+    if !set.Open {
+      setFu *= 2
+    }
+    if set.Kind == "kong" {
+      setFu *= 4
+    }
+    fu += setFu
+  }
+  fu += 2*valuable(r.EyeSuit, r.EyeValue)
+  if r.Wait == "kanchan" || r.Wait == "penchan" || r.Wait == "tanki" {
+    fu += 2
+  }
+  if !closed && fu == 20 {
+    fu = 30
+  }
+  s.Fu = (fu+9)/10*10
+  return s
+}
+
+// yaku for when and with which tile the hand was won
+func riichiTimingYaku(w WinContext, s *ScoredWin) {
+  if w.LastTile && w.Source == "draw" && !w.KongReplacement {
+    s.add("last tile draw", 1)
+  } else if w.LastTile && w.Source != "draw" {
+    s.add("last tile discard", 1)
+  }
+  if w.KongReplacement {
+    s.add("rinshan kaihou", 1)
+  }
+  if w.RobbedKong {
+    s.add("chankan", 1)
+  }
+}
+
+// yaku added to a hand of pairs: those that do not depend on sets
+func riichiPairYaku(w WinContext, pairs []readingSet, s *ScoredWin) {
+  simples, terminals, honors := true, true, false
+  suits := make(map[int]bool)
+  for _, pair := range pairs {
+    simples = simples && !terminalOrHonor(pair.Suit, pair.Value)
+    terminals = terminals && terminalOrHonor(pair.Suit, pair.Value)
+    honors = honors || pair.Suit == 4
+    suits[pair.Suit] = true
+  }
+
+  if w.Hand.Riichi {
+    s.add("riichi", 1)
+  }
+  if w.Source == "draw" {
+    s.add("menzen tsumo", 1)
+  }
+  if simples {
+    s.add("all simples", 1)
+  }
+  if terminals {
+    s.add("all terminals and honors", 2)
+  }
+  riichiTimingYaku(w, s)
+  if len(suits) == 1 && !honors {
+    s.add("full flush", 6)
+  } else if len(suits) == 2 && honors {
+    s.add("half flush", 3)
+  }
+}
+
+// counts of the tiles of a reading
+func readingCounts(r handReading) [][]int {
+  counts := newTileCounts()
+  counts[r.EyeSuit-1][r.EyeValue] += 2
+  for _, set := range r.Sets {
+    for _, t := range set.tiles() {
+      counts[t[0]-1][t[1]]++
+    }
+  }
+  return counts
+}
+
+// count dora, red fives and, after riichi, ura dora in the counted tiles of the hand
+func riichiDora(w WinContext, counts [][]int, s *ScoredWin) {
+  count := func(indicators []Tile) int {
+    dora := 0
+    for _, indicator := range indicators {
+      suit, value := DoraFromIndicator(indicator)
+      dora += counts[suit-1][value]
+    }
+    return dora
+  }
+  if dora := count(w.DoraIndicators); dora > 0 {
+    s.addBonus("dora", dora)
+  }
+  if dora := count(w.UraIndicators); dora > 0 {
+    s.addBonus("ura dora", dora)
+  }
+
+  red := 0
+  tiles := append([]Tile{ w.Consider }, w.Hand.Hidden...)
+  for _, set := range w.Hand.RevealedTileSets[:w.Hand.RevealedSets] {
+    tiles = append(tiles, set.UnderlyingTiles...)
+  }
+  for _, t := range tiles {
+    if t.Red {
+      red++
+    }
+  }
+  if red > 0 {
+    s.addBonus("red five", red)
+  }
+  addNorthBonus(w, s)
+}
+
+// basic points from han and fu, with the limit reached
+func riichiBasePoints(han int, fu int) (int, string) {
+  switch {
+    case han >= RiichiYakuman:
+      return RiichiYakumanPoints*(han/RiichiYakuman), "yakuman"
+    case han >= 11:
+      return 6000, "sanbaiman"
+    case han >= 8:
+      return 4000, "baiman"
+    case han >= 6:
+      return 3000, "haneman"
+    case han >= 5:
+      return 2000, "mangan"
+  }
+  base := fu << uint(han+2)
+  if base >= 2000 {
+    return 2000, "mangan"
+  }
+  return base, ""
+}
+
+// riichi scoring: the reading with the most basic points; a hand without yaku scores no han other than dora
+func scoreRiichi(w WinContext) ScoredWin {
+  best := ScoredWin{ Scoring: "riichi", Unit: "han" }
+
+  // special hands are counted at the fixed fu of seven pairs
+  tileCounts, _, _ := w.Hand.CountHiddenTiles(w.Consider)
+  for _, p := range w.Rules.SpecialWins(w.Hand, w.Consider) {
+    s := ScoredWin{ Scoring: "riichi", Unit: "han", Fu: RiichiSevenPairsFu }
+    s.add(p.Name, p.PointsFor("riichi"))
+    if p.AllPairs() {
+      riichiPairYaku(w, pairSets(tileCounts), &s)
+    }
+    if s.Points < RiichiYakuman {
+      riichiDora(w, tileCounts, &s)
+    }
+    s.BasePoints, s.LimitName = riichiBasePoints(s.Points, s.Fu)
+    if s.BasePoints > best.BasePoints || (s.BasePoints == best.BasePoints && s.Points > best.Points) {
+      best = s
+    }
+  }
+
+  for _, r := range handReadings(w) {
+    s := riichiYakuman(w, r)
+    if s.Points == 0 {
+      s = riichiYaku(w, r)
+      if s.Points > 0 {
+        riichiDora(w, readingCounts(r), &s)
+      }
+    }
+    s.BasePoints, s.LimitName = riichiBasePoints(s.Points, s.Fu)
+    if s.BasePoints > best.BasePoints || (s.BasePoints == best.BasePoints && s.Points > best.Points) {
+      best = s
+    }
+  }
+
+  if best.Points >= RiichiYakuman {
+    best.Fu = 0
+    best.BasePoints, best.LimitName = riichiBasePoints(best.Points, 0)
+    best.Limit = true
+  } else if best.LimitName != "" {
+    best.Limit = true
+  }
+  return best
+}
@@ -0,0 +1,707 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// chinese official (mcr) rules: the 81 fan, their exclusions and the eight fan minimum
+package mahjong
+
+import(
+  "sort"
+)
+
+const (
+  // a win needs this many fan, not counting flower tiles
+  McrMinimumFan = 8
+)
+
+// fan not counted alongside a fan (the non-repeat principle)
+var McrExclusions map[string][]string
+
+func init() {
+  RulePresets["mcr"] = RuleSet{
+    Name: "mcr",
+    Flowers: true,
+    ChowAllowed: true,
+    WinOnAnySequence: true,
+    MinimumFaan: McrMinimumFan,
+    SpecialHands: []string{ "thirteenOrphans", "sevenPairsDoubled", "knittedStraight", "lesserHonorsKnitted", "greaterHonorsKnitted" },
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawRotate,
+    Scoring: "mcr",
+    HandSize: StandardHandSize,
+    Players: PlayersInGame }
+
+  Scorers["mcr"] = scoreMcr
+
+  McrExclusions = map[string][]string{
+    // 88
+    "big four winds": { "big three winds", "little four winds", "all pungs", "seat wind", "prevalent wind", "pung of terminals or honors" },
+    "big three dragons": { "little three dragons", "two dragon pungs", "dragon pung" },
+    "all green": { "half flush" },
+    "nine gates": { "full flush", "concealed hand", "pung of terminals or honors", "no honors", "one voided suit" },
+    "four kongs": { "three kongs", "two concealed kongs", "two melded kongs", "concealed kong", "melded kong", "all pungs", "single wait" },
+    "seven shifted pairs": { "seven pairs", "full flush", "concealed hand", "single wait", "no honors", "one voided suit" },
+    "thirteen orphans": { "all types", "concealed hand", "single wait" },
+    // 64
+    "all terminals": { "all terminals and honors", "all pungs", "outside hand", "pung of terminals or honors", "double pung", "no honors" },
+    "little four winds": { "big three winds", "pung of terminals or honors" },
+    "little three dragons": { "two dragon pungs", "dragon pung" },
+    "all honors": { "all terminals and honors", "all pungs", "outside hand", "pung of terminals or honors" },
+    "four concealed pungs": { "three concealed pungs", "two concealed pungs", "all pungs", "concealed hand" },
+    "pure terminal chows": { "seven pairs", "full flush", "all chows", "pure double chow", "two terminal chows", "no honors", "one voided suit" },
+    // 48
+    "quadruple chow": { "pure triple chow", "pure shifted pungs", "pure double chow", "tile hog" },
+    "four pure shifted pungs": { "pure shifted pungs", "pure triple chow", "all pungs" },
+    // 32
+    "four pure shifted chows": { "pure shifted chows", "pure double chow", "short straight", "two terminal chows" },
+    "three kongs": { "two concealed kongs", "two melded kongs", "concealed kong", "melded kong" },
+    "all terminals and honors": { "all pungs", "outside hand", "pung of terminals or honors" },
+    // 24
+    "seven pairs": { "concealed hand", "single wait" },
+    "all even pungs": { "all pungs", "all simples", "no honors" },
+    "full flush": { "one voided suit", "no honors" },
+    "pure triple chow": { "pure shifted pungs", "pure double chow" },
+    "pure shifted pungs": { "pure triple chow" },
+    "upper tiles": { "upper four", "no honors" },
+    "middle tiles": { "all simples", "no honors" },
+    "lower tiles": { "lower four", "no honors" },
+    // 16
+    "pure straight": { "short straight", "two terminal chows" },
+    "three-suited terminal chows": { "all chows", "mixed double chow", "two terminal chows", "no honors" },
+    "all fives": { "all simples", "no honors" },
+    "triple pung": { "double pung" },
+    "three concealed pungs": { "two concealed pungs" },
+    // 12
+    "upper four": { "no honors" },
+    "lower four": { "no honors" },
+    "big three winds": { "pung of terminals or honors" },
+    // 8
+    "mixed triple chow": { "mixed double chow" },
+    "reversible tiles": { "one voided suit" },
+    "last tile draw": { "self-drawn" },
+    "out with replacement tile": { "self-drawn" },
+    "robbing the kong": { "last tile" },
+    "two concealed kongs": { "concealed kong", "two concealed pungs" },
+    // 6
+    "half flush": { "one voided suit" },
+    "melded hand": { "single wait" },
+    "two dragon pungs": { "dragon pung" },
+    // 4
+    "fully concealed hand": { "self-drawn", "concealed hand" },
+    "two melded kongs": { "melded kong" },
+    // 2
+    "all chows": { "no honors" },
+    "all simples": { "no honors" } }
+}
+
+// drop the fan excluded by higher fan; also returns every excluded fan
+func mcrExclude(s ScoredWin) (ScoredWin, map[string]bool) {
+  patterns := append([]ScoredPattern{}, s.Patterns...)
+  sort.SliceStable(patterns, func(a, b int) bool { return patterns[a].Points > patterns[b].Points })
+
+  excluded := make(map[string]bool)
+  kept := ScoredWin{ Scoring: s.Scoring, Unit: s.Unit }
+  for _, pattern := range patterns {
+    if excluded[pattern.Name] {
+      continue
+    }
+    kept.add(pattern.Name, pattern.Points)
+    for _, name := range McrExclusions[pattern.Name] {
+      excluded[name] = true
+    }
+  }
+  return kept, excluded
+}
+
+// fan for how the hand was won, common to every reading
+func mcrWinFans(w WinContext, s *ScoredWin, wait string, waitingTiles int) {
+  selfDrawn := w.Source == "draw"
+  switch {
+    case w.Hand.Concealed() && selfDrawn:
+      s.add("fully concealed hand", 4)
+    case w.Hand.Concealed():
+      s.add("concealed hand", 2)
+    case w.Hand.RevealedSets == 4 && !selfDrawn:
+      s.add("melded hand", 6)
+  }
+  if selfDrawn {
+    s.add("self-drawn", 1)
+  }
+  if w.LastTile && selfDrawn {
+    s.add("last tile draw", 8)
+  } else if w.LastTile {
+    s.add("last tile claim", 8)
+  }
+  if w.LastCopy {
+    s.add("last tile", 4)
+  }
+  if w.KongReplacement {
+    s.add("out with replacement tile", 8)
+  }
+  if w.RobbedKong {
+    s.add("robbing the kong", 8)
+  }
+
+  // wait fan only when the hand waited on a single tile
+  if waitingTiles != 1 {
+    return
+  }
+  switch wait {
+    case "penchan":
+      s.add("edge wait", 1)
+    case "kanchan":
+      s.add("closed wait", 1)
+    case "tanki":
+      s.add("single wait", 1)
+  }
+}
+
+// determine if a tile may be read upside down: 1234589 dots, 245689 bamboo and the white dragon
+func isReversible(suit int, value int) bool {
+  switch suit {
+    case 1:
+      return value != 6 && value != 7
+    case 2:
+      return value != 1 && value != 3 && value != 7
+    case 4:
+      return value == 7
+  }
+  return false
+}
+
+// determine if three values are consecutive, in any order
+func consecutive(values [3]int, step int) bool {
+  sorted := values[:]
+  sort.Ints(sorted)
+  return sorted[1] == sorted[0]+step && sorted[2] == sorted[1]+step
+}
+
+// fan for the tiles used, whatever the sets: the eye and sets of a reading or the pairs of a hand of pairs
+// also returns the count of each tile
+func mcrTileFans(parts []readingSet, s *ScoredWin) map[[2]int]int {
+  tiles := make([][2]int, 0, 18)
+  tileCounts := make(map[[2]int]int)
+  for _, part := range parts {
+    tiles = append(tiles, part.tiles()...)
+  }
+  allHonors, allTerminals, terminalsAndHonors, simples, noHonors := true, true, true, true, true
+  allGreen, reversible, upper, middle, lower, upperFour, lowerFour := true, true, true, true, true, true, true
+  suits := make(map[int]bool)
+  winds, dragons := false, false
+  for _, t := range tiles {
+    tileCounts[t]++
+    honor := t[0] == 4
+    allHonors = allHonors && honor
+    allTerminals = allTerminals && !honor && terminalOrHonor(t[0], t[1])
+    terminalsAndHonors = terminalsAndHonors && terminalOrHonor(t[0], t[1])
+    simples = simples && !terminalOrHonor(t[0], t[1])
+    noHonors = noHonors && !honor
+    allGreen = allGreen && isGreen(t[0], t[1])
+    reversible = reversible && isReversible(t[0], t[1])
+    upper = upper && !honor && t[1] >= 7
+    middle = middle && !honor && t[1] >= 4 && t[1] <= 6
+    lower = lower && !honor && t[1] <= 3
+    upperFour = upperFour && !honor && t[1] >= 6
+    lowerFour = lowerFour && !honor && t[1] <= 4
+    if honor {
+      winds = winds || t[1] <= 4
+      dragons = dragons || t[1] >= 5
+    } else {
+      suits[t[0]] = true
+    }
+  }
+
+  outside, fives := true, true
+  for _, part := range parts {
+    hasOutside, hasFive := false, false
+    for _, t := range part.tiles() {
+      hasOutside = hasOutside || terminalOrHonor(t[0], t[1])
+      hasFive = hasFive || (t[0] != 4 && t[1] == 5)
+    }
+    outside = outside && hasOutside
+    fives = fives && hasFive
+  }
+
+  switch {
+    case allGreen:
+      s.add("all green", 88)
+    case allHonors:
+      s.add("all honors", 64)
+    case allTerminals:
+      s.add("all terminals", 64)
+  }
+  if terminalsAndHonors {
+    s.add("all terminals and honors", 32)
+  }
+  switch {
+    case upper:
+      s.add("upper tiles", 24)
+    case middle:
+      s.add("middle tiles", 24)
+    case lower:
+      s.add("lower tiles", 24)
+  }
+  if fives {
+    s.add("all fives", 16)
+  }
+  if upperFour {
+    s.add("upper four", 12)
+  }
+  if lowerFour {
+    s.add("lower four", 12)
+  }
+  if reversible {
+    s.add("reversible tiles", 8)
+  }
+  if len(suits) == 3 && winds && dragons {
+    s.add("all types", 6)
+  }
+  switch {
+    case len(suits) == 1 && noHonors:
+      s.add("full flush", 24)
+    case len(suits) == 1:
+      s.add("half flush", 6)
+    case len(suits) == 2:
+      s.add("one voided suit", 1)
+  }
+  if outside {
+    s.add("outside hand", 4)
+  }
+  if simples {
+    s.add("all simples", 2)
+  }
+  if noHonors {
+    s.add("no honors", 1)
+  }
+  return tileCounts
+}
+
+// fan formed by the sets, eye and tiles of one reading, before the one fan chow pairs
+func mcrSetFans(w WinContext, r handReading) ScoredWin {
+  s := ScoredWin{ Scoring: "mcr", Unit: "fan" }
+  chows := make([]readingSet, 0, 4)
+  pungs := make([]readingSet, 0, 4)
+  for _, set := range r.Sets {
+    if set.Kind == "seq" {
+      chows = append(chows, set)
+    } else {
+      pungs = append(pungs, set)
+    }
+  }
+  eye := readingSet{ Kind: "eye", Suit: r.EyeSuit, Value: r.EyeValue }
+  parts := append(append([]readingSet{}, r.Sets...), eye)
+  tileCounts := mcrTileFans(parts, &s)
+
+  // # pungs and kongs
+  windPungs, dragonPungs, concealedPungs, meldedKongs, concealedKongs := 0, 0, 0, 0, 0
+  evenPungs := len(pungs) == 4 && r.EyeSuit != 4 && r.EyeValue % 2 == 0
+  for _, pung := range pungs {
+    if !pung.Open {
+      concealedPungs++
+    }
+    if pung.Kind == "kong" && pung.Open {
+      meldedKongs++
+    } else if pung.Kind == "kong" {
+      concealedKongs++
+    }
+    evenPungs = evenPungs && pung.Suit != 4 && pung.Value % 2 == 0
+
+    switch {
+      case pung.Suit == 4 && pung.Value >= 5:
+        dragonPungs++
+        s.add("dragon pung", 2)
+      case pung.Suit == 4:
+        windPungs++
+        if pung.Value == w.PrevailingWind {
+          s.add("prevalent wind", 2)
+        }
+        if pung.Value == w.SeatWind {
+          s.add("seat wind", 2)
+        }
+        if pung.Value != w.PrevailingWind && pung.Value != w.SeatWind {
+          s.add("pung of terminals or honors", 1)
+        }
+      case terminalOrHonor(pung.Suit, pung.Value):
+        s.add("pung of terminals or honors", 1)
+    }
+  }
+
+  switch {
+    case windPungs == 4:
+      s.add("big four winds", 88)
+    case windPungs == 3 && r.EyeSuit == 4 && r.EyeValue <= 4:
+      s.add("little four winds", 64)
+    case windPungs == 3:
+      s.add("big three winds", 12)
+  }
+  switch {
+    case dragonPungs == 3:
+      s.add("big three dragons", 88)
+    case dragonPungs == 2 && r.EyeSuit == 4 && r.EyeValue >= 5:
+      s.add("little three dragons", 64)
+    case dragonPungs == 2:
+      s.add("two dragon pungs", 6)
+  }
+  if len(pungs) == 4 {
+    s.add("all pungs", 6)
+  }
+  if evenPungs {
+    s.add("all even pungs", 24)
+  }
+  switch {
+    case concealedPungs == 4:
+      s.add("four concealed pungs", 64)
+    case concealedPungs == 3:
+      s.add("three concealed pungs", 16)
+    case concealedPungs == 2:
+      s.add("two concealed pungs", 2)
+  }
+  switch kongs := meldedKongs + concealedKongs; {
+    case kongs == 4:
+      s.add("four kongs", 88)
+    case kongs == 3:
+      s.add("three kongs", 32)
+    case concealedKongs == 2:
+      s.add("two concealed kongs", 8)
+    case meldedKongs == 2:
+      s.add("two melded kongs", 4)
+    default:
+      if concealedKongs == 1 {
+        s.add("concealed kong", 2)
+      }
+      if meldedKongs == 1 {
+        s.add("melded kong", 1)
+      }
+  }
+
+  // four copies of a tile used without a kong
+  kongTiles := make(map[[2]int]bool)
+  for _, pung := range pungs {
+    if pung.Kind == "kong" {
+      kongTiles[[2]int{ pung.Suit, pung.Value }] = true
+    }
+  }
+  for tile, count := range tileCounts {
+    if count == 4 && !kongTiles[tile] {
+      s.add("tile hog", 2)
+    }
+  }
+
+  // pungs of the standard suits by number
+  suitedPungs := make(map[int][]int)
+  for _, pung := range pungs {
+    if pung.Suit != 4 {
+      suitedPungs[pung.Value] = append(suitedPungs[pung.Value], pung.Suit)
+    }
+  }
+  for _, pungSuits := range suitedPungs {
+    if len(pungSuits) == 3 {
+      s.add("triple pung", 16)
+    } else if len(pungSuits) == 2 {
+      s.add("double pung", 2)
+    }
+  }
+  mcrShiftedPungs(pungs, &s)
+
+  // # chows
+  mcrChowFans(chows, r, &s)
+  if len(chows) == 4 && r.EyeSuit != 4 {
+    s.add("all chows", 2)
+  }
+  return s
+}
+
+// pungs of consecutive numbers
+func mcrShiftedPungs(pungs []readingSet, s *ScoredWin) {
+  suited := make([]readingSet, 0, 4)
+  for _, pung := range pungs {
+    if pung.Suit != 4 {
+      suited = append(suited, pung)
+    }
+  }
+  if len(suited) == 4 && suited[0].Suit == suited[1].Suit && suited[1].Suit == suited[2].Suit && suited[2].Suit == suited[3].Suit {
+    values := []int{ suited[0].Value, suited[1].Value, suited[2].Value, suited[3].Value }
+    sort.Ints(values)
+    if values[1] == values[0]+1 && values[2] == values[1]+1 && values[3] == values[2]+1 {
+      s.add("four pure shifted pungs", 48)
+      return
+    }
+  }
+  for _, three := range triples(len(suited)) {
+    a, b, c := suited[three[0]], suited[three[1]], suited[three[2]]
+    if !consecutive([3]int{ a.Value, b.Value, c.Value }, 1) {
+      continue
+    }
+    if a.Suit == b.Suit && b.Suit == c.Suit {
+      s.add("pure shifted pungs", 24)
+      return
+    }
+    if a.Suit != b.Suit && b.Suit != c.Suit && a.Suit != c.Suit {
+      s.add("mixed shifted pungs", 8)
+      return
+    }
+  }
+}
+
+// index combinations of three out of n
+func triples(n int) [][3]int {
+  combinations := make([][3]int, 0, 4)
+  for i := 0; i < n; i++ {
+    for j := i+1; j < n; j++ {
+      for k := j+1; k < n; k++ {
+        combinations = append(combinations, [3]int{ i, j, k })
+      }
+    }
+  }
+  return combinations
+}
+
+// fan formed by three or four chows
+func mcrChowFans(chows []readingSet, r handReading, s *ScoredWin) {
+  if len(chows) == 4 {
+    sameSuit := chows[0].Suit == chows[1].Suit && chows[1].Suit == chows[2].Suit && chows[2].Suit == chows[3].Suit
+    values := []int{ chows[0].Value, chows[1].Value, chows[2].Value, chows[3].Value }
+    sort.Ints(values)
+    switch {
+      case sameSuit && values[0] == values[3]:
+        s.add("quadruple chow", 48)
+        return
+      case sameSuit && values[0] == 1 && values[1] == 1 && values[2] == 7 && values[3] == 7 && r.EyeSuit == chows[0].Suit && r.EyeValue == 5:
+        s.add("pure terminal chows", 64)
+        return
+      case sameSuit && values[1]-values[0] == values[2]-values[1] && values[2]-values[1] == values[3]-values[2] && (values[1]-values[0] == 1 || values[1]-values[0] == 2):
+        s.add("four pure shifted chows", 32)
+        return
+    }
+
+    // 123 and 789 in two suits with a pair of fives in the third
+    terminalChows := make(map[int]int)
+    for _, chow := range chows {
+      if chow.Value == 1 {
+        terminalChows[chow.Suit] |= 1
+      } else if chow.Value == 7 {
+        terminalChows[chow.Suit] |= 2
+      }
+    }
+    if len(terminalChows) == 2 && r.EyeSuit != 4 && r.EyeValue == 5 && terminalChows[r.EyeSuit] == 0 {
+      complete := true
+      for _, found := range terminalChows {
+        complete = complete && found == 3
+      }
+      if complete {
+        s.add("three-suited terminal chows", 16)
+        return
+      }
+    }
+  }
+
+  // the best of the three chow fan
+  name, points := "", 0
+  for _, three := range triples(len(chows)) {
+    a, b, c := chows[three[0]], chows[three[1]], chows[three[2]]
+    sameSuit := a.Suit == b.Suit && b.Suit == c.Suit
+    differentSuits := a.Suit != b.Suit && b.Suit != c.Suit && a.Suit != c.Suit
+    values := [3]int{ a.Value, b.Value, c.Value }
+    found, worth := "", 0
+    switch {
+      case sameSuit && a.Value == b.Value && b.Value == c.Value:
+        found, worth = "pure triple chow", 24
+      case sameSuit && values[0]+values[1]+values[2] == 12 && consecutive(values, 3):
+        found, worth = "pure straight", 16
+      case sameSuit && (consecutive(values, 1) || consecutive(values, 2)):
+        found, worth = "pure shifted chows", 16
+      case differentSuits && values[0]+values[1]+values[2] == 12 && consecutive(values, 3):
+        found, worth = "mixed straight", 8
+      case differentSuits && a.Value == b.Value && b.Value == c.Value:
+        found, worth = "mixed triple chow", 8
+      case differentSuits && consecutive(values, 1):
+        found, worth = "mixed shifted chows", 6
+    }
+    if worth > points {
+      name, points = found, worth
+    }
+  }
+  if points > 0 {
+    s.add(name, points)
+  }
+}
+
+// one fan combinations of two chows; each chow combines once and excluded fan are skipped
+func mcrChowPairs(chows []readingSet, excluded map[string]bool) []string {
+  pairName := func(a readingSet, b readingSet) string {
+    low, high := a.Value, b.Value
+    if low > high {
+      low, high = high, low
+    }
+    switch {
+      case a.Suit == b.Suit && low == high:
+        return "pure double chow"
+      case a.Suit == b.Suit && low == 1 && high == 7:
+        return "two terminal chows"
+      case a.Suit == b.Suit && high == low+3:
+        return "short straight"
+      case a.Suit != b.Suit && low == high:
+        return "mixed double chow"
+    }
+    return ""
+  }
+
+  var best []string
+  var search func(used []bool, found []string)
+  search = func(used []bool, found []string) {
+    if len(found) > len(best) {
+      best = append([]string{}, found...)
+    }
+    for i := 0; i < len(chows); i++ {
+      for j := i+1; j < len(chows); j++ {
+        if used[i] || used[j] {
+          continue
+        }
+        name := pairName(chows[i], chows[j])
+        if name == "" || excluded[name] {
+          continue
+        }
+        used[i], used[j] = true, true
+        search(used, append(found, name))
+        used[i], used[j] = false, false
+      }
+    }
+  }
+  search(make([]bool, len(chows)), []string{})
+  return best
+}
+
+// determine if a concealed hand of one suit waited on the nine gates pattern: 1112345678999 and any tile
+func mcrNineGates(w WinContext) bool {
+  if w.Hand.RevealedSets > 0 || w.WinningTile.Suit < 1 || w.WinningTile.Suit > 3 {
+    return false
+  }
+  tileCounts, tileCountsSum, _ := w.Hand.CountHiddenTiles(w.Consider)
+  suit := w.WinningTile.Suit-1
+  if tileCountsSum[suit] != 14 {
+    return false
+  }
+  tileCounts[suit][w.WinningTile.Value]--
+  for value := 1; value <= 9; value++ {
+    want := 1
+    if value == 1 || value == 9 {
+      want = 3
+    }
+    if tileCounts[suit][value] != want {
+      return false
+    }
+  }
+  return true
+}
+
+// determine if seven pairs are of one suit and consecutive values, such as 11223344556677p
+func mcrSevenShiftedPairs(tileCounts [][]int) bool {
+  for suit := 0; suit < 3; suit++ {
+    pairs, first := 0, 0
+    for value := 1; value <= 9; value++ {
+      switch tileCounts[suit][value] {
+        case 0:
+        case 2:
+          if first == 0 {
+            first = value
+          }
+          if value-first != pairs {
+            return false
+          }
+          pairs++
+        default:
+          return false
+      }
+    }
+    if pairs == 7 {
+      return true
+    }
+    if pairs > 0 {
+      return false
+    }
+  }
+  return false
+}
+
+// mcr scoring: every reading is scored with its exclusions applied and the best is kept; flower tiles count one fan each but not towards the minimum
+func scoreMcr(w WinContext) ScoredWin {
+  best := ScoredWin{ Scoring: "mcr", Unit: "fan" }
+  waitingTiles := waitingTileCount(w)
+
+  candidates := make([]ScoredWin, 0, 4)
+  hiddenCounts, _, _ := w.Hand.CountHiddenTiles(w.Consider)
+  for _, p := range w.Rules.SpecialWins(w.Hand, w.Consider) {
+    s := ScoredWin{ Scoring: "mcr", Unit: "fan" }
+    s.add(p.Name, p.PointsFor("mcr"))
+    if p.AllPairs() {
+      if mcrSevenShiftedPairs(hiddenCounts) {
+        s.add("seven shifted pairs", 88)
+      }
+      for _, count := range mcrTileFans(pairSets(hiddenCounts), &s) {
+        if count == 4 {
+          s.add("tile hog", 2)
+        }
+      }
+    }
+    mcrWinFans(w, &s, "", waitingTiles)
+    s, _ = mcrExclude(s)
+    candidates = append(candidates, s)
+  }
+
+  nineGates := mcrNineGates(w)
+  for _, r := range handReadings(w) {
+    s := mcrSetFans(w, r)
+    if nineGates {
+      s.add("nine gates", 88)
+    }
+    mcrWinFans(w, &s, r.Wait, waitingTiles)
+    s, excluded := mcrExclude(s)
+
+    chows := make([]readingSet, 0, 4)
+    for _, set := range r.Sets {
+      if set.Kind == "seq" {
+        chows = append(chows, set)
+      }
+    }
+    for _, name := range mcrChowPairs(chows, excluded) {
+      s.add(name, 1)
+    }
+    candidates = append(candidates, s)
+  }
+
+  for _, s := range candidates {
+    if len(s.Patterns) == 0 {
+      s.add("chicken hand", 8)
+    }
+    if s.Points > best.Points || len(best.Patterns) == 0 {
+      best = s
+    }
+  }
+
+  if w.Rules.Flowers {
+    flowers := 0
+    for _, t := range w.Hand.Revealed {
+      if t.IsSpecial() {
+        flowers++
+      }
+    }
+    if flowers > 0 {
+      best.addBonus("flower tiles", flowers)
+    }
+  }
+  return best
+}
@@ -0,0 +1,190 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// taiwanese rules: 16-tile hands of five sets and a pair, and tai scoring
+package mahjong
+
+const (
+  // tiles left in the wall when the game is drawn
+  TaiwaneseReservedTiles = 16
+)
+
+func init() {
+  RulePresets["taiwanese"] = RuleSet{
+    Name: "taiwanese",
+    Flowers: true,
+    ChowAllowed: true,
+    WinOnAnySequence: true,
+    MinimumFaan: 0,
+    SpecialHands: []string{},
+    ClaimPriority: []string{ "win", "kong", "pong", "chow" },
+    ExhaustiveDraw: ExhaustiveDrawDealerStays,
+    Scoring: "taiwanese",
+    ReservedTiles: TaiwaneseReservedTiles,
+    HandSize: LongHandSize,
+    Players: PlayersInGame }
+
+  Scorers["taiwanese"] = scoreTaiwanese
+}
+
+// tai for the flowers: one for each matching the seat, two for each complete set of flowers or seasons
+func taiwaneseFlowers(w WinContext, s *ScoredWin) int {
+  flowers, seasons, bonusTiles := 0, 0, 0
+  for _, t := range w.Hand.Revealed {
+    if !t.IsSpecial() {
+      continue
+    }
+    bonusTiles++
+    if t.Value <= 4 {
+      flowers++
+    } else {
+      seasons++
+    }
+    if t.Value == w.SeatWind || t.Value == w.SeatWind+4 {
+      s.add("seat flower", 1)
+    }
+  }
+  if flowers == 4 {
+    s.add("four flowers", 2)
+  }
+  if seasons == 4 {
+    s.add("four seasons", 2)
+  }
+  return bonusTiles
+}
+
+// tai for one reading of the hand
+func taiwanesePatterns(w WinContext, r handReading, waitingTiles int) ScoredWin {
+  s := ScoredWin{ Scoring: "taiwanese", Unit: "tai" }
+  selfDrawn := w.Source == "draw"
+
+  if w.SeatWind == 1 {
+    s.add("dealer", 1)
+  }
+  switch {
+    case w.Hand.Concealed() && selfDrawn:
+      s.add("concealed self-drawn", 3)
+    case w.Hand.Concealed():
+      s.add("concealed hand", 1)
+    case selfDrawn:
+      s.add("self-drawn", 1)
+    case w.Hand.RevealedSets == w.Rules.SetsInHand():
+      s.add("all melded", 2)
+  }
+  if w.LastTile && selfDrawn {
+    s.add("last tile draw", 1)
+  } else if w.LastTile {
+    s.add("last discard", 1)
+  }
+  if w.KongReplacement {
+    s.add("win on a kong replacement", 1)
+  }
+  if w.RobbedKong {
+    s.add("robbing a kong", 1)
+  }
+  if waitingTiles == 1 {
+    s.add("single wait", 1)
+  }
+  bonusTiles := 0
+  if w.Rules.Flowers {
+    bonusTiles = taiwaneseFlowers(w, &s)
+  }
+
+  sequences, concealedTriplets, windTriplets, dragonTriplets := 0, 0, 0, 0
+  suits := map[int]bool{ r.EyeSuit: true }
+  for _, set := range r.Sets {
+    suits[set.Suit] = true
+    if set.Kind == "seq" {
+      sequences++
+      continue
+    }
+    if !set.Open {
+      concealedTriplets++
+    }
+    switch {
+      case set.Suit == 4 && set.Value >= 5:
+        dragonTriplets++
+        s.add("dragon", 1)
+      case set.Suit == 4:
+        windTriplets++
+        if set.Value == w.SeatWind {
+          s.add("seat wind", 1)
+        }
+        if set.Value == w.PrevailingWind {
+          s.add("prevailing wind", 1)
+        }
+    }
+  }
+
+  if sequences == len(r.Sets) && r.EyeSuit != 4 && bonusTiles == 0 && !selfDrawn && waitingTiles > 1 {
+    s.add("all sequences", 2)
+  }
+  if sequences == 0 {
+    s.add("all triplets", 4)
+  }
+  switch {
+    case concealedTriplets == 5:
+      s.add("five concealed triplets", 8)
+    case concealedTriplets == 4:
+      s.add("four concealed triplets", 5)
+    case concealedTriplets == 3:
+      s.add("three concealed triplets", 2)
+  }
+
+  eyeDragon, eyeWind := r.EyeSuit == 4 && r.EyeValue >= 5, r.EyeSuit == 4 && r.EyeValue <= 4
+  switch {
+    case dragonTriplets == 3:
+      s.add("big three dragons", 8)
+    case dragonTriplets == 2 && eyeDragon:
+      s.add("little three dragons", 4)
+  }
+  switch {
+    case windTriplets == 4:
+      s.add("big four winds", 16)
+    case windTriplets == 3 && eyeWind:
+      s.add("little four winds", 8)
+  }
+
+  switch {
+    case len(suits) == 1 && suits[4]:
+      s.add("all honors", 16)
+    case len(suits) == 1:
+      s.add("full flush", 8)
+    case len(suits) == 2 && suits[4]:
+      s.add("half flush", 4)
+  }
+  return s
+}
+
+// taiwanese scoring: the highest-scoring reading
+func scoreTaiwanese(w WinContext) ScoredWin {
+  best := ScoredWin{ Scoring: "taiwanese", Unit: "tai" }
+  waitingTiles := waitingTileCount(w)
+  for _, p := range w.Rules.SpecialWins(w.Hand, w.Consider) {
+    if points := p.PointsFor("taiwanese"); points > best.Points {
+      best = ScoredWin{ Scoring: "taiwanese", Unit: "tai" }
+      best.add(p.Name, points)
+    }
+  }
+  for _, r := range handReadings(w) {
+    if s := taiwanesePatterns(w, r, waitingTiles); s.Points > best.Points || len(best.Patterns) == 0 {
+      best = s
+    }
+  }
+  return best
+}
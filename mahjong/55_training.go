@@ -0,0 +1,174 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// discard efficiency training: deal hands, grade discards against the engine and track accuracy
+package mahjong
+
+import(
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// grade for one discard
+type DiscardGrade struct {
+  Choice DiscardOption
+  // every discard tied for best
+  Best []DiscardOption
+  Correct bool
+  Explanation string
+}
+
+// running totals for a training session
+type TrainingSession struct {
+  Hands int
+  Correct int
+  // accepting tiles given up across all non-optimal discards
+  UkeireLost int
+}
+
+// deal a hand from a shuffled wall, following the initial deal; the dealer's hand awaits a discard
+func DealTrainingHand() PlayerHand {
+  for {
+    g := New()
+    g.Initialize(-1, make([]bool, PlayersInGame, PlayersInGame))
+    h := g.Hands[g.StartPlayer]
+    // a dealt win leaves nothing to train
+    if !h.HaveWin(EmptyTile, "draw") {
+      return h
+    }
+  }
+}
+
+// grade a discard against the lowest shanten and, among those, the most accepting tiles
+func GradeDiscard(h PlayerHand, discard DiscardPile, hands []PlayerHand, choice Tile) DiscardGrade {
+  options := h.DiscardOptions(h.UnseenTileCounts(discard, hands))
+  grade := DiscardGrade{}
+
+  for _, option := range options {
+    if option.Shanten == options[0].Shanten && option.Ukeire == options[0].Ukeire {
+      grade.Best = append(grade.Best, option)
+    }
+    if option.Item.Suit == choice.Suit && option.Item.Value == choice.Value {
+      grade.Choice = option
+    }
+  }
+
+  best := options[0]
+  grade.Correct = grade.Choice.Shanten == best.Shanten && grade.Choice.Ukeire == best.Ukeire
+
+  bestTiles := ""
+  for _, option := range grade.Best {
+    bestTiles += option.Item.Ud
+  }
+
+  switch {
+    case grade.Correct:
+      grade.Explanation = fmt.Sprintf("optimal: your discard leaves %d accepting tiles at %d shanten", grade.Choice.Ukeire, grade.Choice.Shanten)
+    case grade.Choice.Shanten > best.Shanten:
+      grade.Explanation = fmt.Sprintf("your discard moves the hand to %d shanten; the best (%s) keeps it at %d shanten with %d accepting tiles", grade.Choice.Shanten, bestTiles, best.Shanten, best.Ukeire)
+    default:
+      grade.Explanation = fmt.Sprintf("your discard leaves %d accepting tiles, best (%s) leaves %d", grade.Choice.Ukeire, bestTiles, best.Ukeire)
+  }
+  return grade
+}
+
+// add a grade to the session totals
+func (s *TrainingSession) Record(grade DiscardGrade) {
+  s.Hands++
+  if grade.Correct {
+    s.Correct++
+  } else if grade.Choice.Shanten == grade.Best[0].Shanten {
+    s.UkeireLost += grade.Best[0].Ukeire - grade.Choice.Ukeire
+  }
+}
+
+// share of optimal discards
+func (s TrainingSession) Accuracy() float64 {
+  if s.Hands == 0 {
+    return 0
+  }
+  return float64(s.Correct) / float64(s.Hands)
+}
+
+// find the tile chosen by hand position, notation or glyph
+func trainingChoice(h PlayerHand, input string) (Tile, bool) {
+  if position, err := strconv.Atoi(input); err == nil {
+    if position >= 0 && position < len(h.Hidden) && h.Hidden[position] != EmptyTile {
+      return h.Hidden[position], true
+    }
+    return EmptyTile, false
+  }
+
+  tiles, err := NewTilePool().Parse(input)
+  if err != nil || len(tiles) != 1 {
+    return EmptyTile, false
+  }
+  for _, t := range h.Hidden {
+    if t.Suit == tiles[0].Suit && t.Value == tiles[0].Value {
+      return t, true
+    }
+  }
+  return EmptyTile, false
+}
+
+// run an interactive session of the given number of hands; q ends the session early
+func (s *TrainingSession) Play(rounds int) {
+  for round := 0; round < rounds; round++ {
+    h := DealTrainingHand()
+    h.Sort()
+
+    fmt.Printf("Hand %d of %d\n", round+1, rounds)
+    h.OutputHand(true, true)
+    helperLine := ""
+    for i := 0; i < len(h.Hidden); i++ {
+      if h.Hidden[i] != EmptyTile {
+        helperLine += fmt.Sprintf("(%s%d)", h.Hidden[i].Ud, i)
+      }
+    }
+
+    var choice Tile
+    for {
+      var input string
+      fmt.Printf("%s\n", helperLine)
+      fmt.Printf("What do you discard? (#, notation or tile; q to stop)\n")
+      fmt.Scanln(&input)
+      input = strings.TrimSpace(input)
+
+      if input == "q" {
+        return
+      }
+      var valid bool
+      if choice, valid = trainingChoice(h, input); valid {
+        break
+      }
+      fmt.Printf("Invalid selection %q.\n", input)
+    }
+
+    grade := GradeDiscard(h, DiscardPile{}, []PlayerHand{ h }, choice)
+    s.Record(grade)
+
+    fmt.Printf("%s\n", grade.Explanation)
+    fmt.Printf("Session: %d of %d optimal (%.0f%%)\n\n", s.Correct, s.Hands, 100*s.Accuracy())
+  }
+}
+
+// output session totals
+func (s TrainingSession) OutputSummary() {
+  fmt.Printf("Training complete: %d of %d discards optimal (%.0f%%); %d accepting tiles given up on same-shanten discards\n", s.Correct, s.Hands, 100*s.Accuracy(), s.UkeireLost)
+}
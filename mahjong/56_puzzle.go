@@ -0,0 +1,263 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// "what to discard" and "what wins" puzzles generated with seeded randomness
+package mahjong
+
+import(
+  "errors"
+  "fmt"
+  insecureRand "math/rand"
+  "strconv"
+  "strings"
+)
+
+const (
+  // given 14 tiles, pick the best discard
+  PuzzleDiscard = "discard"
+  // given 13 tiles, name all waits
+  PuzzleWaits = "waits"
+
+  // accepting tiles by which the best discard must lead when the shanten number ties
+  PuzzleUkeireMargin = 4
+  // generation attempts before giving up on a seed
+  PuzzleAttempts = 5000
+)
+
+// puzzle with its solution
+type Puzzle struct {
+  Kind string
+  Seed int64
+  Hand []Tile
+  // discard puzzles: every discard, best first
+  Ranking []DiscardOption
+  // waits puzzles: every winning tile and the splits it completes
+  Waits []WaitingTile
+  Decompositions []HandDecomposition
+}
+
+// solve a puzzle hand; the kind follows from the number of tiles
+func SolvePuzzle(hand []Tile) (Puzzle, error) {
+  h := PlayerHand{ Hidden: hand }
+  unseen := h.UnseenTileCounts(DiscardPile{}, []PlayerHand{ h })
+  p := Puzzle{ Hand: hand }
+
+  switch len(hand) {
+    case 14:
+      p.Kind = PuzzleDiscard
+      if h.HaveWin(EmptyTile, "draw") {
+        return p, errors.New("the hand is already complete")
+      }
+      p.Ranking = h.DiscardOptions(unseen)
+    case 13:
+      p.Kind = PuzzleWaits
+      p.Waits = h.Waits(unseen)
+      tileCounts, _, _ := h.CountHiddenTiles(EmptyTile)
+      for _, wait := range p.Waits {
+        tileCounts[wait.Item.Suit-1][wait.Item.Value]++
+        p.Decompositions = append(p.Decompositions, Decompositions(tileCounts)...)
+        tileCounts[wait.Item.Suit-1][wait.Item.Value]--
+      }
+    default:
+      return p, fmt.Errorf("a puzzle has 13 or 14 tiles, not %d", len(hand))
+  }
+  return p, nil
+}
+
+// determine if the answer is unique or clearly ranked
+func (p Puzzle) WellPosed() bool {
+  switch p.Kind {
+    case PuzzleDiscard:
+      if len(p.Ranking) < 2 {
+        return len(p.Ranking) == 1
+      }
+      best, next := p.Ranking[0], p.Ranking[1]
+      return best.Shanten < next.Shanten || best.Ukeire >= next.Ukeire+PuzzleUkeireMargin
+    case PuzzleWaits:
+      // a single wait is too easy to be of interest
+      return len(p.Waits) >= 2
+  }
+  return false
+}
+
+// build a complete hand of four sets and an eye from the shuffled tiles
+func completePuzzleHand(rng *insecureRand.Rand, available [][]int) []Tile {
+  counts := make([][]int, 4, 4)
+  for i := 0; i < 4; i++ {
+    counts[i] = make([]int, 10, 10)
+    copy(counts[i], available[i])
+  }
+
+  hand := make([]Tile, 0, 14)
+  take := func(suit int, value int) {
+    counts[suit][value]--
+    hand = append(hand, NewTile(suit+1, value, 0))
+  }
+
+  for sets := 0; sets < 4; {
+    suit := rng.Intn(4)
+    if suit != 3 && rng.Intn(3) > 0 {
+      value := rng.Intn(7)+1
+      if counts[suit][value] > 0 && counts[suit][value+1] > 0 && counts[suit][value+2] > 0 {
+        take(suit, value); take(suit, value+1); take(suit, value+2)
+        sets++
+      }
+    } else {
+      value := rng.Intn(MaxTileIndex[suit])+1
+      if counts[suit][value] >= 3 {
+        take(suit, value); take(suit, value); take(suit, value)
+        sets++
+      }
+    }
+  }
+  for {
+    suit := rng.Intn(4)
+    value := rng.Intn(MaxTileIndex[suit])+1
+    if counts[suit][value] >= 2 {
+      take(suit, value); take(suit, value)
+      break
+    }
+  }
+  return hand
+}
+
+// generate a well-posed puzzle; the same kind and seed always yield the same puzzle
+func GeneratePuzzle(kind string, seed int64) (Puzzle, error) {
+  if kind != PuzzleDiscard && kind != PuzzleWaits {
+    return Puzzle{}, fmt.Errorf("unknown puzzle kind %q", kind)
+  }
+  rng := insecureRand.New(insecureRand.NewSource(seed))
+  full := newTileCounts()
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      full[i][j] = 4
+    }
+  }
+
+  for attempt := 0; attempt < PuzzleAttempts; attempt++ {
+    hand := completePuzzleHand(rng, full)
+    rng.Shuffle(len(hand), func(a, b int) { hand[a], hand[b] = hand[b], hand[a] })
+
+    if kind == PuzzleWaits {
+      // remove one tile from a complete hand to leave it ready
+      hand = hand[1:]
+    } else {
+      // swap two tiles for random tiles to leave work to do
+      used := newTileCounts()
+      for _, t := range hand[2:] {
+        used[t.Suit-1][t.Value]++
+      }
+      hand = hand[2:]
+      for len(hand) < 14 {
+        suit := rng.Intn(4)
+        value := rng.Intn(MaxTileIndex[suit])+1
+        if used[suit][value] < 4 {
+          hand = append(hand, NewTile(suit+1, value, 0))
+          used[suit][value]++
+        }
+      }
+    }
+
+    // distinct tile ids, in hand order
+    pool := NewTilePool()
+    for i, t := range hand {
+      hand[i], _ = pool.Take(t.Suit, t.Value)
+    }
+    sortTiles(hand)
+    
+    p, err := SolvePuzzle(hand)
+    if err == nil && p.WellPosed() {
+      p.Seed = seed
+      return p, nil
+    }
+  }
+  return Puzzle{}, fmt.Errorf("no well-posed %s puzzle found for seed %d", kind, seed)
+}
+
+// answer in tile notation: the best discard or every wait
+func (p Puzzle) Answer() string {
+  if p.Kind == PuzzleDiscard {
+    return p.Ranking[0].Item.Notation()
+  }
+  waits := make([]Tile, 0, len(p.Waits))
+  for _, wait := range p.Waits {
+    waits = append(waits, wait.Item)
+  }
+  return TilesNotation(waits)
+}
+
+// shareable line: kind, hand, seed and, optionally, the answer
+func (p Puzzle) Notation(withAnswer bool) string {
+  line := fmt.Sprintf("%s %s seed=%d", p.Kind, TilesNotation(p.Hand), p.Seed)
+  if withAnswer {
+    line += " answer=" + p.Answer()
+  }
+  return line
+}
+
+// read a shared puzzle line and solve it again
+func ParsePuzzle(line string) (Puzzle, error) {
+  fields := strings.Fields(line)
+  if len(fields) < 2 {
+    return Puzzle{}, fmt.Errorf("expected a kind and a hand in %q", line)
+  }
+  hand, err := NewTilePool().Parse(fields[1])
+  if err != nil {
+    return Puzzle{}, err
+  }
+  p, err := SolvePuzzle(hand)
+  if err != nil {
+    return p, err
+  }
+  if p.Kind != fields[0] {
+    return p, fmt.Errorf("a %s puzzle cannot have %d tiles", fields[0], len(hand))
+  }
+  for _, field := range fields[2:] {
+    if strings.HasPrefix(field, "seed=") {
+      p.Seed, _ = strconv.ParseInt(strings.TrimPrefix(field, "seed="), 10, 64)
+    }
+  }
+  return p, nil
+}
+
+// output the puzzle and, optionally, its solution
+func (p Puzzle) OutputPuzzle(showAnswer bool) {
+  if p.Kind == PuzzleDiscard {
+    fmt.Printf("What do you discard? %s\n", tileGlyphs(p.Hand))
+  } else {
+    fmt.Printf("What wins? %s\n", tileGlyphs(p.Hand))
+  }
+  fmt.Printf("  %s\n", p.Notation(false))
+
+  if !showAnswer {
+    return
+  }
+  if p.Kind == PuzzleDiscard {
+    best := p.Ranking[0]
+    fmt.Printf("  answer: %v (%s), shanten %d with %d accepting tiles (%s)\n", best.Item.Ud, best.Item.Notation(), best.Shanten, best.Ukeire, tileGlyphs(best.Accepted))
+    if len(p.Ranking) > 1 {
+      next := p.Ranking[1]
+      fmt.Printf("  next best: %v (%s), shanten %d with %d accepting tiles\n", next.Item.Ud, next.Item.Notation(), next.Shanten, next.Ukeire)
+    }
+  } else {
+    fmt.Printf("  answer: %s (%s)\n", formatWaits(p.Waits), p.Answer())
+    for _, d := range p.Decompositions {
+      fmt.Printf("    %v\n", d)
+    }
+  }
+}
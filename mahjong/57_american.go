@@ -0,0 +1,598 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// american rules: jokers, the charleston and a card of allowed hands read from a data file
+package mahjong
+
+import(
+  "fmt"
+  "io/ioutil"
+  "log"
+  "strconv"
+  "strings"
+)
+
+// # card
+// a hand of the card: groups of tiles, numbered groups taking the suit of their variable
+type CardHand struct {
+  Name string
+  // symbols followed by an optional suit variable, e.g., 222a, FFFF or NEWS
+  Groups []string
+  // only won with no exposed sets
+  Concealed bool
+  // the numbers may be moved up together, e.g., any run of consecutive numbers
+  Shift bool
+  Value int
+  // tiles needed for each choice of suits and shift
+  variants []cardNeed
+}
+
+// tiles needed by a card hand, by suit (1 to 5, flowers under value 0) and value
+type cardNeed struct {
+  // tiles of pairs, singles and mixed groups, which jokers cannot stand in for
+  natural [6][10]int
+  // tiles of groups of three or more alike
+  jokerable [6][10]int
+}
+
+// tiles held towards a card hand, counted as in cardNeed, and jokers
+type cardTiles struct {
+  counts [6][10]int
+  jokers int
+}
+
+const (
+  // symbols of a card group: numbers, winds, dragons (0 being the white), the dragon of the group's suit and flowers
+  CardSymbols = "123456789NESWRG0DF"
+  // suit variables; distinct variables are distinct suits
+  CardSuitVariables = "abc"
+  // tiles passed in each pass of the charleston
+  CharlestonTiles = 3
+  // passes of the first charleston, which is always played
+  CharlestonFirstPasses = 3
+)
+
+// seats each pass of the charleston goes to, by offset from the passing player: right, across and left, then back for the second charleston
+var CharlestonOffsets []int
+
+func init() {
+  CharlestonOffsets = []int{ 1, 2, 3, 3, 2, 1 }
+
+  RulePresets["american"] = RuleSet{
+    Name: "american",
+    Flowers: true,
+    ChowAllowed: false,
+    MinimumFaan: 0,
+    SpecialHands: []string{},
+    ClaimPriority: []string{ "win", "kong", "pong" },
+    ExhaustiveDraw: ExhaustiveDrawRotate,
+    Scoring: "american",
+    HandSize: StandardHandSize,
+    Players: PlayersInGame,
+    Jokers: MaxJokers,
+    FlowersHeld: true,
+    Charleston: true,
+    Card: "cards/american.card" }
+
+  Scorers["american"] = scoreAmerican
+}
+
+// read a card file (see ParseCard)
+func LoadCard(path string) ([]CardHand, error) {
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  hands, err := ParseCard(string(data))
+  if err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  return hands, nil
+}
+
+// read a card: one hand per line as "name: groups ; value flags", with # starting a comment
+// flags: c for a concealed hand, x for a hand that may be exposed (the default), shift for numbers that may be moved up together
+func ParseCard(data string) ([]CardHand, error) {
+  hands := make([]CardHand, 0, 64)
+  for number, line := range strings.Split(data, "\n") {
+    if comment := strings.Index(line, "#"); comment >= 0 {
+      line = line[:comment]
+    }
+    line = strings.TrimSpace(line)
+    if line == "" {
+      continue
+    }
+    hand, err := parseCardHand(line)
+    if err != nil {
+      return nil, fmt.Errorf("line %d: %v", number+1, err)
+    }
+    hands = append(hands, hand)
+  }
+  if len(hands) == 0 {
+    return nil, fmt.Errorf("the card has no hands")
+  }
+  return hands, nil
+}
+
+// parse one line of a card
+func parseCardHand(line string) (CardHand, error) {
+  colon := strings.Index(line, ":")
+  semicolon := strings.LastIndex(line, ";")
+  if colon < 1 || semicolon < colon {
+    return CardHand{}, fmt.Errorf("expected name: groups ; value flags")
+  }
+  hand := CardHand{ Name: strings.TrimSpace(line[:colon]), Groups: strings.Fields(line[colon+1:semicolon]) }
+
+  for _, flag := range strings.Fields(line[semicolon+1:]) {
+    switch flag {
+      case "c":
+        hand.Concealed = true
+      case "x":
+        hand.Concealed = false
+      case "shift":
+        hand.Shift = true
+      default:
+        value, err := strconv.Atoi(flag)
+        if err != nil || value <= 0 {
+          return CardHand{}, fmt.Errorf("%s: unknown flag %q", hand.Name, flag)
+        }
+        hand.Value = value
+    }
+  }
+  if hand.Value == 0 {
+    return CardHand{}, fmt.Errorf("%s has no value", hand.Name)
+  }
+
+  tiles := 0
+  for _, group := range hand.Groups {
+    symbols, variable := splitCardGroup(group)
+    if symbols == "" {
+      return CardHand{}, fmt.Errorf("%s: group %s has no tiles", hand.Name, group)
+    }
+    for _, symbol := range symbols {
+      if !strings.ContainsRune(CardSymbols, symbol) {
+        return CardHand{}, fmt.Errorf("%s: unknown symbol %c in group %s", hand.Name, symbol, group)
+      }
+      if variable == "" && (symbol == 'D' || (symbol >= '1' && symbol <= '9')) {
+        return CardHand{}, fmt.Errorf("%s: group %s needs a suit (a, b or c)", hand.Name, group)
+      }
+    }
+    tiles += len(symbols)
+  }
+  if tiles != StandardHandSize+1 {
+    return CardHand{}, fmt.Errorf("%s has %d tiles, not %d", hand.Name, tiles, StandardHandSize+1)
+  }
+
+  hand.variants = hand.expand()
+  return hand, nil
+}
+
+// symbols and suit variable of a group
+func splitCardGroup(group string) (string, string) {
+  if last := group[len(group)-1:]; strings.Contains(CardSuitVariables, last) {
+    return group[:len(group)-1], last
+  }
+  return group, ""
+}
+
+// tiles needed for every choice of distinct suits for the variables and, for a shifted hand, every shift keeping the numbers within 1 to 9
+func (c CardHand) expand() []cardNeed {
+  variables := ""
+  highest := 0
+  for _, group := range c.Groups {
+    symbols, variable := splitCardGroup(group)
+    if variable != "" && !strings.Contains(variables, variable) {
+      variables += variable
+    }
+    for _, symbol := range symbols {
+      if symbol >= '1' && symbol <= '9' && int(symbol-'0') > highest {
+        highest = int(symbol-'0')
+      }
+    }
+  }
+  shifts := 0
+  if c.Shift && highest > 0 {
+    shifts = 9-highest
+  }
+
+  needs := make([]cardNeed, 0, 6*(shifts+1))
+  for _, suits := range suitChoices(len(variables)) {
+    for shift := 0; shift <= shifts; shift++ {
+      var need cardNeed
+      for _, group := range c.Groups {
+        symbols, variable := splitCardGroup(group)
+        suit := 0
+        if variable != "" {
+          suit = suits[strings.Index(variables, variable)]
+        }
+        alike := len(symbols) >= 3 && strings.Count(symbols, symbols[:1]) == len(symbols)
+        for _, symbol := range symbols {
+          s, v := cardTile(symbol, suit, shift)
+          if alike {
+            need.jokerable[s][v]++
+          } else {
+            need.natural[s][v]++
+          }
+        }
+      }
+      needs = append(needs, need)
+    }
+  }
+  return needs
+}
+
+// every ordered choice of distinct standard suits for the given number of variables
+func suitChoices(variables int) [][]int {
+  if variables == 0 {
+    return [][]int{ {} }
+  }
+  choices := make([][]int, 0, 6)
+  for _, rest := range suitChoices(variables-1) {
+    for suit := 1; suit <= 3; suit++ {
+      used := false
+      for _, s := range rest {
+        used = used || s == suit
+      }
+      if !used {
+        choices = append(choices, append(append([]int{}, rest...), suit))
+      }
+    }
+  }
+  return choices
+}
+
+// suit and value of a card symbol in the given suit; flowers are all alike, counted under value 0
+func cardTile(symbol rune, suit int, shift int) (int, int) {
+  switch symbol {
+    case 'E':
+      return 4, 1
+    case 'S':
+      return 4, 2
+    case 'W':
+      return 4, 3
+    case 'N':
+      return 4, 4
+    case 'R':
+      return 4, 5
+    case 'G':
+      return 4, 6
+    case '0':
+      return 4, 7
+    case 'D':
+      // red with characters, green with bamboo, white with dots
+      return 4, []int{ 0, 7, 6, 5 }[suit]
+    case 'F':
+      return 5, 0
+  }
+  return suit, int(symbol-'0')+shift
+}
+
+// determine if jokers may be used anywhere in the hand
+func (c CardHand) TakesJokers() bool {
+  for _, group := range c.Groups {
+    symbols, _ := splitCardGroup(group)
+    if len(symbols) >= 3 && strings.Count(symbols, symbols[:1]) == len(symbols) {
+      return true
+    }
+  }
+  return false
+}
+
+// # matching
+// count a tile (or take it away with a negative count)
+func (c *cardTiles) add(t Tile, count int) {
+  switch {
+    case t == EmptyTile:
+    case t.IsJoker():
+      c.jokers += count
+    case t.IsSpecial():
+      c.counts[5][0] += count
+    default:
+      c.counts[t.Suit][t.Value] += count
+  }
+}
+
+// tiles of the hand, with an optional extra tile, including the exposed sets
+func (h PlayerHand) heldCardTiles(consider Tile) cardTiles {
+  var held cardTiles
+  held.add(consider, 1)
+  for _, t := range h.Hidden {
+    held.add(t, 1)
+  }
+  publicCounts, _, _ := h.CountPublicSetTiles()
+  for i := 0; i < 4; i++ {
+    for j := 1; j < 10; j++ {
+      held.counts[i+1][j] += publicCounts[i][j]
+    }
+  }
+  return held
+}
+
+// tiles missing for one choice of a card hand: natural tiles first cover the tiles jokers cannot stand in for, then jokers fill the rest
+func (c cardTiles) missing(need cardNeed) int {
+  missing, jokersNeeded := 0, 0
+  for i := 1; i <= 5; i++ {
+    for j := 0; j < 10; j++ {
+      held := c.counts[i][j]
+      if held < need.natural[i][j] {
+        missing += need.natural[i][j]-held
+        held = 0
+      } else {
+        held -= need.natural[i][j]
+      }
+      if held < need.jokerable[i][j] {
+        jokersNeeded += need.jokerable[i][j]-held
+      }
+    }
+  }
+  if jokersNeeded > c.jokers {
+    missing += jokersNeeded-c.jokers
+  }
+  return missing
+}
+
+// closest card hand: the tiles it is missing and the hand, the most valuable on a tie; concealed hands are skipped once a set is exposed
+func (c cardTiles) closest(hands []CardHand, exposed bool) (int, CardHand) {
+  best, bestHand := StandardHandSize+2, CardHand{}
+  for _, hand := range hands {
+    if hand.Concealed && exposed {
+      continue
+    }
+    for _, need := range hand.variants {
+      if missing := c.missing(need); missing < best || (missing == best && hand.Value > bestHand.Value) {
+        best, bestHand = missing, hand
+      }
+    }
+  }
+  return best, bestHand
+}
+
+// tiles the hand, with an optional extra tile, is missing for the closest hand of the card
+func (h PlayerHand) CardDistance(consider Tile) (int, CardHand) {
+  return h.heldCardTiles(consider).closest(h.ruleSet().CardHands, h.RevealedSets > 0)
+}
+
+// determine if the hand, with an optional extra tile, is a hand of the card; a discarded joker is dead
+func (h PlayerHand) haveCardWin(consider Tile, tileSource string) bool {
+  if consider.IsJoker() && tileSource != "draw" {
+    return false
+  }
+  distance, hand := h.CardDistance(consider)
+  if VerboseDebug {
+    fmt.Printf("[vd] Player %d is %d tiles from %s\n", h.Player, distance, hand.Name)
+  }
+  return distance == 0
+}
+
+// american scoring: the value of the card hand, doubled when self-drawn and doubled again without jokers
+func scoreAmerican(w WinContext) ScoredWin {
+  s := ScoredWin{ Scoring: "american", Unit: "points" }
+  held := w.Hand.heldCardTiles(w.Consider)
+  distance, hand := held.closest(w.Rules.CardHands, w.Hand.RevealedSets > 0)
+  if distance > 0 {
+    return s
+  }
+  s.add(hand.Name, hand.Value)
+  if w.Source == "draw" {
+    s.add("self-drawn", s.Points)
+  }
+  // hands of pairs and singles are played without jokers anyway
+  if held.jokers == 0 && hand.TakesJokers() {
+    s.add("jokerless", s.Points)
+  }
+  return s
+}
+
+// # computer player
+// computer player: what to discard under a card?
+// naively, the tile leaving the hand closest to a hand of the card; jokers are kept
+func (h PlayerHand) cardDiscard() int {
+  held := h.heldCardTiles(EmptyTile)
+  exposed := h.RevealedSets > 0
+  position, best := -1, 0
+  for i, t := range h.Hidden {
+    if t == EmptyTile || (t.IsJoker() && position >= 0) {
+      continue
+    }
+    held.add(t, -1)
+    distance, _ := held.closest(h.ruleSet().CardHands, exposed)
+    held.add(t, 1)
+    if position < 0 || h.Hidden[position].IsJoker() || distance < best {
+      position, best = i, distance
+    }
+  }
+  if position < 0 {
+    position = 0
+  }
+  return position
+}
+
+// computer player: claim a discard under a card?
+// naively, if it brings the hand closer to a hand of the card that may be exposed
+func (h PlayerHand) cardClaim(discard []DiscardedTile) string {
+  if len(discard) == 0 {
+    return "n"
+  }
+  held := h.heldCardTiles(EmptyTile)
+  before, _ := held.closest(h.ruleSet().CardHands, h.RevealedSets > 0)
+  held.add(discard[len(discard)-1].Item, 1)
+  after, _ := held.closest(h.ruleSet().CardHands, true)
+  if after < before {
+    return "y"
+  }
+  return "n"
+}
+
+// computer player: which tiles to pass in the charleston?
+// naively, one at a time, the tile leaving the hand closest to a hand of the card; jokers are never passed
+func (h PlayerHand) CharlestonChoice() []int {
+  held := h.heldCardTiles(EmptyTile)
+  chosen := make([]int, 0, CharlestonTiles)
+  for len(chosen) < CharlestonTiles {
+    position, best := -1, 0
+    for i, t := range h.Hidden {
+      if t == EmptyTile || t.IsJoker() || containsPosition(chosen, i) {
+        continue
+      }
+      held.add(t, -1)
+      distance, _ := held.closest(h.ruleSet().CardHands, false)
+      held.add(t, 1)
+      if position < 0 || distance < best {
+        position, best = i, distance
+      }
+    }
+    if position < 0 {
+      break
+    }
+    held.add(h.Hidden[position], -1)
+    chosen = append(chosen, position)
+  }
+  return chosen
+}
+
+// computer player: play the second charleston?
+// naively, yes
+func (h PlayerHand) TakeCharleston() string {
+  return "y"
+}
+
+// # charleston
+// one pass of the charleston: every player passes three tiles, then receives three; play then begins with the dealer
+func (g *Game) processCharleston(curState StateUnit) StateUnit {
+  afterCharleston := StateUnit { Player: g.StartPlayer, State: "HaveWin", Phase: "DrawProcessing" }
+
+  // the second charleston is only played if every player agrees
+  if g.CharlestonPass == CharlestonFirstPasses {
+    for i := 0; i < g.Rules.Players; i++ {
+      player := (g.StartPlayer+i) % g.Rules.Players
+      agreed := false
+      if !g.Hands[player].ComputerPlayer {
+        g.handToPlayer(player)
+        g.ShowGameState(false, player, false)
+        agreed = g.promptAccept(player, "charleston", fmt.Sprintf("Player %d: Do you want a second charleston?", player))
+      } else {
+        agreed = g.Hands[player].TakeCharleston() == "y"
+      }
+      if !agreed {
+        g.LogAction(player, "charleston", nil, "stop", fmt.Sprintf("player %d stops the charleston", player))
+        g.CharlestonPass = len(CharlestonOffsets)
+        return afterCharleston
+      }
+    }
+  }
+  if g.CharlestonPass >= len(CharlestonOffsets) {
+    return afterCharleston
+  }
+
+  offset := CharlestonOffsets[g.CharlestonPass]
+  direction := RelativeSeatLabels[offset]
+  passes := make([][]Tile, g.Rules.Players, g.Rules.Players)
+  for i := 0; i < g.Rules.Players; i++ {
+    player := (g.StartPlayer+i) % g.Rules.Players
+    positions := g.Hands[player].CharlestonChoice()
+    if !g.Hands[player].ComputerPlayer {
+      g.handToPlayer(player)
+      g.ShowGameState(false, player, false)
+      positions = g.promptPass(player, direction, positions)
+    }
+    for _, position := range positions {
+      passes[player] = append(passes[player], g.Hands[player].Hidden[position])
+      g.Hands[player].Hidden[position] = EmptyTile
+    }
+  }
+
+  for player, tiles := range passes {
+    receiver := (player+offset) % g.Rules.Players
+    for _, t := range tiles {
+      if err := g.Hands[receiver].Receive(t); err != nil {
+        log.Fatal(err)
+      }
+    }
+    g.LogAction(player, "pass", tiles, direction, fmt.Sprintf("player %d passes %d tiles to player %d", player, len(tiles), receiver))
+    if VerboseDebug {
+      fmt.Printf("[vd] Player %d passed %v to player %d\n", player, tiles, receiver)
+    }
+  }
+  g.CharlestonPass++
+  return curState
+}
+
+// ask a human player which tiles to pass; an empty response takes the suggestion
+func (g *Game) promptPass(player int, direction string, suggestion []int) []int {
+  hidden := g.Hands[player].Hidden
+
+  if g.Tui != nil {
+    // one tile at a time, each chosen tile set aside until all are chosen
+    positions := make([]int, 0, CharlestonTiles)
+    chosen := make([]Tile, 0, CharlestonTiles)
+    for len(positions) < CharlestonTiles {
+      suggested := -1
+      for _, position := range suggestion {
+        if suggested < 0 && hidden[position] != EmptyTile {
+          suggested = position
+        }
+      }
+      position := g.Tui.ChoosePass(g, player, direction, suggested)
+      if hidden[position] == EmptyTile || hidden[position].IsJoker() {
+        continue
+      }
+      positions = append(positions, position)
+      chosen = append(chosen, hidden[position])
+      hidden[position] = EmptyTile
+    }
+    for i, position := range positions {
+      hidden[position] = chosen[i]
+    }
+    return positions
+  }
+
+  helperLine := ""
+  for i := 0; i < len(hidden); i++ {
+    if hidden[i] != EmptyTile {
+      helperLine += fmt.Sprintf("(%s%d)", hidden[i].Ud, i)
+    }
+  }
+  suggestionLine := make([]string, len(suggestion), len(suggestion))
+  for i, position := range suggestion {
+    suggestionLine[i] = strconv.Itoa(position)
+  }
+
+  for {
+    var input string
+    fmt.Printf("%s\n", helperLine)
+    fmt.Printf("Player %d: Which %d tiles do you pass %s? #,#,# [%s]\n", player, CharlestonTiles, direction, strings.Join(suggestionLine, ","))
+    fmt.Scanln(&input)
+
+    if len(input) == 0 {
+      return suggestion
+    }
+    positions := make([]int, 0, CharlestonTiles)
+    for _, field := range strings.Split(input, ",") {
+      position, err := strconv.Atoi(strings.TrimSpace(field))
+      if err != nil || position < 0 || position >= len(hidden) || hidden[position] == EmptyTile || hidden[position].IsJoker() || containsPosition(positions, position) {
+        positions = nil
+        break
+      }
+      positions = append(positions, position)
+    }
+    if len(positions) == CharlestonTiles {
+      return positions
+    }
+    fmt.Printf("Invalid selection %q; please enter %d different numbers shown after the tiles, separated by commas. Jokers cannot be passed.\n", input, CharlestonTiles)
+  }
+}
@@ -0,0 +1,537 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// special hands: a pattern language for winning hands outside of four sets and a pair
+package mahjong
+
+import(
+  "fmt"
+  "log"
+  "strconv"
+  "strings"
+  "sync"
+)
+
+// a special winning hand: terms that together use every tile of the hand
+type HandPattern struct {
+  Name string
+  Terms []patternTerm
+  // points in the unit of the scoring system; Points unless the system has its own
+  Points int
+  ScoringPoints map[string]int
+  // only won with no revealed sets
+  Concealed bool
+  // repeated terms take different tiles, e.g., seven different pairs
+  Distinct bool
+  // suit variables used, in order of appearance
+  variables string
+}
+
+// one term of a pattern
+type patternTerm struct {
+  // literal, tile, pair, pung, chow, set or only
+  Kind string
+  // tiles of a literal, as a card group
+  Tiles patternRun
+  // tiles a wildcard may take, or every tile must be, for only; empty for any tile
+  Class []patternRun
+  Repeat int
+}
+
+// symbols in a suit: p, s or m, or a suit variable a, b or c; no suit for honors
+type patternRun struct {
+  Symbols string
+  Suit string
+}
+
+// a term with its suits chosen: tiles a wildcard may take, by suit index and value
+type resolvedTerm struct {
+  kind string
+  class [4][10]bool
+  repeat int
+}
+
+const (
+  // suits named in a pattern: dots, bamboo and characters, then the suit variables
+  PatternSuits = "psmabc"
+)
+
+// pattern kinds taking tiles other than literals
+var PatternWildcards map[string]bool
+// patterns of the special hands that may be listed in specialHands
+var BuiltinHandPatterns map[string]string
+// parsed patterns by their text, shared by games played at once
+var parsedPatterns map[string]HandPattern
+var parsedPatternsLock sync.Mutex
+
+func init() {
+  PatternWildcards = map[string]bool{ "tile": true, "pair": true, "pung": true, "chow": true, "set": true }
+  parsedPatterns = make(map[string]HandPattern)
+
+  BuiltinHandPatterns = make(map[string]string)
+  BuiltinHandPatterns["thirteenOrphans"] = "thirteen orphans: 19a 19b 19c ESWNRG0 tile[19a19b19cESWNRG0] ; 13 mcr=88 taiwanese=16 c"
+  BuiltinHandPatterns["sevenPairs"] = "seven pairs: pair*7 ; 4 riichi=2 mcr=24 c distinct"
+  // four of a tile count as two of the pairs
+  BuiltinHandPatterns["sevenPairsDoubled"] = "seven pairs: pair*7 ; 4 riichi=2 mcr=24 c"
+  BuiltinHandPatterns["nineGates"] = "nine gates: 1112345678999a tile[a] ; 13 mcr=88 c"
+  BuiltinHandPatterns["allGreen"] = "all green: only[23468sG] set*4 pair ; 13 mcr=88"
+  BuiltinHandPatterns["knittedStraight"] = "knitted straight: 147a 258b 369c set pair ; 12"
+  BuiltinHandPatterns["lesserHonorsKnitted"] = "lesser honors and knitted tiles: tile[147a258b369cESWNRG0]*14 ; 12 c distinct"
+  BuiltinHandPatterns["greaterHonorsKnitted"] = "greater honors and knitted tiles: ESWNRG0 tile[147a258b369c]*7 ; 24 c distinct"
+
+  for name := range BuiltinHandPatterns {
+    KnownSpecialHands[name] = true
+  }
+}
+
+// # parsing
+// read a pattern: "name: terms ; points flags"
+// terms are literals written as card groups (e.g., 19a or ESWNRG0, with p, s and m for fixed suits as well as the variables a, b and c)
+// and wildcards tile, pair, pung, chow and set, optionally limited to tiles in brackets (e.g., pair[a] or tile[19aESWNRG0]) and repeated (e.g., pair*7)
+// only[...] requires every tile of the hand to be among those in brackets
+// flags: points as a number for any scoring and as scoring=number for one system, c for a concealed hand, distinct for repeated terms taking different tiles
+func ParseHandPattern(text string) (HandPattern, error) {
+  parsedPatternsLock.Lock()
+  p, found := parsedPatterns[text]
+  parsedPatternsLock.Unlock()
+  if found {
+    return p, nil
+  }
+
+  colon := strings.Index(text, ":")
+  semicolon := strings.LastIndex(text, ";")
+  if colon < 1 || semicolon < colon {
+    return HandPattern{}, fmt.Errorf("pattern %q: expected name: terms ; points flags", text)
+  }
+  p = HandPattern{ Name: strings.TrimSpace(text[:colon]), ScoringPoints: make(map[string]int) }
+
+  for _, field := range strings.Fields(text[colon+1:semicolon]) {
+    term, err := parsePatternTerm(field)
+    if err != nil {
+      return HandPattern{}, fmt.Errorf("%s: %v", p.Name, err)
+    }
+    p.Terms = append(p.Terms, term)
+    for _, run := range append([]patternRun{ term.Tiles }, term.Class...) {
+      if strings.Contains("abc", run.Suit) && run.Suit != "" && !strings.Contains(p.variables, run.Suit) {
+        p.variables += run.Suit
+      }
+    }
+  }
+  if len(p.Terms) == 0 {
+    return HandPattern{}, fmt.Errorf("%s has no terms", p.Name)
+  }
+
+  for _, flag := range strings.Fields(text[semicolon+1:]) {
+    switch {
+      case flag == "c":
+        p.Concealed = true
+      case flag == "distinct":
+        p.Distinct = true
+      case strings.Contains(flag, "="):
+        points, err := strconv.Atoi(flag[strings.Index(flag, "=")+1:])
+        if err != nil || points <= 0 {
+          return HandPattern{}, fmt.Errorf("%s: points in %q are not a positive number", p.Name, flag)
+        }
+        p.ScoringPoints[flag[:strings.Index(flag, "=")]] = points
+      default:
+        points, err := strconv.Atoi(flag)
+        if err != nil || points <= 0 {
+          return HandPattern{}, fmt.Errorf("%s: unknown flag %q", p.Name, flag)
+        }
+        p.Points = points
+    }
+  }
+  if p.Points == 0 {
+    return HandPattern{}, fmt.Errorf("%s has no points", p.Name)
+  }
+
+  parsedPatternsLock.Lock()
+  parsedPatterns[text] = p
+  parsedPatternsLock.Unlock()
+  return p, nil
+}
+
+// parse one term: a literal, a wildcard or only
+func parsePatternTerm(field string) (patternTerm, error) {
+  term := patternTerm{ Repeat: 1 }
+  if star := strings.LastIndex(field, "*"); star >= 0 {
+    repeat, err := strconv.Atoi(field[star+1:])
+    if err != nil || repeat < 1 {
+      return term, fmt.Errorf("term %s: bad repeat", field)
+    }
+    term.Repeat = repeat
+    field = field[:star]
+  }
+
+  kind, class := field, ""
+  if open := strings.Index(field, "["); open >= 0 {
+    if !strings.HasSuffix(field, "]") {
+      return term, fmt.Errorf("term %s: unclosed bracket", field)
+    }
+    kind, class = field[:open], field[open+1:len(field)-1]
+  }
+
+  if PatternWildcards[kind] || kind == "only" {
+    runs, err := parsePatternRuns(class)
+    if err != nil {
+      return term, fmt.Errorf("term %s: %v", field, err)
+    }
+    if kind == "only" && len(runs) == 0 {
+      return term, fmt.Errorf("term %s: only needs tiles", field)
+    }
+    term.Kind, term.Class = kind, runs
+    return term, nil
+  }
+
+  if class != "" || term.Repeat != 1 {
+    return term, fmt.Errorf("term %s: a literal takes no brackets or repeat", field)
+  }
+  runs, err := parsePatternRuns(field)
+  if err != nil {
+    return term, fmt.Errorf("term %s: %v", field, err)
+  }
+  if len(runs) != 1 || runs[0].Symbols == "" {
+    return term, fmt.Errorf("term %s: a literal is symbols in at most one suit", field)
+  }
+  term.Kind, term.Tiles = "literal", runs[0]
+  return term, nil
+}
+
+// split symbols into runs, each ending with its suit; a suit alone is every tile of the suit
+func parsePatternRuns(text string) ([]patternRun, error) {
+  runs := make([]patternRun, 0, 4)
+  pending := ""
+  for _, r := range text {
+    switch {
+      case strings.ContainsRune(PatternSuits, r):
+        runs = append(runs, patternRun{ Symbols: pending, Suit: string(r) })
+        pending = ""
+      case r != 'F' && strings.ContainsRune(CardSymbols, r):
+        pending += string(r)
+      default:
+        return nil, fmt.Errorf("unknown symbol %c", r)
+    }
+  }
+  if strings.ContainsAny(pending, "123456789D") {
+    return nil, fmt.Errorf("%s needs a suit", pending)
+  }
+  if pending != "" {
+    runs = append(runs, patternRun{ Symbols: pending })
+  }
+  return runs, nil
+}
+
+// # matching
+// points of the pattern under a scoring system
+func (p HandPattern) PointsFor(scoring string) int {
+  if points, found := p.ScoringPoints[scoring]; found {
+    return points
+  }
+  return p.Points
+}
+
+// suit index (1 to 3) of a run given the suits chosen for the variables; zero for honors
+func (run patternRun) suit(variables string, suits []int) int {
+  switch {
+    case run.Suit == "":
+      return 0
+    case strings.Contains("psm", run.Suit):
+      return strings.Index("psm", run.Suit)+1
+  }
+  return suits[strings.Index(variables, run.Suit)]
+}
+
+// tiles a wildcard may take; every tile for an empty class
+func resolveClass(runs []patternRun, variables string, suits []int) [4][10]bool {
+  var class [4][10]bool
+  for i := 0; i < 4; i++ {
+    for j := 1; j <= MaxTileIndex[i]; j++ {
+      class[i][j] = len(runs) == 0
+    }
+  }
+  for _, run := range runs {
+    suit := run.suit(variables, suits)
+    if run.Symbols == "" {
+      for j := 1; j <= MaxTileIndex[suit-1]; j++ {
+        class[suit-1][j] = true
+      }
+    }
+    for _, symbol := range run.Symbols {
+      s, v := cardTile(symbol, suit, 0)
+      class[s-1][v] = true
+    }
+  }
+  return class
+}
+
+// determine if the pattern is made of pairs only, such as seven pairs
+func (p HandPattern) AllPairs() bool {
+  for _, term := range p.Terms {
+    if term.Kind != "pair" {
+      return false
+    }
+  }
+  return true
+}
+
+// tiles used by the pattern
+func (p HandPattern) TileCount() int {
+  count := 0
+  for _, term := range p.Terms {
+    switch term.Kind {
+      case "literal":
+        count += len(term.Tiles.Symbols)
+      case "tile":
+        count += term.Repeat
+      case "pair":
+        count += 2*term.Repeat
+      case "pung", "chow", "set":
+        count += 3*term.Repeat
+    }
+  }
+  return count
+}
+
+// determine if the counted tiles are exactly the pattern for some choice of suits
+func (p HandPattern) Matches(tileCounts [][]int) bool {
+  return p.MatchesHand(tileCounts, nil)
+}
+
+// determine if hidden tiles and revealed sets are exactly the pattern for some choice of suits:
+// each revealed set fills a whole set, pung or chow term, and the other terms take hidden tiles only
+func (p HandPattern) MatchesHand(tileCounts [][]int, revealed []TileSet) bool {
+  shapes := make([]setShape, 0, len(revealed))
+  for _, set := range revealed {
+    shape, ok := revealedSetShape(set)
+    if !ok {
+      return false
+    }
+    shapes = append(shapes, shape)
+  }
+  if totalTileCount(tileCounts)+3*len(shapes) != p.TileCount() {
+    return false
+  }
+
+  for _, suits := range suitChoices(len(p.variables)) {
+    counts := newTileCounts()
+    for i := 0; i < 4; i++ {
+      copy(counts[i], tileCounts[i])
+    }
+
+    matched := true
+    wildcards := make([]resolvedTerm, 0, len(p.Terms))
+    for _, term := range p.Terms {
+      switch term.Kind {
+        case "literal":
+          suit := term.Tiles.suit(p.variables, suits)
+          for _, symbol := range term.Tiles.Symbols {
+            s, v := cardTile(symbol, suit, 0)
+            counts[s-1][v]--
+            matched = matched && counts[s-1][v] >= 0
+          }
+        case "only":
+          class := resolveClass(term.Class, p.variables, suits)
+          for i := 0; i < 4; i++ {
+            for j := 1; j < 10; j++ {
+              matched = matched && (tileCounts[i][j] == 0 || class[i][j])
+            }
+          }
+          for _, shape := range shapes {
+            matched = matched && shape.within(class)
+          }
+        default:
+          wildcards = append(wildcards, resolvedTerm{ kind: term.Kind, class: resolveClass(term.Class, p.variables, suits), repeat: term.Repeat })
+      }
+    }
+    if matched && matchRevealedSets(counts, shapes, wildcards, p.Distinct) {
+      return true
+    }
+  }
+  return false
+}
+
+// a revealed set as a pattern sees it: a pung (a kong counts as one) or a chow from its lowest tile
+type setShape struct {
+  kind string
+  suit int
+  value int
+}
+
+// shape of a revealed set, from its tiles
+func revealedSetShape(set TileSet) (setShape, bool) {
+  shape := setShape{ kind: "pung", suit: -1 }
+  if set.Kind == "seq" {
+    shape.kind = "chow"
+  }
+  for _, r := range set.Tiles {
+    for m := 0; m < 4; m++ {
+      for n := 1; n <= MaxTileIndex[m]; n++ {
+        if UnicodeDisplay[m][n] == string(r) && (shape.suit < 0 || n < shape.value) {
+          shape.suit, shape.value = m, n
+        }
+      }
+    }
+  }
+  return shape, shape.suit >= 0
+}
+
+// determine if every tile of the set may be taken by a term
+func (s setShape) within(class [4][10]bool) bool {
+  if s.kind == "pung" {
+    return class[s.suit][s.value]
+  }
+  return s.suit < 3 && s.value <= 7 && class[s.suit][s.value] && class[s.suit][s.value+1] && class[s.suit][s.value+2]
+}
+
+// give each revealed set to a set, pung or chow term that can take it, then use up the hidden tiles with the remaining terms
+func matchRevealedSets(counts [][]int, shapes []setShape, terms []resolvedTerm, distinct bool) bool {
+  if len(shapes) == 0 {
+    return matchWildcards(counts, terms, 0, 0, 0, distinct)
+  }
+  shape := shapes[0]
+  for i := range terms {
+    if terms[i].repeat == 0 || (terms[i].kind != "set" && terms[i].kind != shape.kind) || !shape.within(terms[i].class) {
+      continue
+    }
+    terms[i].repeat--
+    found := matchRevealedSets(counts, shapes[1:], terms, distinct)
+    terms[i].repeat++
+    if found {
+      return true
+    }
+  }
+  return false
+}
+
+// take (or, with a count of -1, put back) the tiles of a wildcard at a tile; false if they are not all there
+func takeWildcard(counts [][]int, kind string, class [4][10]bool, suit int, value int, count int) bool {
+  tiles := [][2]int{ {suit, value} }
+  switch kind {
+    case "pair":
+      tiles = append(tiles, [2]int{ suit, value })
+    case "pung":
+      tiles = append(tiles, [2]int{ suit, value }, [2]int{ suit, value })
+    case "chow":
+      if suit > 2 || value > 7 || !class[suit][value+1] || !class[suit][value+2] {
+        return false
+      }
+      tiles = append(tiles, [2]int{ suit, value+1 }, [2]int{ suit, value+2 })
+  }
+  for i, t := range tiles {
+    counts[t[0]][t[1]] -= count
+    if counts[t[0]][t[1]] < 0 {
+      for _, u := range tiles[:i+1] {
+        counts[u[0]][u[1]] += count
+      }
+      return false
+    }
+  }
+  return true
+}
+
+// use up the remaining tiles with the wildcards; a repeated wildcard takes its tiles in order so that each split is tried once
+func matchWildcards(counts [][]int, terms []resolvedTerm, term int, done int, from int, distinct bool) bool {
+  if term == len(terms) {
+    return totalTileCount(counts) == 0
+  }
+  if done == terms[term].repeat {
+    return matchWildcards(counts, terms, term+1, 0, 0, distinct)
+  }
+
+  t := terms[term]
+  kinds := []string{ t.kind }
+  if t.kind == "set" {
+    kinds = []string{ "pung", "chow" }
+  }
+  for index := from; index < 4*10; index++ {
+    suit, value := index/10, index%10
+    if value == 0 || !t.class[suit][value] {
+      continue
+    }
+    next := index
+    if distinct {
+      next++
+    }
+    for _, kind := range kinds {
+      if !takeWildcard(counts, kind, t.class, suit, value, 1) {
+        continue
+      }
+      found := matchWildcards(counts, terms, term, done+1, next, distinct)
+      takeWildcard(counts, kind, t.class, suit, value, -1)
+      if found {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+// special hands accepted by the rule set: the listed built-in hands, then the rule set's own patterns
+func (r RuleSet) EnabledPatterns() ([]HandPattern, error) {
+  patterns := make([]HandPattern, 0, len(r.SpecialHands)+len(r.HandPatterns))
+  for _, special := range r.SpecialHands {
+    text, found := BuiltinHandPatterns[special]
+    if !found {
+      return nil, fmt.Errorf("unknown special hand %q", special)
+    }
+    p, err := ParseHandPattern(text)
+    if err != nil {
+      return nil, err
+    }
+    patterns = append(patterns, p)
+  }
+  for _, text := range r.HandPatterns {
+    p, err := ParseHandPattern(text)
+    if err != nil {
+      return nil, err
+    }
+    patterns = append(patterns, p)
+  }
+  return patterns, nil
+}
+
+// special hands of a rule set in play, which has been validated
+func (r RuleSet) enabledPatterns() []HandPattern {
+  patterns, err := r.EnabledPatterns()
+  if err != nil {
+    log.Fatal(err)
+  }
+  return patterns
+}
+
+// special hands of the rule set matched by a hand with an optional extra tile;
+// each revealed set, a kong counting as a pung, must fill a whole set of the pattern
+func (r RuleSet) SpecialWins(h PlayerHand, consider Tile) []HandPattern {
+  matched := make([]HandPattern, 0, 1)
+  tileCounts, _, _ := h.CountHiddenTiles(consider)
+  revealed := h.RevealedTileSets
+  if h.RevealedSets < len(revealed) {
+    revealed = revealed[:h.RevealedSets]
+  }
+  for _, p := range r.enabledPatterns() {
+    if (!p.Concealed || h.Concealed()) && p.MatchesHand(tileCounts, revealed) {
+      matched = append(matched, p)
+    }
+  }
+  return matched
+}
+
+// special hands matched by the hand with an optional extra tile
+func (h PlayerHand) SpecialWins(consider Tile) []HandPattern {
+  return h.ruleSet().SpecialWins(h, consider)
+}
@@ -0,0 +1,265 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// a session: players seated at the table for a series of games, with the dealer and prevailing wind passed on between games
+package mahjong
+
+import(
+  "crypto/rand"
+  "fmt"
+  "math/big"
+  insecureRand "math/rand"
+)
+
+// # Session
+type Session struct {
+  Rules RuleSet `json:"rules"`
+  // player names by seat; seats are numbered counter-clockwise around the table and do not change
+  Names []string `json:"names"`
+  // seats of the computer players
+  ComputerPlayers []bool `json:"computerPlayers"`
+  // discard strategies of the computer players by seat; empty for the default
+  Strategies []string `json:"strategies"`
+  // how the first east was chosen: dice or tiles
+  Seating string `json:"seating"`
+  // sum of the three dice rolled by the tentative east when seating by dice
+  SeatingRoll int `json:"seatingRoll"`
+  // seat of the first east; the prevailing wind moves on each time the deal returns to it
+  FirstEast int `json:"firstEast"`
+  // seat of the current dealer, who sits as east
+  Dealer int `json:"dealer"`
+  // honor value of the prevailing wind (1 east to 4 north)
+  PrevailingWind int `json:"prevailingWind"`
+  // games since a player other than the dealer last won: each kept deal or drawn game adds a bonus stick
+  BonusSticks int `json:"bonusSticks"`
+  // riichi sticks left on the table by drawn games, collected by the next winner
+  RiichiSticks int `json:"riichiSticks"`
+  // games completed
+  Games int `json:"games"`
+  // chips won and lost, carried over from earlier sessions when loaded
+  Ledger Ledger `json:"ledger"`
+  // chips won (positive) or lost (negative) by each seat in this session alone
+  Scores []int `json:"scores"`
+}
+
+const (
+  // the tentative east, seat 0, rolls three dice: the sum-1, modulo the players, counts round to the first east
+  SeatingDice = "dice"
+  // each player draws a wind tile and sits at the seat of that wind; east deals first
+  SeatingTiles = "tiles"
+)
+
+// names of the winds by honor value
+var WindNames []string
+
+func init() {
+  WindNames = []string {"", "East", "South", "West", "North"}
+}
+
+// a session of players in arrival order; names may be left empty
+func NewSession(rules RuleSet, names []string, computerPlayers []bool) *Session {
+  s := &Session{ Rules: rules, PrevailingWind: 1 }
+  s.Names = make([]string, rules.Players, rules.Players)
+  s.ComputerPlayers = make([]bool, rules.Players, rules.Players)
+  s.Strategies = make([]string, rules.Players, rules.Players)
+  s.Scores = make([]int, rules.Players, rules.Players)
+  for i := 0; i < rules.Players; i++ {
+    s.Names[i] = PlaceholderName(i)
+    if i < len(names) && names[i] != "" {
+      s.Names[i] = names[i]
+    }
+    s.ComputerPlayers[i] = i < len(computerPlayers) && computerPlayers[i]
+  }
+  return s
+}
+
+// make computer players of the seats given a discard strategy, in arrival order; seats left empty are human
+func (s *Session) SetStrategies(strategies []string) error {
+  for i := range s.Strategies {
+    s.Strategies[i] = ""
+    s.ComputerPlayers[i] = false
+    if i >= len(strategies) || strategies[i] == "" {
+      continue
+    }
+    if err := ValidateStrategy(strategies[i]); err != nil {
+      return err
+    }
+    s.Strategies[i] = strategies[i]
+    s.ComputerPlayers[i] = true
+  }
+  return nil
+}
+
+// choose the seats and the first east
+func (s *Session) Seat(seating string, deterministic bool) error {
+  switch seating {
+    case SeatingDice:
+      s.SeatingRoll = RollOneDice(deterministic)+RollOneDice(deterministic)+RollOneDice(deterministic)
+      s.FirstEast = (s.SeatingRoll-1) % s.Rules.Players
+    case SeatingTiles:
+      // draw from one wind tile for each player, face-down
+      winds := make([]int, s.Rules.Players)
+      for i := range winds {
+        winds[i] = i+1
+      }
+      for i := len(winds)-1; i > 0; i-- {
+        k, err := randomIndex(i+1, deterministic)
+        if err != nil {
+          return err
+        }
+        winds[i], winds[k] = winds[k], winds[i]
+      }
+      // each player moves to the seat of the wind drawn
+      names := make([]string, len(s.Names))
+      computerPlayers := make([]bool, len(s.ComputerPlayers))
+      strategies := make([]string, len(s.Strategies))
+      for i, wind := range winds {
+        names[wind-1] = s.Names[i]
+        computerPlayers[wind-1] = s.ComputerPlayers[i]
+        strategies[wind-1] = s.Strategies[i]
+      }
+      s.Names, s.ComputerPlayers, s.Strategies = names, computerPlayers, strategies
+      s.FirstEast = 0
+    default:
+      return fmt.Errorf("unknown seating %q; expected %s or %s", seating, SeatingDice, SeatingTiles)
+  }
+  s.Seating = seating
+  s.Dealer = s.FirstEast
+
+  if VerboseDebug {
+    fmt.Printf("[vd] Seated by %s (roll %d): %v, first east %d\n", seating, s.SeatingRoll, s.Names, s.FirstEast)
+  }
+  return nil
+}
+
+// random number from 0 to n-1
+func randomIndex(n int, deterministic bool) (int, error) {
+  if deterministic {
+    return insecureRand.Intn(n), nil
+  }
+  j, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+  if err != nil {
+    return 0, err
+  }
+  return int(j.Int64()), nil
+}
+
+// seat wind of a player (1 east to 4 north) given the current dealer
+func (s *Session) SeatWind(player int) int {
+  return (player-s.Dealer+s.Rules.Players) % s.Rules.Players + 1
+}
+
+// the next game of the session, to be initialized with the session's dealer and computer players
+func (s *Session) NewGame() *Game {
+  g := New()
+  g.Rules = s.Rules
+  g.PrevailingWind = s.PrevailingWind
+  g.FirstEast = s.FirstEast
+  g.BonusSticks = s.BonusSticks
+  g.RiichiSticks = s.RiichiSticks
+  g.Names = append([]string{}, s.Names...)
+  g.Strategies = append([]string{}, s.Strategies...)
+  return g
+}
+
+// pass the deal on after a game, given the outcome from BeginGame: the winner, or the dealer after a draw
+// the dealer stays after winning, or after a draw as the rules allow; otherwise the deal passes to the next seat,
+// and the prevailing wind moves on once every seat has dealt
+func (s *Session) Record(won bool, player int) {
+  s.Games++
+  if won && player != s.Dealer {
+    s.BonusSticks = 0
+  } else {
+    s.BonusSticks++
+  }
+  
+  next := (s.Dealer+1) % s.Rules.Players
+  if player == s.Dealer {
+    next = s.Dealer
+  }
+  if next != s.Dealer && next == s.FirstEast {
+    s.PrevailingWind++
+  }
+  s.Dealer = next
+}
+
+// hand within the round of the prevailing wind, from 1: how many seats have dealt since the first east, including the dealer
+func (s *Session) Hand() int {
+  return (s.Dealer-s.FirstEast+s.Rules.Players) % s.Rules.Players + 1
+}
+
+// name shown for a seat whose player gave no name, e.g., "Player 2"
+func PlaceholderName(seat int) string {
+  return fmt.Sprintf("Player %d", seat)
+}
+
+// a name that stands in for a seat rather than a player; such players are neither profiled nor rated
+func IsPlaceholderName(name string) bool {
+  var seat int
+  if _, err := fmt.Sscanf(name, "Player %d", &seat); err != nil {
+    return false
+  }
+  return name == PlaceholderName(seat)
+}
+
+// name under which a seat is rated: the player's name, or for a computer player the strategy it plays, e.g., "bot:sets";
+// empty for a player who gave no name
+func (s *Session) RatingName(seat int) string {
+  if !s.ComputerPlayers[seat] {
+    if IsPlaceholderName(s.Names[seat]) {
+      return ""
+    }
+    return s.Names[seat]
+  }
+  strategy := DefaultStrategy
+  if seat < len(s.Strategies) && s.Strategies[seat] != "" {
+    strategy = s.Strategies[seat]
+  }
+  return BotPrefix+strategy
+}
+
+// every seat's wind has been the prevailing wind
+func (s *Session) Over() bool {
+  return s.PrevailingWind > s.Rules.Players
+}
+
+// # display
+// prevailing wind, hand within its round and bonus sticks, e.g., "East 2, 1 bonus stick"
+func (g *Game) RoundLabel() string {
+  hand := (g.StartPlayer-g.FirstEast+g.Rules.Players) % g.Rules.Players + 1
+  label := fmt.Sprintf("%s %d", WindNames[g.PrevailingWind], hand)
+  switch {
+    case g.BonusSticks == 1:
+      label += ", 1 bonus stick"
+    case g.BonusSticks > 1:
+      label += fmt.Sprintf(", %d bonus sticks", g.BonusSticks)
+  }
+  return label
+}
+
+// seat wind of a player, with their name when seated by a session and a marker for the dealer, e.g., "East ann, dealer"
+func (g *Game) SeatLabel(player int) string {
+  label := WindNames[g.SeatWind(player)]
+  if player < len(g.Names) {
+    label += " "+g.Names[player]
+  }
+  if player == g.StartPlayer {
+    label += ", dealer"
+  }
+  return label
+}
@@ -0,0 +1,209 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// settlement: payments for each win and a running ledger of chips, saved with the session
+package mahjong
+
+import(
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "sort"
+)
+
+// # Ledger
+// chips won and lost by each player, by name, over one or more sessions
+type Ledger struct {
+  Balances map[string]int `json:"balances"`
+  // payments of each game, in the order played
+  Entries []LedgerEntry `json:"entries"`
+}
+
+// payments of one game
+type LedgerEntry struct {
+  GameId string `json:"gameId"`
+  // name of the winner; empty for a drawn game
+  Winner string `json:"winner,omitempty"`
+  // name of the player whose tile completed the win; empty when self-drawn
+  DealtIn string `json:"dealtIn,omitempty"`
+  // chips for each share, before multipliers
+  Value int `json:"value"`
+  // chips received (positive) or paid (negative), by name
+  Payments map[string]int `json:"payments"`
+}
+
+const (
+  // chips deposited with each riichi declaration
+  RiichiStickValue = 1000
+)
+
+// chips for each share of a win: the basic points under riichi, a doubling for each faan, otherwise the points
+func (r RuleSet) HandValue(s ScoredWin) int {
+  switch {
+    case s.BasePoints > 0:
+      return s.BasePoints
+    case s.Unit == "faan":
+      return 1 << uint(s.Points)
+  }
+  if s.Points < 1 {
+    return 1
+  }
+  return s.Points
+}
+
+// chips received (positive) or paid (negative) by each seat for the game's win, if any
+// each other player owes a share of the hand value, multiplied for a self-draw and when the dealer pays or wins;
+// on a discard, the discarder pays every share under discarderPays
+// each player declaring riichi deposits a stick, and the winner collects every stick on the table
+func (g *Game) Payments() []int {
+  payments := make([]int, len(g.Hands))
+  for p, h := range g.Hands {
+    if h.Riichi {
+      payments[p] -= RiichiStickValue
+    }
+  }
+  if g.Winner < 0 || g.Winner >= len(g.Hands) {
+    return payments
+  }
+  payments[g.Winner] += g.RiichiSticks*RiichiStickValue
+
+  value := g.Rules.HandValue(g.Win)
+  for p := range g.Hands {
+    if p == g.Winner {
+      continue
+    }
+    share := value
+    if g.DealtIn < 0 && g.Rules.SelfDrawMultiplier > 1 {
+      share *= g.Rules.SelfDrawMultiplier
+    }
+    if (p == g.StartPlayer || g.Winner == g.StartPlayer) && g.Rules.DealerMultiplier > 1 {
+      share *= g.Rules.DealerMultiplier
+    }
+
+    payer := p
+    if g.DealtIn >= 0 && g.Rules.Payment != PaymentAllPay {
+      payer = g.DealtIn
+    }
+    payments[payer] -= share
+    payments[g.Winner] += share
+  }
+  return payments
+}
+
+// record the game's payments in the session ledger; riichi sticks left after a drawn game stay on the table for the next
+func (s *Session) Settle(g *Game) LedgerEntry {
+  s.RiichiSticks = g.RiichiSticks
+  if g.Winner >= 0 {
+    s.RiichiSticks = 0
+  }
+
+  entry := LedgerEntry{ GameId: g.GameId, Payments: make(map[string]int) }
+  if g.Winner >= 0 {
+    entry.Winner = g.PlayerName(g.Winner)
+    entry.Value = g.Rules.HandValue(g.Win)
+  }
+  if g.Winner >= 0 && g.DealtIn >= 0 {
+    entry.DealtIn = g.PlayerName(g.DealtIn)
+  }
+
+  if s.Ledger.Balances == nil {
+    s.Ledger.Balances = make(map[string]int)
+  }
+  for p, amount := range g.Payments() {
+    name := g.PlayerName(p)
+    entry.Payments[name] = amount
+    s.Ledger.Balances[name] += amount
+    if p < len(s.Scores) {
+      s.Scores[p] += amount
+    }
+  }
+  s.Ledger.Entries = append(s.Ledger.Entries, entry)
+  return entry
+}
+
+// balances from highest to lowest, then the payments that settle them: each debtor pays the largest creditor in turn
+func (l Ledger) Settlement() []string {
+  names := make([]string, 0, len(l.Balances))
+  for name := range l.Balances {
+    names = append(names, name)
+  }
+  sort.Slice(names, func(i, j int) bool {
+    if l.Balances[names[i]] != l.Balances[names[j]] {
+      return l.Balances[names[i]] > l.Balances[names[j]]
+    }
+    return names[i] < names[j]
+  })
+
+  lines := make([]string, 0, 2*len(names))
+  remaining := make(map[string]int)
+  for _, name := range names {
+    lines = append(lines, fmt.Sprintf("%s: %+d", name, l.Balances[name]))
+    remaining[name] = l.Balances[name]
+  }
+
+  // creditors lead the order and debtors trail it
+  for i, j := 0, len(names)-1; i < j; {
+    creditor, debtor := names[i], names[j]
+    if remaining[creditor] <= 0 {
+      i++
+      continue
+    }
+    if remaining[debtor] >= 0 {
+      j--
+      continue
+    }
+    amount := remaining[creditor]
+    if -remaining[debtor] < amount {
+      amount = -remaining[debtor]
+    }
+    lines = append(lines, fmt.Sprintf("%s pays %s %d", debtor, creditor, amount))
+    remaining[creditor] -= amount
+    remaining[debtor] += amount
+  }
+  return lines
+}
+
+// output the final settlement
+func (l Ledger) OutputSettlement() {
+  fmt.Printf("Settlement after %d games:\n", len(l.Entries))
+  for _, line := range l.Settlement() {
+    fmt.Printf("%s\n", line)
+  }
+}
+
+// write the session, with its ledger, as json
+func (s *Session) Save(path string) error {
+  data, err := json.MarshalIndent(s, "", "  ")
+  if err != nil {
+    return err
+  }
+  return ioutil.WriteFile(path, data, 0644)
+}
+
+// read a session saved with Save
+func LoadSession(path string) (*Session, error) {
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  var s Session
+  if err := json.Unmarshal(data, &s); err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  return &s, nil
+}
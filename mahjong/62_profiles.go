@@ -0,0 +1,220 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// player profiles: statistics accumulated by name over the games played, kept in a local data directory
+package mahjong
+
+import(
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+)
+
+// # Profile
+type Profile struct {
+  Name string `json:"name"`
+  Games int `json:"games"`
+  Wins int `json:"wins"`
+  SelfDrawnWins int `json:"selfDrawnWins"`
+  // games lost on the player's discard or a tile added to their kong
+  DealtIn int `json:"dealtIn"`
+  // chips for a share of each win (see HandValue), summed over the wins
+  WinValue int `json:"winValue"`
+  // wins scoring each pattern, by pattern name
+  Patterns map[string]int `json:"patterns"`
+  // discards claimed, by claim: pong, seq or kong
+  Claims map[string]int `json:"claims"`
+}
+
+// profiles by player name
+type Profiles map[string]*Profile
+
+const (
+  // file of the profiles within the data directory
+  ProfilesFile = "profiles.json"
+)
+
+// local data directory: .mahjong in the home directory, or the working directory if there is no home
+func DefaultDataDir() string {
+  home, err := os.UserHomeDir()
+  if err != nil {
+    return ".mahjong"
+  }
+  return filepath.Join(home, ".mahjong")
+}
+
+// read the profiles of a data directory; there are none until the first game is recorded
+func LoadProfiles(dir string) (Profiles, error) {
+  profiles := make(Profiles)
+  data, err := ioutil.ReadFile(filepath.Join(dir, ProfilesFile))
+  if os.IsNotExist(err) {
+    return profiles, nil
+  } else if err != nil {
+    return nil, err
+  }
+  if err := json.Unmarshal(data, &profiles); err != nil {
+    return nil, fmt.Errorf("%s: %v", filepath.Join(dir, ProfilesFile), err)
+  }
+  return profiles, nil
+}
+
+// write the profiles to a data directory, creating it if needed
+func (p Profiles) Save(dir string) error {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(p, "", "  ")
+  if err != nil {
+    return err
+  }
+  return ioutil.WriteFile(filepath.Join(dir, ProfilesFile), data, 0644)
+}
+
+// profile of a player, created on first use
+func (p Profiles) profile(name string) *Profile {
+  if p[name] == nil {
+    p[name] = &Profile{ Name: name }
+  }
+  if p[name].Patterns == nil {
+    p[name].Patterns = make(map[string]int)
+  }
+  if p[name].Claims == nil {
+    p[name].Claims = make(map[string]int)
+  }
+  return p[name]
+}
+
+// add a completed game to the profiles of its players, reading the game's event record:
+// the player of a win on a discard dealt in with the latest discard, and of a robbed kong with the latest kong
+func (p Profiles) Record(g *Game) {
+  // players who gave no name are not profiled: their events are counted in a profile that is not kept
+  player := func(seat int) *Profile {
+    name := g.PlayerName(seat)
+    if IsPlaceholderName(name) {
+      return &Profile{ Name: name, Patterns: make(map[string]int), Claims: make(map[string]int) }
+    }
+    return p.profile(name)
+  }
+  for i := range g.Hands {
+    player(i).Games++
+  }
+
+  lastDiscard, lastKong := -1, -1
+  for _, event := range g.Events {
+    switch event.Action {
+      case "discard":
+        lastDiscard = event.Seat
+      case "pong", "seq":
+        player(event.Seat).Claims[event.Action]++
+      case "kong":
+        lastKong = event.Seat
+        if event.Detail == "discard" {
+          player(event.Seat).Claims[event.Action]++
+        }
+      case "win":
+        winner := player(event.Seat)
+        winner.Wins++
+        winner.WinValue += g.Rules.HandValue(g.Win)
+        for _, pattern := range g.Win.Patterns {
+          winner.Patterns[pattern.Name]++
+        }
+        switch event.Detail {
+          case "draw":
+            winner.SelfDrawnWins++
+          case "discard":
+            if lastDiscard >= 0 {
+              player(lastDiscard).DealtIn++
+            }
+          case "kong":
+            if lastKong >= 0 {
+              player(lastKong).DealtIn++
+            }
+        }
+    }
+  }
+}
+
+// share of the games
+func (p Profile) rate(count int) float64 {
+  if p.Games == 0 {
+    return 0
+  }
+  return 100*float64(count)/float64(p.Games)
+}
+
+// most frequent winning patterns, up to the given number, most frequent first
+func (p Profile) TopPatterns(limit int) []string {
+  names := make([]string, 0, len(p.Patterns))
+  for name := range p.Patterns {
+    names = append(names, name)
+  }
+  sort.Slice(names, func(i, j int) bool {
+    if p.Patterns[names[i]] != p.Patterns[names[j]] {
+      return p.Patterns[names[i]] > p.Patterns[names[j]]
+    }
+    return names[i] < names[j]
+  })
+  if len(names) > limit {
+    names = names[:limit]
+  }
+  return names
+}
+
+// statistics for display
+func (p Profile) Lines() []string {
+  lines := []string {
+    fmt.Sprintf("%s: %d games", p.Name, p.Games),
+    fmt.Sprintf("  win rate %.1f%% (%d wins, %d self-drawn); deal-in rate %.1f%% (%d)", p.rate(p.Wins), p.Wins, p.SelfDrawnWins, p.rate(p.DealtIn), p.DealtIn),
+  }
+  if p.Wins > 0 {
+    lines = append(lines, fmt.Sprintf("  average hand value %.1f", float64(p.WinValue)/float64(p.Wins)))
+  }
+
+  patterns := ""
+  for i, name := range p.TopPatterns(5) {
+    if i > 0 {
+      patterns += ", "
+    }
+    patterns += fmt.Sprintf("%s ×%d", name, p.Patterns[name])
+  }
+  if patterns != "" {
+    lines = append(lines, "  most common patterns: "+patterns)
+  }
+
+  claims := 0
+  for _, count := range p.Claims {
+    claims += count
+  }
+  if p.Games > 0 {
+    lines = append(lines, fmt.Sprintf("  claims %.2f a game (pong %d, chow %d, kong %d)", float64(claims)/float64(p.Games), p.Claims["pong"], p.Claims["seq"], p.Claims["kong"]))
+  }
+  return lines
+}
+
+// names of the profiles in order
+func (p Profiles) Names() []string {
+  names := make([]string, 0, len(p))
+  for name := range p {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
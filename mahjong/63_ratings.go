@@ -0,0 +1,196 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016-7 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// ratings: an Elo-style rating for each player and computer strategy, from their placings in completed sessions
+package mahjong
+
+import(
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "math"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// # Rating
+type Rating struct {
+  Name string `json:"name"`
+  Rating float64 `json:"rating"`
+  // sessions rated, once for each seat taken
+  Sessions int `json:"sessions"`
+  // sessions finished first, ties included
+  Firsts int `json:"firsts"`
+  // places summed over the sessions, for the average
+  Places int `json:"places"`
+}
+
+// ratings by player name, or "bot:" and the strategy for computer players
+type Ratings map[string]*Rating
+
+const (
+  // file of the ratings within the data directory
+  RatingsFile = "ratings.json"
+  // prefix of the rating names of computer players, before the strategy
+  BotPrefix = "bot:"
+  // rating of a newcomer
+  InitialRating = 1500.0
+  // largest change from one session, shared among the opponents
+  RatingFactor = 32.0
+)
+
+// read the ratings of a data directory; there are none until the first session is rated
+func LoadRatings(dir string) (Ratings, error) {
+  ratings := make(Ratings)
+  data, err := ioutil.ReadFile(filepath.Join(dir, RatingsFile))
+  if os.IsNotExist(err) {
+    return ratings, nil
+  } else if err != nil {
+    return nil, err
+  }
+  if err := json.Unmarshal(data, &ratings); err != nil {
+    return nil, fmt.Errorf("%s: %v", filepath.Join(dir, RatingsFile), err)
+  }
+  return ratings, nil
+}
+
+// write the ratings to a data directory, creating it if needed
+func (r Ratings) Save(dir string) error {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(r, "", "  ")
+  if err != nil {
+    return err
+  }
+  return ioutil.WriteFile(filepath.Join(dir, RatingsFile), data, 0644)
+}
+
+// rating of a player, created on first use
+func (r Ratings) rating(name string) *Rating {
+  if r[name] == nil {
+    r[name] = &Rating{ Name: name, Rating: InitialRating }
+  }
+  return r[name]
+}
+
+// place of each seat by score, from 1; equal scores share the better place
+func Places(scores []int) []int {
+  places := make([]int, len(scores))
+  for i := range scores {
+    places[i] = 1
+    for j := range scores {
+      if scores[j] > scores[i] {
+        places[i]++
+      }
+    }
+  }
+  return places
+}
+
+// rate a completed session from the score of each seat: every seat is matched against every other,
+// scoring 1 for placing above them, 1/2 for a tie and 0 for placing below, against the expectation from the ratings before the session
+// seats of the same name, such as two computer players of one strategy, are not matched against each other
+func (r Ratings) Record(names []string, scores []int) {
+  if len(names) < 2 || len(names) != len(scores) {
+    return
+  }
+  before := make([]float64, len(names))
+  for i, name := range names {
+    before[i] = r.rating(name).Rating
+  }
+  places := Places(scores)
+
+  k := RatingFactor/float64(len(names)-1)
+  for i, name := range names {
+    change := 0.0
+    for j := range names {
+      if names[j] == name {
+        continue
+      }
+      actual := 0.5
+      if scores[i] > scores[j] {
+        actual = 1
+      } else if scores[i] < scores[j] {
+        actual = 0
+      }
+      expected := 1/(1+math.Pow(10, (before[j]-before[i])/400))
+      change += k*(actual-expected)
+    }
+
+    rating := r.rating(name)
+    rating.Rating += change
+    rating.Sessions++
+    rating.Places += places[i]
+    if places[i] == 1 {
+      rating.Firsts++
+    }
+    if VerboseDebug {
+      fmt.Printf("[vd] %s placed %d with %+d: rating %.1f (%+.1f)\n", name, places[i], scores[i], rating.Rating, change)
+    }
+  }
+}
+
+// names from the highest rating to the lowest
+func (r Ratings) Names() []string {
+  names := make([]string, 0, len(r))
+  for name := range r {
+    names = append(names, name)
+  }
+  sort.Slice(names, func(i, j int) bool {
+    if r[names[i]].Rating != r[names[j]].Rating {
+      return r[names[i]].Rating > r[names[j]].Rating
+    }
+    return names[i] < names[j]
+  })
+  return names
+}
+
+// rankings for display, optionally of computer players only
+func (r Ratings) Leaderboard(botsOnly bool) []string {
+  lines := make([]string, 0, len(r))
+  rank := 0
+  for _, name := range r.Names() {
+    if botsOnly && !strings.HasPrefix(name, BotPrefix) {
+      continue
+    }
+    rank++
+    rating := r[name]
+    lines = append(lines, fmt.Sprintf("%d. %s %.0f (%d sessions, %d first, average place %.2f)", rank, name, rating.Rating, rating.Sessions, rating.Firsts, float64(rating.Places)/float64(rating.Sessions)))
+  }
+  return lines
+}
+
+// rate the session's players by their scores once it is complete; a session without games is not rated
+func (s *Session) Rate(r Ratings) {
+  if s.Games == 0 {
+    return
+  }
+  // seats without a name are left out
+  names := make([]string, 0, len(s.Names))
+  scores := make([]int, 0, len(s.Names))
+  for i := range s.Names {
+    if name := s.RatingName(i); name != "" {
+      names = append(names, name)
+      scores = append(scores, s.Scores[i])
+    }
+  }
+  r.Record(names, scores)
+}
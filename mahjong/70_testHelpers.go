@@ -111,3 +111,20 @@ func (gt *Game) TestHandMaker(unicodeTiles string) (PlayerHand, Tile) {
   
   return PlayerHand{ Hidden: Hidden }, Draw
 }
+
+// game with a hand for each given player; tiles are given as in TestHandMaker, without the draw portion
+func (gt *Game) TestGameMaker(unicodeHands ...string) *Game {
+  g := New()
+  g.Hands = make([]PlayerHand, g.Rules.Players, g.Rules.Players)
+  for i := 0; i < g.Rules.Players; i++ {
+    g.Hands[i].Hidden = make([]Tile, g.Rules.HandSize+1, g.Rules.HandSize+1)
+    g.Hands[i].Revealed = make([]Tile, g.Rules.BonusTileCount(), g.Rules.BonusTileCount())
+    g.Hands[i].Player = i
+    g.Hands[i].Rules = &g.Rules
+    if i < len(unicodeHands) {
+      testHand, _ := gt.TestHandMaker(unicodeHands[i]+";")
+      copy(g.Hands[i].Hidden, testHand.Hidden)
+    }
+  }
+  return g
+}
@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package mahjong
 
 import (
+  "reflect"
   "testing"
 )
 
@@ -26,6 +27,8 @@ type TestHand struct {
   Tiles string
   Relationship string
   Outcome bool
+  // preset the hand is played under; empty for the default
+  Rules string
 }
 
 func TestWinningHands(t *testing.T) {
@@ -49,8 +52,20 @@ func TestWinningHands(t *testing.T) {
   testCases = append(testCases, TestHand{ Tiles:"🀑🀒🀓🀉🀉🀉🀝🀒🀒🀟🀆🀆🀆;🀞", Relationship: "other", Outcome: false })
   testCases = append(testCases, TestHand{ Tiles:"🀑🀒🀓🀉🀉🀇🀝🀞🀒🀒🀟🀆🀆🀆;", Relationship: "draw", Outcome: false })
   
+  // seven pairs, where the rules accept it
+  testCases = append(testCases, TestHand{ Tiles:"🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄;🀄", Relationship: "other", Outcome: true, Rules: "riichi" })
+  testCases = append(testCases, TestHand{ Tiles:"🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄🀄;", Relationship: "draw", Outcome: true, Rules: "mcr" })
+  testCases = append(testCases, TestHand{ Tiles:"🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄;🀄", Relationship: "other", Outcome: false })
+  // four of a tile as two pairs
+  testCases = append(testCases, TestHand{ Tiles:"🀇🀇🀇🀇🀙🀙🀚🀚🀐🀐🀀🀀🀄;🀄", Relationship: "other", Outcome: false, Rules: "riichi" })
+  testCases = append(testCases, TestHand{ Tiles:"🀇🀇🀇🀇🀙🀙🀚🀚🀐🀐🀀🀀🀄;🀄", Relationship: "other", Outcome: true, Rules: "mcr" })
+  
   for i := 0; i < len(testCases); i++ {
     testHand, testTile := gt.TestHandMaker(testCases[i].Tiles)
+    if testCases[i].Rules != "" {
+      rules, _ := PresetRules(testCases[i].Rules)
+      testHand.Rules = &rules
+    }
     if testCases[i].Outcome != testHand.HaveWin(testTile, testCases[i].Relationship) {
       t.Errorf("%v with additional tile %v arising from %s should have been a %v, but was not", testHand, testTile, testCases[i].Relationship, testCases[i].Outcome)
     }
@@ -203,6 +218,203 @@ func TestKongCheck(t *testing.T) {
   }
 }
 
+func TestKongKinds(t *testing.T) {
+  // four drawn tiles
+  testHand, _ := gt.TestHandMaker("🀇🀈🀉🀊🀋🀌🀍🀎🀏🀏🀆🀆🀆🀆;")
+  if _, setOptions := testHand.HaveKong(EmptyTile, "draw"); len(setOptions) != 1 || setOptions[0].Kind != "concealedKong" {
+    t.Errorf("four drawn tiles should make a concealed kong, got %v", setOptions)
+  }
+  // claimed from a discard
+  testHand, testTile := gt.TestHandMaker("🀇🀈🀉🀊🀋🀌🀌🀏🀏🀏🀆🀆🀆;🀆")
+  if _, setOptions := testHand.HaveKong(testTile, "other"); len(setOptions) != 1 || setOptions[0].Kind != "kong" {
+    t.Errorf("a kong claimed from a discard should be exposed, got %v", setOptions)
+  }
+  // added to a revealed triple
+  testHand, _ = gt.TestHandMaker("🀇🀈🀉🀊🀋🀌🀌🀏🀏🀏🀆;")
+  testHand.RevealedSets = 1
+  testHand.RevealedTileSets = []TileSet{ TileSet{ Kind: "triple", Tiles: "🀆🀆🀆" } }
+  if _, setOptions := testHand.HaveKong(EmptyTile, "draw"); len(setOptions) != 1 || setOptions[0].Kind != "addedKong" {
+    t.Errorf("a tile added to a revealed triple should make an added kong, got %v", setOptions)
+  }
+  
+  // a concealed kong is shown face-down and keeps the hand concealed
+  concealed := TileSet{ Kind: "concealedKong", Tiles: "🀆🀆🀆🀆" }
+  if concealed.Display(0, PlayersInGame) != TileBack+"🀆🀆"+TileBack || !concealed.IsKong() {
+    t.Errorf("unexpected display %s of a concealed kong", concealed.Display(0, PlayersInGame))
+  }
+  testHand, _ = gt.TestHandMaker("🀇🀈🀉🀊🀋🀌🀍🀎🀏🀙🀙;")
+  testHand.RevealedSets = 1
+  testHand.RevealedTileSets = []TileSet{ concealed }
+  if !testHand.Concealed() {
+    t.Errorf("a hand with only a concealed kong revealed should be concealed")
+  }
+  score := ScoreWin(WinContext{ Hand: testHand, Source: "draw", SeatWind: 2, PrevailingWind: 1, Rules: RulePresets["simple"] })
+  if fanPoints(score, "concealed hand") != 1 {
+    t.Errorf("expected a concealed hand with a concealed kong, got %v", score)
+  }
+  testHand.RevealedTileSets = []TileSet{ TileSet{ Kind: "kong", Tiles: "🀆🀆🀆🀆" } }
+  if testHand.Concealed() {
+    t.Errorf("a hand with a claimed kong should not be concealed")
+  }
+}
+
+func TestRobKong(t *testing.T) {
+  // player 0 adds 🀆 to a revealed triple; player 2 waits on it
+  robbed := func(waiting string) *Game {
+    g := gt.TestGameMaker("🀇🀈🀉🀊🀋🀌🀍🀎🀏🀙🀆", "", waiting)
+    g.Hands[0].RevealedSets = 1
+    g.Hands[0].RevealedTileSets = []TileSet{ TileSet{ Kind: "triple", Tiles: "🀆🀆🀆" } }
+    for i := range g.Hands {
+      g.Hands[i].ComputerPlayer = true
+    }
+    g.UndealtTileCount = 50
+    return g
+  }
+  
+  g := robbed("🀇🀈🀉🀙🀚🀛🀜🀝🀞🀟🀠🀡🀆")
+  state := g.processState(StateUnit{ Player: 0, State: "HaveKong", Phase: "DrawProcessing" })
+  if state.State != "RobKong" || state.Player != 1 || g.KongTile.Ud != "🀆" {
+    t.Fatalf("expected a chance to rob the kong of 🀆, got %v", state)
+  }
+  for state.State == "RobKong" {
+    state = g.processState(state)
+  }
+  if state.State != "WinGameP2" || fanPoints(g.Win, "robbing a kong") != 1 {
+    t.Errorf("expected player 2 to win by robbing the kong, got %v worth %v", state, g.Win)
+  }
+  if g.Hands[0].RevealedTileSets[0].Kind != "triple" {
+    t.Errorf("a robbed kong should revert to a triple, but was %v", g.Hands[0].RevealedTileSets[0])
+  }
+  
+  // no one can rob the kong: its player draws a replacement
+  g = robbed("🀇🀈🀉🀙🀚🀛🀜🀝🀞🀟🀠🀡🀀")
+  state = StateUnit{ Player: 0, State: "HaveKong", Phase: "DrawProcessing" }
+  for state.State == "HaveKong" || state.State == "RobKong" {
+    state = g.processState(state)
+  }
+  if state.State != "DrawReplacementTile" || state.Player != 0 || g.KongTile != EmptyTile {
+    t.Errorf("expected player 0 to draw a replacement, got %v", state)
+  }
+  
+  // in a dealt game, where every transition is audited, the robbed tile moves from the kong to the winner's hand
+  g = New()
+  g.Initialize(0, []bool{ true, true, true, true })
+  locked := make(map[int]bool)
+  arrangeHand(g, locked, 0, "🀇🀈🀉🀊🀋🀌🀍🀎🀏🀀🀚🀚🀚🀚")
+  arrangeHand(g, locked, 1, "🀇🀈🀉🀙🀛🀜🀝🀞🀟🀠🀡🀃🀃")
+  arrangeHand(g, locked, 2, "🀇🀋🀏🀐🀔🀘🀟🀡🀀🀁🀂🀃🀄")
+  arrangeHand(g, locked, 3, "🀈🀌🀑🀕🀝🀀🀁🀁🀂🀂🀄🀅🀅")
+  
+  // player 0 pongs 🀚 discarded by player 3, then adds the fourth
+  triple := make([]Tile, 0, 3)
+  for i, held := range g.Hands[0].Hidden {
+    if held.Ud == "🀚" && len(triple) < 3 {
+      triple = append(triple, held)
+      g.Hands[0].Hidden[i] = EmptyTile
+    }
+  }
+  g.Hands[0].RevealedTileSets = []TileSet{ TileSet{ Kind: "triple", Tiles: "🀚🀚🀚", UnderlyingTiles: triple, ClaimedTile: triple[0], ClaimedFrom: 3, ClaimedTurn: 0 } }
+  g.Hands[0].RevealedSets = 1
+  if err := g.Audit(); err != nil {
+    t.Fatal(err)
+  }
+  
+  state = StateUnit{ Player: 0, State: "HaveKong", Phase: "DrawProcessing" }
+  for state.State == "HaveKong" || state.State == "RobKong" {
+    state = g.processState(state)
+  }
+  if state.State != "WinGameP1" {
+    t.Fatalf("expected player 1 to win by robbing the kong, got %v", state)
+  }
+  set := g.Hands[0].RevealedTileSets[0]
+  if set.Kind != "triple" || !reflect.DeepEqual(set.UnderlyingTiles, triple) || set.ClaimedTile != triple[0] || set.ClaimedFrom != 3 {
+    t.Errorf("the robbed kong should be its original triple %v, claimed from player 3, got %+v", triple, set)
+  }
+  if g.Hands[1].tilePosition(g.KongTile) < 0 || containsTile(set.UnderlyingTiles, g.KongTile) {
+    t.Errorf("the robbed tile %v should be in player 1's hand %v only", g.KongTile, g.Hands[1].Hidden)
+  }
+}
+
+// move the given tiles into a player's hidden hand, swapping them with tiles from the wall or other hands,
+// so that every tile stays in exactly one place; tiles already arranged are not taken again
+func arrangeHand(g *Game, locked map[int]bool, player int, unicodeTiles string) {
+  k := 0
+  for _, r := range unicodeTiles {
+    for g.Hands[player].Hidden[k] == EmptyTile {
+      k++
+    }
+    target := &g.Hands[player].Hidden[k]
+    var source *Tile
+    for i := range g.Undealt {
+      if g.Undealt[i].Ud == string(r) && !locked[g.Undealt[i].Id] {
+        source = &g.Undealt[i]
+      }
+    }
+    for p := range g.Hands {
+      for i := range g.Hands[p].Hidden {
+        held := &g.Hands[p].Hidden[i]
+        if source == nil && held.Ud == string(r) && !locked[held.Id] && !(p == player && i < k) {
+          source = held
+        }
+      }
+    }
+    *target, *source = *source, *target
+    locked[target.Id] = true
+    k++
+  }
+}
+
+func containsTile(tiles []Tile, t Tile) bool {
+  for _, x := range tiles {
+    if x == t {
+      return true
+    }
+  }
+  return false
+}
+
+func TestClaimProvenance(t *testing.T) {
+  g := gt.TestGameMaker("", "🀇🀈🀉🀊🀋🀌🀍🀎🀏🀆🀆🀀🀀", "", "🀙🀛🀜🀝🀞🀟🀠🀡🀃🀃🀃🀁🀁")
+  for i := range g.Hands {
+    g.Hands[i].ComputerPlayer = true
+  }
+  discarded, _ := gt.TestHandMaker("🀆🀚;")
+  g.Discard = DiscardPile{ DiscardedTile{ Player: 2, Item: discarded.Hidden[1] }, DiscardedTile{ Player: 0, Item: discarded.Hidden[0] } }
+  
+  // player 1 pongs the white dragon discarded by the previous player
+  state := g.processState(StateUnit{ Player: 1, State: "HavePong", Phase: "DiscardProcessing" })
+  if state.State != "Discard" || g.Hands[1].RevealedSets != 1 {
+    t.Fatalf("expected player 1 to pong, got %v", state)
+  }
+  set := g.Hands[1].RevealedTileSets[0]
+  if set.ClaimedTile.Ud != "🀆" || set.ClaimedFrom != 0 || set.ClaimedTurn != 1 || len(set.UnderlyingTiles) != 3 {
+    t.Errorf("unexpected provenance of %+v", set)
+  }
+  if len(g.Discard) != 2 || !g.Discard[1].Claimed || g.Discard[0].Claimed {
+    t.Errorf("the claimed discard should stay in the pile, marked, got %v", g.Discard)
+  }
+  if display := set.Display(1, g.Rules.Players); display != "[🀆]🀆🀆" {
+    t.Errorf("a tile claimed from the previous player should be shown on the left, got %s", display)
+  }
+  if display := set.Display(2, g.Rules.Players); display != "🀆[🀆]🀆" {
+    t.Errorf("a tile claimed from across should be shown in the middle, got %s", display)
+  }
+  
+  // the claimed tile is counted once
+  unseen := g.Hands[3].UnseenTileCounts(g.Discard, g.Hands)
+  if unseen[3][7] != 1 {
+    t.Errorf("one white dragon should be unseen, got %d", unseen[3][7])
+  }
+  
+  // player 3 chows the two of dots discarded by the previous player
+  g.Discard = append(g.Discard, DiscardedTile{ Player: 2, Item: discarded.Hidden[1] })
+  state = g.processState(StateUnit{ Player: 3, State: "HaveSeq", Phase: "DiscardProcessing" })
+  set = g.Hands[3].RevealedTileSets[0]
+  if state.State != "Discard" || set.ClaimedFrom != 2 || set.ClaimedTurn != 2 || set.Display(3, g.Rules.Players) != "[🀚]🀙🀛" {
+    t.Errorf("unexpected chow %+v shown as %s", set, set.Display(3, g.Rules.Players))
+  }
+}
+
 func TestPongCheck(t *testing.T) {
   // invalid invocation; only applicable to a previous/other relationship
   testHand, testTile := gt.TestHandMaker("🀑🀒🀓🀉🀉🀇🀝🀞🀒🀒🀟🀆🀆🀆;")
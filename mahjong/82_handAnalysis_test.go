@@ -0,0 +1,240 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+type TestShanten struct {
+  Tiles string
+  Shanten int
+}
+
+func TestParseTiles(t *testing.T) {
+  pool := NewTilePool()
+  tiles, err := pool.Parse("123m 5p🀆7z")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(tiles) != 6 || tiles[0].Ud != "🀇" || tiles[3].Ud != "🀝" || tiles[4].Ud != "🀆" || tiles[5].Ud != "🀆" {
+    t.Errorf("tiles were not parsed as expected: %v", tiles)
+  }
+  if tiles[4].Id == tiles[5].Id {
+    t.Errorf("repeated tiles should receive distinct ids: %v", tiles)
+  }
+  
+  if _, err := pool.Parse("777z"); err == nil {
+    t.Errorf("a fifth copy of a tile should have been refused")
+  }
+  if _, err := pool.Parse("12"); err == nil {
+    t.Errorf("values without a suit letter should have been refused")
+  }
+  
+  sets, err := NewTilePool().ParseSets("555z,312s,1111p")
+  if err != nil || len(sets) != 3 || sets[0].Kind != "triple" || sets[1].Kind != "seq" || sets[2].Kind != "kong" {
+    t.Errorf("sets were not parsed as expected: %v, %v", sets, err)
+  }
+  if _, err := NewTilePool().ParseSets("135m"); err == nil {
+    t.Errorf("tiles that do not form a set should have been refused")
+  }
+}
+
+func TestShantenNumber(t *testing.T) {
+  var testCases []TestShanten
+  
+  // complete
+  testCases = append(testCases, TestShanten{ Tiles: "🀑🀒🀓🀉🀉🀉🀝🀞🀒🀒🀟🀆🀆🀆;", Shanten: -1 })
+  // ready
+  testCases = append(testCases, TestShanten{ Tiles: "🀑🀒🀓🀉🀉🀉🀝🀞🀒🀒🀆🀆🀆;", Shanten: 0 })
+  // one away
+  testCases = append(testCases, TestShanten{ Tiles: "🀑🀒🀓🀉🀉🀉🀝🀒🀒🀆🀆🀆🀃;", Shanten: 1 })
+  testCases = append(testCases, TestShanten{ Tiles: "🀇🀈🀉🀊🀋🀌🀛🀜🀞🀞🀀🀀🀃;", Shanten: 1 })
+  
+  for i := 0; i < len(testCases); i++ {
+    testHand, _ := gt.TestHandMaker(testCases[i].Tiles)
+    tileCounts, _, _ := testHand.CountHiddenTiles(EmptyTile)
+    if shanten := ShantenNumber(tileCounts, 0); shanten != testCases[i].Shanten {
+      t.Errorf("%v should have had a shanten number of %d, but had %d", testHand, testCases[i].Shanten, shanten)
+    }
+  }
+  
+  // revealed sets reduce the sets needed
+  testHand, _ := gt.TestHandMaker("🀑🀒🀓🀆🀆;")
+  tileCounts, _, _ := testHand.CountHiddenTiles(EmptyTile)
+  if shanten := ShantenNumber(tileCounts, 3); shanten != -1 {
+    t.Errorf("%v with three revealed sets should have been complete, but had a shanten number of %d", testHand, shanten)
+  }
+}
+
+func TestRuleSetShanten(t *testing.T) {
+  riichi, _ := PresetRules("riichi")
+  mcr, _ := PresetRules("mcr")
+  classic, _ := PresetRules("classic")
+  simple, _ := PresetRules("simple")
+  testCases := []struct {
+    Tiles string
+    Rules RuleSet
+    Shanten int
+  }{
+    // ready on the single tile
+    { "🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄;", riichi, 0 },
+    { "🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄;", classic, 3 },
+    // four of a tile are two pairs only where doubled pairs are allowed
+    { "🀇🀇🀇🀇🀙🀙🀚🀚🀐🀐🀀🀀🀄;", riichi, 2 },
+    { "🀇🀇🀇🀇🀙🀙🀚🀚🀐🀐🀀🀀🀄;", mcr, 0 },
+    // complete
+    { "🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄🀄;", riichi, -1 },
+    // thirteen orphans counts only where the rule set accepts it
+    { "🀀🀁🀂🀃🀄🀅🀆🀙🀐🀇🀡🀘🀏;", classic, 0 },
+    { "🀀🀁🀂🀃🀄🀅🀆🀙🀐🀇🀡🀘🀏;", simple, 8 },
+  }
+  
+  for _, testCase := range testCases {
+    testHand, _ := gt.TestHandMaker(testCase.Tiles)
+    tileCounts, _, _ := testHand.CountHiddenTiles(EmptyTile)
+    if shanten := testCase.Rules.Shanten(tileCounts, 0); shanten != testCase.Shanten {
+      t.Errorf("%v under %s rules should have had a shanten number of %d, but had %d", testHand, testCase.Rules.Name, testCase.Shanten, shanten)
+    }
+  }
+  
+  // the wait of a seven pairs hand is its single tile
+  testHand, _ := gt.TestHandMaker("🀇🀇🀈🀈🀙🀙🀚🀚🀐🀐🀀🀀🀄;")
+  testHand.Rules = &riichi
+  unseen := testHand.UnseenTileCounts(DiscardPile{}, []PlayerHand{ testHand })
+  if waits := testHand.Waits(unseen); len(waits) != 1 || waits[0].Item.Ud != "🀄" || waits[0].Live != 3 {
+    t.Errorf("seven pairs should wait on 🀄 with 3 live, but waited on %v", waits)
+  }
+}
+
+func TestWaits(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀇🀇🀇🀈🀉🀊🀋🀌🀍🀎🀏🀏🀏;")
+  unseen := testHand.UnseenTileCounts(DiscardPile{}, []PlayerHand{ testHand })
+  waits := testHand.Waits(unseen)
+  if len(waits) != 9 {
+    t.Errorf("nine gates should wait on all nine tiles of the suit, but waited on %v", waits)
+  }
+  for _, wait := range waits {
+    expected := 3
+    if wait.Item.Value == 1 || wait.Item.Value == 9 {
+      expected = 1
+    }
+    if wait.Live != expected {
+      t.Errorf("wait %v should have %d live copies, but had %d", wait.Item.Ud, expected, wait.Live)
+    }
+  }
+  
+  // discards and revealed sets are not live
+  testHand, _ = gt.TestHandMaker("🀇🀈🀉🀊🀋🀌🀍🀎🀏🀆🀆🀀🀀;")
+  discardHand, _ := gt.TestHandMaker("🀆;")
+  other := PlayerHand{ RevealedSets: 1, RevealedTileSets: []TileSet{ TileSet{ Kind: "triple", Tiles: "🀀🀀🀀" } } }
+  discard := DiscardPile{ DiscardedTile{ Player: 1, Item: discardHand.Hidden[0] } }
+  unseen = testHand.UnseenTileCounts(discard, []PlayerHand{ testHand, other })
+  waits = testHand.Waits(unseen)
+  if len(waits) != 2 || waits[0].Live != 0 || waits[1].Live != 1 {
+    t.Errorf("waits should have been 🀀 (0 live) and 🀆 (1 live), but were %v", waits)
+  }
+}
+
+func TestDecompositions(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀇🀇🀇🀈🀈🀈🀉🀉🀉🀆🀆🀆🀀🀀;")
+  tileCounts, _, _ := testHand.CountHiddenTiles(EmptyTile)
+  if decompositions := Decompositions(tileCounts); len(decompositions) != 2 {
+    t.Errorf("three consecutive triples should split as triples or as sequences, but found %v", decompositions)
+  }
+  
+  testHand, _ = gt.TestHandMaker("🀇🀇🀇🀈🀈🀈🀉🀉🀉🀆🀆🀆🀀🀃;")
+  tileCounts, _, _ = testHand.CountHiddenTiles(EmptyTile)
+  if decompositions := Decompositions(tileCounts); len(decompositions) != 0 {
+    t.Errorf("an incomplete hand should not split, but found %v", decompositions)
+  }
+}
+
+func TestDiscardOptions(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀇🀈🀉🀜🀝🀞🀖🀗🀘🀀🀀🀁🀁🀂;")
+  unseen := testHand.UnseenTileCounts(DiscardPile{}, []PlayerHand{ testHand })
+  options := testHand.DiscardOptions(unseen)
+  if options[0].Item.Ud != "🀂" || options[0].Shanten != 0 || options[0].Ukeire != 4 {
+    t.Errorf("the isolated honor should have been the best discard, leaving a ready hand with 4 accepting tiles, but was %+v", options[0])
+  }
+  for i := 1; i < len(options); i++ {
+    if options[i].Shanten < options[i-1].Shanten || (options[i].Shanten == options[i-1].Shanten && options[i].Ukeire > options[i-1].Ukeire) {
+      t.Errorf("discard options are not ranked: %+v before %+v", options[i-1], options[i])
+    }
+  }
+}
+
+func TestReadyHandAssist(t *testing.T) {
+  // ready hand awaiting a tile, with one of the two other 🀀 discarded
+  g := gt.TestGameMaker("🀇🀈🀉🀜🀝🀞🀖🀗🀘🀀🀀🀁🀁")
+  for _, t := range gt.Undealt {
+    if t.Ud == "🀀" && g.Hands[0].tilePosition(t) < 0 {
+      g.Discard = DiscardPile{ DiscardedTile{ Player: 1, Item: t } }
+      break
+    }
+  }
+  
+  lines := g.ReadyHandLines(0)
+  if len(lines) != 1 || lines[0] != "P0-W: ready, waiting on 🀀×1 🀁×2" {
+    t.Errorf("unexpected ready-hand summary %q", lines)
+  }
+  
+  // hand awaiting a discard
+  g = gt.TestGameMaker("🀇🀈🀉🀜🀝🀞🀖🀗🀘🀀🀀🀁🀁🀂")
+  lines = g.ReadyHandLines(0)
+  if len(lines) != 1 || lines[0] != "P0-W: discard 🀂 to wait on 🀀×2 🀁×2" {
+    t.Errorf("unexpected ready-hand summary %q", lines)
+  }
+  
+  for position, tile := range g.Hands[0].Hidden {
+    breaks, keepReady := g.BreaksReadyHand(0, position)
+    if tile.Ud == "🀂" && breaks {
+      t.Errorf("discarding 🀂 keeps the hand ready")
+    } else if tile.Ud == "🀇" && (!breaks || keepReady != "🀂") {
+      t.Errorf("discarding 🀇 should break the ready hand kept by 🀂, but was %v, %q", breaks, keepReady)
+    }
+  }
+}
+
+func TestGradeDiscard(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀇🀈🀉🀜🀝🀞🀖🀗🀘🀀🀀🀁🀁🀂;")
+  hands := []PlayerHand{ testHand }
+  var session TrainingSession
+  
+  grade := GradeDiscard(testHand, DiscardPile{}, hands, testHand.Hidden[13])
+  session.Record(grade)
+  if !grade.Correct || len(grade.Best) != 1 {
+    t.Errorf("discarding 🀂 should have been graded optimal: %+v", grade)
+  }
+  
+  grade = GradeDiscard(testHand, DiscardPile{}, hands, testHand.Hidden[0])
+  session.Record(grade)
+  if grade.Correct || grade.Explanation != "your discard moves the hand to 1 shanten; the best (🀂) keeps it at 0 shanten with 4 accepting tiles" {
+    t.Errorf("discarding 🀇 should have been graded as breaking the ready hand: %+v", grade)
+  }
+  
+  if session.Hands != 2 || session.Correct != 1 || session.Accuracy() != 0.5 {
+    t.Errorf("session totals were not tracked: %+v", session)
+  }
+  
+  dealt := DealTrainingHand()
+  if count := occupiedCount(dealt.Hidden); count != 14 {
+    t.Errorf("a training hand should hold 14 tiles, but held %d", count)
+  }
+}
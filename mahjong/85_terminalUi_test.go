@@ -0,0 +1,103 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "bufio"
+  "io/ioutil"
+  "strings"
+  "testing"
+)
+
+func TestDecodeKey(t *testing.T) {
+  testCases := map[string]string {
+    "\u001b[D": "left",
+    "\u001b[C": "right",
+    "\u001bOA": "up",
+    "\u001b": "escape",
+    "\r": "enter",
+    "\n": "enter",
+    "P": "p",
+    "k\n": "k",
+    "h": "left",
+  }
+  
+  for input, expected := range testCases {
+    if outcome := decodeKey([]byte(input)); outcome != expected {
+      t.Errorf("key input %q should have been decoded as %s, but was %s", input, expected, outcome)
+    }
+  }
+}
+
+func TestMoveCursor(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀇🀈🀉;")
+  hidden := make([]Tile, 14, 14)
+  hidden[2] = testHand.Hidden[0]
+  hidden[5] = testHand.Hidden[1]
+  hidden[13] = testHand.Hidden[2]
+  
+  if cursor := moveCursor(hidden, 2, 1); cursor != 5 {
+    t.Errorf("cursor should have skipped empty positions to 5, but was %d", cursor)
+  }
+  if cursor := moveCursor(hidden, 13, 1); cursor != 2 {
+    t.Errorf("cursor should have wrapped to 2, but was %d", cursor)
+  }
+  if cursor := moveCursor(hidden, 2, -1); cursor != 13 {
+    t.Errorf("cursor should have wrapped backwards to 13, but was %d", cursor)
+  }
+}
+
+func TestRenderTable(t *testing.T) {
+  g := gt.TestGameMaker("🀇🀈🀉")
+  testHand, _ := gt.TestHandMaker("🀇🀈🀉;")
+  g.Hands[2].RevealedTileSets = append(g.Hands[2].RevealedTileSets, TileSet{ Kind: "triple", Tiles: "🀆🀆🀆" })
+  g.Hands[2].RevealedSets = 1
+  g.Discard = append(g.Discard, DiscardedTile{ Player: 3, Item: testHand.Hidden[0] })
+  
+  screen := g.RenderTable(0, 1, []string{ "footer line" })
+  
+  for _, expected := range []string{ "P2 (across)", "🀆🀆🀆", "P3 left", "latest: 🀇 from P3", "[🀈]", "footer line" } {
+    if !strings.Contains(screen, expected) {
+      t.Errorf("rendered table is missing %q:\n%s", expected, screen)
+    }
+  }
+}
+
+func TestReadKey(t *testing.T) {
+  // keys typed ahead or pasted arrive in one read and are queued
+  ui := &TerminalUi{ In: strings.NewReader("\u001b[Cs\rw\u001b[3~kd\nd"), Out: ioutil.Discard }
+  for _, expected := range []string{ "right", "s", "enter", "w", "", "k", "d", "d" } {
+    if key := ui.ReadKey(); key != expected {
+      t.Errorf("expected key %q, got %q", expected, key)
+    }
+  }
+}
+
+func TestChooseTileIgnoresInvalidSuggestion(t *testing.T) {
+  g := gt.TestGameMaker("🀇🀈🀉")
+  g.Hands[0].Hidden[0] = EmptyTile
+  
+  // an empty slot, then a position outside the hand, are suggested; [s] then [enter] keeps a tile
+  for _, suggestion := range []int{ 0, -1 } {
+    ui := &TerminalUi{ In: bufio.NewReader(strings.NewReader("s\r")), Out: ioutil.Discard }
+    if position := ui.ChooseDiscard(g, 0, suggestion); position != 1 {
+      t.Errorf("a suggestion of %d should have left the cursor on the first tile, 1, but chose %d", suggestion, position)
+    }
+  }
+}
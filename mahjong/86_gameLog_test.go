@@ -0,0 +1,68 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "bytes"
+  "encoding/json"
+  "log"
+  "testing"
+)
+
+func TestJsonLogAction(t *testing.T) {
+  var output bytes.Buffer
+  
+  g := New()
+  g.OutputLog = log.New(&output, "", 0)
+  g.LogFormat = LogFormatJson
+  g.GameId = "test"
+  g.DiceRoll = 12
+  g.UndealtTileCount = 80
+  g.DrawPointer = 17
+  g.ReplacementPointer = 3
+  
+  testHand, _ := gt.TestHandMaker("🀙🀆;")
+  g.LogAction(2, "pong", testHand.Hidden, "", "player 2 reveals pong")
+  
+  var entry GameAction
+  if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+    t.Fatalf("log entry %q is not valid json: %v", output.String(), err)
+  }
+  
+  if entry.GameId != "test" || entry.Seat != 2 || entry.Action != "pong" || entry.WallCount != 80 || entry.DiceRoll != 12 || entry.DrawPointer != 17 || entry.ReplacementPointer != 3 {
+    t.Errorf("log entry fields were not recorded: %+v", entry)
+  }
+  if len(entry.TileIds) != 2 || entry.TileIds[0] != testHand.Hidden[0].Id || entry.Tiles[0] != "1p" || entry.Tiles[1] != "7z" {
+    t.Errorf("log entry tiles were not recorded: %+v", entry)
+  }
+}
+
+func TestTextLogAction(t *testing.T) {
+  var output bytes.Buffer
+  
+  g := New()
+  g.OutputLog = log.New(&output, "ACTION: ", 0)
+  
+  g.LogAction(1, "replacement", nil, "", "")
+  g.LogAction(1, "discard", nil, "", "player 1 discards tile 🀙")
+  
+  if output.String() != "ACTION: player 1 discards tile 🀙\n" {
+    t.Errorf("unexpected text log output %q", output.String())
+  }
+}
@@ -0,0 +1,61 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+func TestGeneratePuzzle(t *testing.T) {
+  for _, kind := range []string{ PuzzleDiscard, PuzzleWaits } {
+    for seed := int64(1); seed <= 5; seed++ {
+      p, err := GeneratePuzzle(kind, seed)
+      if err != nil {
+        t.Fatalf("%s puzzle for seed %d: %v", kind, seed, err)
+      }
+      if !p.WellPosed() {
+        t.Errorf("%s puzzle for seed %d is not well posed: %s", kind, seed, p.Notation(true))
+      }
+      
+      again, _ := GeneratePuzzle(kind, seed)
+      if again.Notation(true) != p.Notation(true) {
+        t.Errorf("seed %d gave %q then %q", seed, p.Notation(true), again.Notation(true))
+      }
+      
+      shared, err := ParsePuzzle(p.Notation(false))
+      if err != nil || shared.Notation(true) != p.Notation(true) {
+        t.Errorf("shared puzzle %q solved as %q (%v)", p.Notation(true), shared.Notation(true), err)
+      }
+    }
+  }
+}
+
+func TestSolvePuzzle(t *testing.T) {
+  p, err := ParsePuzzle("waits 1112345678999m seed=3")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if p.Answer() != "123456789m" || p.Seed != 3 {
+    t.Errorf("nine gates waits were %q (seed %d)", p.Answer(), p.Seed)
+  }
+  
+  if _, err := ParsePuzzle("discard 1112345678999m seed=3"); err == nil {
+    t.Errorf("a 13-tile discard puzzle was accepted")
+  }
+}
@@ -0,0 +1,182 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "io/ioutil"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestLoadRuleSet(t *testing.T) {
+  dir := t.TempDir()
+  
+  jsonFile := filepath.Join(dir, "house.json")
+  ioutil.WriteFile(jsonFile, []byte(`{ "flowers": false, "minimumFaan": 1, "claimPriority": ["win", "pong", "kong"] }`), 0644)
+  r, err := LoadRuleSet(jsonFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if r.Name != "house" || r.Flowers || r.MinimumFaan != 1 || len(r.ClaimPriority) != 3 || !r.ChowAllowed || r.TileCount() != 136 {
+    t.Errorf("json rules were not read over the default preset: %+v", r)
+  }
+  
+  tomlFile := filepath.Join(dir, "club.toml")
+  ioutil.WriteFile(tomlFile, []byte("# club night\nchowAllowed = false\nspecialHands = []\nexhaustiveDraw = \"rotate\" # dealer passes\n"), 0644)
+  r, err = LoadRuleSet(tomlFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if r.ChowAllowed || len(r.SpecialHands) != 0 || r.ExhaustiveDraw != ExhaustiveDrawRotate || !r.Flowers {
+    t.Errorf("toml rules were not read over the default preset: %+v", r)
+  }
+  
+  badFile := filepath.Join(dir, "bad.json")
+  for _, bad := range []string{ `{ "chow": true }`, `{ "claimPriority": ["pong", "win", "pong"] }`, `{ "exhaustiveDraw": "replay" }`, `{ "specialHands": ["allRed"] }`, `{ "handPatterns": ["seven pairs: pair*7"] }`, `{ "handSize": 14 }`, `{ "players": 2 }`, `{ "shortSuit": "z" }` } {
+    ioutil.WriteFile(badFile, []byte(bad), 0644)
+    if _, err := LoadRuleSet(badFile); err == nil {
+      t.Errorf("rules %s were accepted", bad)
+    }
+  }
+  
+  if r, err := LoadRuleSet("hongkong"); err != nil || r.MinimumFaan != 3 {
+    t.Errorf("hongkong preset was not found: %+v %v", r, err)
+  }
+}
+
+func TestParseToml(t *testing.T) {
+  values, err := parseToml([]byte(`# house rules
+specialHands = [
+  "thirteenOrphans", # the classic limit
+  "sevenPairs",
+]
+handPatterns = ["snake, wriggling: 123456789a ESWN tile[a] ; 13"]
+name = "club #1" # night
+minimumFaan = 1
+`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(values["specialHands"], []string{ "thirteenOrphans", "sevenPairs" }) {
+    t.Errorf("unexpected multi-line array %v", values["specialHands"])
+  }
+  if !reflect.DeepEqual(values["handPatterns"], []string{ "snake, wriggling: 123456789a ESWN tile[a] ; 13" }) {
+    t.Errorf("a comma in a string should not split the array: %v", values["handPatterns"])
+  }
+  if values["name"] != "club #1" || values["minimumFaan"] != 1 {
+    t.Errorf("unexpected values %v", values)
+  }
+
+  // what the subset does not support is named
+  for text, expected := range map[string]string{
+    "[scoring]\nminimumFaan = 1": "tables",
+    "a.b = 1": "bare keys",
+    "minimumFaan = 1.5": "unsupported value",
+    "specialHands = [1, 2]": "arrays of strings only",
+    "specialHands = [\"sevenPairs\"": "not closed",
+    "name = \"\"\"club\"\"\"": "multi-line strings",
+    "flowers = true\nflowers = false": "set twice",
+  } {
+    if _, err := parseToml([]byte(text)); err == nil || !strings.Contains(err.Error(), expected) {
+      t.Errorf("%q should have been refused with %q, got %v", text, expected, err)
+    }
+  }
+}
+
+func TestClaimPriority(t *testing.T) {
+  g := gt.TestGameMaker()
+  g.Discard = DiscardPile{ DiscardedTile{ Player: 2 } }
+  
+  expected := []StateUnit{
+    { Player: 3, State: "HaveWin" }, { Player: 0, State: "HaveWin" }, { Player: 1, State: "HaveWin" },
+    { Player: 3, State: "HaveKong" }, { Player: 0, State: "HaveKong" }, { Player: 1, State: "HaveKong" },
+    { Player: 3, State: "HavePong" }, { Player: 0, State: "HavePong" }, { Player: 1, State: "HavePong" },
+    { Player: 3, State: "HaveSeq" }, { Player: 3, State: "DrawTile" } }
+  
+  state := g.claimStateAfter(2, "")
+  claims := map[string]string{ "HaveWin": "win", "HaveKong": "kong", "HavePong": "pong", "HaveSeq": "chow" }
+  for i, e := range expected {
+    if state.Player != e.Player || state.State != e.State {
+      t.Fatalf("step %d: expected %v, got %v", i, e, state)
+    }
+    if state.State != "DrawTile" {
+      state = g.nextClaimState(state, claims[state.State])
+    }
+  }
+  
+  // pong ahead of kong and no chow
+  g.Rules.ClaimPriority = []string{ "win", "pong", "kong", "chow" }
+  g.Rules.ChowAllowed = false
+  if state := g.nextClaimState(StateUnit{ Player: 1, State: "HaveWin" }, "win"); state.State != "HavePong" || state.Player != 3 {
+    t.Errorf("pong should follow win, got %v", state)
+  }
+  if state := g.nextClaimState(StateUnit{ Player: 1, State: "HaveKong" }, "kong"); state.State != "DrawTile" || state.Player != 3 {
+    t.Errorf("the next player should draw without chow, got %v", state)
+  }
+  if outcome, _ := g.Hands[3].HaveSeq(EmptyTile, "previous"); outcome {
+    t.Errorf("sequence offered with chow disallowed")
+  }
+}
+
+func TestMinimumFaan(t *testing.T) {
+  // all sequences, mixed suits, claimed from a discard
+  g := gt.TestGameMaker("🀑🀒🀓🀉🀊🀋🀝🀞🀟🀙🀚🀛🀆")
+  g.Rules, _ = PresetRules("hongkong")
+  _, discard := gt.TestHandMaker(";🀆")
+  
+  g.Rules.Flowers = false
+  if win, score := g.HaveQualifyingWin(0, discard, "other"); win || score.Points != 2 {
+    t.Errorf("a %v win should be below the hongkong minimum", score)
+  }
+  
+  g.Rules.Flowers = true
+  if win, score := g.HaveQualifyingWin(0, discard, "other"); !win || score.Points != 3 {
+    t.Errorf("a %v win should meet the hongkong minimum", score)
+  }
+}
+
+func TestScoreHongKong(t *testing.T) {
+  testHand, _ := gt.TestHandMaker("🀀🀀🀀🀄🀄🀄🀅🀅🀅🀆🀆🀇🀈🀉;")
+  score := ScoreWin(WinContext{ Hand: testHand, Source: "draw", SeatWind: 1, PrevailingWind: 1, Rules: RulePresets["simple"] })
+  // self-drawn 1, concealed 1, seat wind 1, prevailing wind 1, small dragons 5, mixed one suit 3
+  if score.Points != 12 || score.Limit {
+    t.Errorf("expected 12 faan, got %v", score)
+  }
+  
+  testHand, _ = gt.TestHandMaker("🀀🀁🀂🀃🀄🀅🀆🀙🀐🀇🀡🀘🀏🀀;")
+  score = ScoreWin(WinContext{ Hand: testHand, Source: "draw", SeatWind: 2, PrevailingWind: 1, Rules: RulePresets["classic"] })
+  if score.Points != HongKongLimit || !score.Limit {
+    t.Errorf("thirteen orphans should be a limit hand, got %v", score)
+  }
+  
+  if testHand.HaveWin(EmptyTile, "draw") != true || (PlayerHand{ Hidden: testHand.Hidden, Rules: &RuleSet{ Name: "none" } }).HaveWin(EmptyTile, "draw") {
+    t.Errorf("thirteen orphans should only win when enabled")
+  }
+  
+  // seven pairs beats the reading as sequences: seven pairs 4, self-drawn 1, all one suit 7
+  rules, _ := PresetRules("classic")
+  rules.SpecialHands = append(rules.SpecialHands, "sevenPairs")
+  testHand, _ = gt.TestHandMaker("🀇🀇🀈🀈🀉🀉🀊🀊🀋🀋🀌🀌🀍🀍;")
+  score = ScoreWin(WinContext{ Hand: testHand, Source: "draw", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.Points != 12 || score.Patterns[0].Name != "seven pairs" {
+    t.Errorf("expected seven pairs for 12 faan, got %v", score)
+  }
+}
@@ -0,0 +1,108 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+func TestScoreRiichi(t *testing.T) {
+  rules := RulePresets["riichi"]
+  testHand, testTile := gt.TestHandMaker("🀈🀉🀊🀌🀍🀎🀛🀜🀝🀕🀖🀚🀚;🀔")
+  testHand.Riichi = true
+  
+  // riichi, all simples and pinfu on a discard
+  score := ScoreWin(WinContext{ Hand: testHand, Consider: testTile, WinningTile: testTile, Source: "other", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.Points != 3 || score.Fu != 30 || score.BasePoints != 960 {
+    t.Errorf("expected 3 han 30 fu, got %v (%d basic points)", score, score.BasePoints)
+  }
+  
+  // self-drawn pinfu is 20 fu
+  testHand.Hidden = append(testHand.Hidden, testTile)
+  score = ScoreWin(WinContext{ Hand: testHand, WinningTile: testTile, Source: "draw", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.Points != 4 || score.Fu != 20 || score.BasePoints != 1280 {
+    t.Errorf("expected 4 han 20 fu, got %v (%d basic points)", score, score.BasePoints)
+  }
+  
+  // dora do not count as yaku
+  testHand, testTile = gt.TestHandMaker("🀇🀈🀉🀌🀍🀎🀛🀜🀝🀕🀖🀚🀚;🀔")
+  indicator, _ := gt.TestHandMaker("🀙;")
+  score = ScoreWin(WinContext{ Hand: testHand, Consider: testTile, WinningTile: testTile, Source: "previous", SeatWind: 2, PrevailingWind: 1, Rules: rules, DoraIndicators: indicator.Hidden })
+  if score.QualifyingPoints() != 1 || score.Points != 3 {
+    t.Errorf("expected pinfu with two dora, got %v", score)
+  }
+  
+  // seven pairs and all simples at 25 fu
+  testHand, testTile = gt.TestHandMaker("🀈🀈🀉🀉🀜🀜🀝🀝🀕🀕🀖🀖🀗;🀗")
+  score = ScoreWin(WinContext{ Hand: testHand, Consider: testTile, WinningTile: testTile, Source: "other", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.Points != 3 || score.Fu != RiichiSevenPairsFu || score.BasePoints != 800 {
+    t.Errorf("expected seven pairs and all simples, 3 han 25 fu, got %v (%d basic points)", score, score.BasePoints)
+  }
+  
+  // yakuman
+  testHand, testTile = gt.TestHandMaker("🀄🀄🀄🀅🀅🀅🀆🀆🀆🀇🀇🀈🀈;🀇")
+  score = ScoreWin(WinContext{ Hand: testHand, Consider: testTile, WinningTile: testTile, Source: "other", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.LimitName != "yakuman" || score.BasePoints != RiichiYakumanPoints {
+    t.Errorf("expected big three dragons, got %v", score)
+  }
+}
+
+func TestDoraFromIndicator(t *testing.T) {
+  testCases := map[string][2]int{ "9m": { 3, 1 }, "4p": { 1, 5 }, "4z": { 4, 1 }, "2z": { 4, 3 }, "7z": { 4, 5 }, "5z": { 4, 6 } }
+  for notation, dora := range testCases {
+    tiles, _ := NewTilePool().Parse(notation)
+    if suit, value := DoraFromIndicator(tiles[0]); suit != dora[0] || value != dora[1] {
+      t.Errorf("indicator %s shows %d-%d, not %v", notation, suit, value, dora)
+    }
+  }
+  
+  tiles, err := NewTilePool().Parse("0p55p")
+  if err != nil || !tiles[0].Red || tiles[1].Red || tiles[0].Notation() != "0p" || tiles[0].Id == tiles[1].Id {
+    t.Errorf("red five was not parsed: %v %v", tiles, err)
+  }
+}
+
+func TestFuriten(t *testing.T) {
+  g := gt.TestGameMaker("🀈🀉🀊🀌🀍🀎🀛🀜🀝🀕🀖🀚🀚")
+  g.Rules, _ = PresetRules("riichi")
+  _, waitTile := gt.TestHandMaker(";🀗")
+  
+  if g.Furiten(0) {
+    t.Errorf("hand without discards should not be furiten")
+  }
+  if win, _ := g.HaveQualifyingWin(0, waitTile, "other"); !win {
+    t.Errorf("pinfu should win on a discard")
+  }
+  
+  g.Discard = DiscardPile{ DiscardedTile{ Player: 1, Item: waitTile } }
+  if g.Furiten(0) {
+    t.Errorf("another player's discard should not leave the hand furiten")
+  }
+  
+  g.Discard = append(g.Discard, DiscardedTile{ Player: 0, Item: waitTile })
+  if !g.Furiten(0) {
+    t.Errorf("a wait among the player's own discards should leave the hand furiten")
+  }
+  if win, _ := g.HaveQualifyingWin(0, waitTile, "other"); win {
+    t.Errorf("a furiten hand should not win on a discard")
+  }
+  if len(g.RiichiDiscards(0)) != 0 {
+    t.Errorf("riichi offered with a 13-tile hand")
+  }
+}
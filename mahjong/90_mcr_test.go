@@ -0,0 +1,157 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+// win context of a hand given in notation; the last hidden tile is the winning tile
+func mcrTestWin(t *testing.T, hidden string, revealed string, source string) WinContext {
+  pool := NewTilePool()
+  tiles, err := pool.Parse(hidden)
+  if err != nil {
+    t.Fatal(err)
+  }
+  sets, err := pool.ParseSets(revealed)
+  if err != nil {
+    t.Fatal(err)
+  }
+  h := PlayerHand{ Hidden: tiles, RevealedSets: len(sets), RevealedTileSets: sets }
+  w := WinContext{ Hand: h, WinningTile: tiles[len(tiles)-1], Source: source, SeatWind: 2, PrevailingWind: 1, Rules: RulePresets["mcr"] }
+  if source != "draw" {
+    w.Hand.Hidden = tiles[:len(tiles)-1]
+    w.Consider = w.WinningTile
+  }
+  return w
+}
+
+// score a hand given in notation; the last hidden tile is the winning tile
+func mcrTestScore(t *testing.T, hidden string, revealed string, source string) ScoredWin {
+  return ScoreWin(mcrTestWin(t, hidden, revealed, source))
+}
+
+// points of a fan in the score, or zero
+func fanPoints(s ScoredWin, name string) int {
+  for _, pattern := range s.Patterns {
+    if pattern.Name == name {
+      return pattern.Points
+    }
+  }
+  return 0
+}
+
+func TestScoreMcr(t *testing.T) {
+  // pure straight excludes short straight and two terminal chows; waited on the 5s alone
+  score := mcrTestScore(t, "123456789m123p55s", "", "draw")
+  expected := map[string]int{ "pure straight": 16, "fully concealed hand": 4, "all chows": 2, "mixed double chow": 1, "single wait": 1 }
+  for name, points := range expected {
+    if fanPoints(score, name) != points {
+      t.Errorf("expected %s %d in %v", name, points, score)
+    }
+  }
+  if score.Points != 24 || fanPoints(score, "short straight") != 0 || fanPoints(score, "self-drawn") != 0 || fanPoints(score, "no honors") != 0 {
+    t.Errorf("expected 24 fan with exclusions applied, got %v", score)
+  }
+  
+  // big three dragons excludes the dragon pungs
+  score = mcrTestScore(t, "555666777z123m99p", "", "other")
+  if fanPoints(score, "big three dragons") != 88 || fanPoints(score, "dragon pung") != 0 || fanPoints(score, "two dragon pungs") != 0 {
+    t.Errorf("expected big three dragons alone, got %v", score)
+  }
+  
+  // seven shifted pairs excludes seven pairs and full flush
+  score = mcrTestScore(t, "11223344556677p", "", "draw")
+  if fanPoints(score, "seven shifted pairs") != 88 || fanPoints(score, "seven pairs") != 0 || fanPoints(score, "full flush") != 0 || score.Points != 92 {
+    t.Errorf("expected seven shifted pairs with a fully concealed hand, 92 fan, got %v", score)
+  }
+  score = mcrTestScore(t, "11223344556688p", "", "draw")
+  if fanPoints(score, "seven shifted pairs") != 0 || fanPoints(score, "seven pairs") != 24 {
+    t.Errorf("expected seven pairs that are not consecutive to score as seven pairs, got %v", score)
+  }
+  
+  // nothing but the hand itself
+  score = mcrTestScore(t, "56p345s666s11z7p", "234m", "other")
+  if fanPoints(score, "chicken hand") != 8 || score.Points != 8 {
+    t.Errorf("expected a chicken hand, got %v", score)
+  }
+  
+  // the best reading: three identical chows rather than three pungs
+  score = mcrTestScore(t, "111222333m789p99s", "", "other")
+  if fanPoints(score, "pure triple chow") != 0 && fanPoints(score, "three concealed pungs") != 0 {
+    t.Errorf("readings were combined: %v", score)
+  }
+  if fanPoints(score, "pure shifted pungs") != 24 {
+    t.Errorf("expected pure shifted pungs, got %v", score)
+  }
+}
+
+func TestMcrSevenPairs(t *testing.T) {
+  // four of a tile are two pairs and a tile hog; seven pairs excludes concealed hand and single wait
+  score := mcrTestScore(t, "2222m33m55m66m88m11z", "", "other")
+  expected := map[string]int{ "seven pairs": 24, "half flush": 6, "tile hog": 2 }
+  for name, points := range expected {
+    if fanPoints(score, name) != points {
+      t.Errorf("expected %s %d in %v", name, points, score)
+    }
+  }
+  if score.Points != 32 || fanPoints(score, "single wait") != 0 || fanPoints(score, "concealed hand") != 0 {
+    t.Errorf("expected 32 fan with exclusions applied, got %v", score)
+  }
+}
+
+func TestMcrKongWins(t *testing.T) {
+  // out with replacement tile excludes self-drawn
+  w := mcrTestWin(t, "123m456p789s11z", "5555s", "draw")
+  w.KongReplacement = true
+  score := ScoreWin(w)
+  if fanPoints(score, "out with replacement tile") != 8 || fanPoints(score, "self-drawn") != 0 {
+    t.Errorf("expected out with replacement tile without self-drawn, got %v", score)
+  }
+  
+  // robbing the kong excludes last tile
+  w = mcrTestWin(t, "123m456p789s123m11z", "", "other")
+  w.RobbedKong, w.LastCopy = true, true
+  score = ScoreWin(w)
+  if fanPoints(score, "robbing the kong") != 8 || fanPoints(score, "last tile") != 0 {
+    t.Errorf("expected robbing the kong without last tile, got %v", score)
+  }
+}
+
+func TestMcrMinimum(t *testing.T) {
+  g := gt.TestGameMaker()
+  g.Rules, _ = PresetRules("mcr")
+  g.UndealtTileCount = 50
+  pool := NewTilePool()
+  hidden, _ := pool.Parse("234m567m45p678s99s")
+  copy(g.Hands[0].Hidden, hidden)
+  discard, _ := pool.Parse("3p")
+  
+  // all chows, concealed hand and short straight are five fan
+  if win, score := g.HaveQualifyingWin(0, discard[0], "other"); win || score.Points != 5 {
+    t.Errorf("a %v win should be below the mcr minimum", score)
+  }
+  
+  // flower tiles do not count towards the minimum
+  flowers, _ := gt.TestHandMaker("🀢🀣🀤🀥;")
+  copy(g.Hands[0].Revealed, flowers.Hidden)
+  if win, score := g.HaveQualifyingWin(0, discard[0], "other"); win || score.Points != 9 {
+    t.Errorf("a %v win should still be below the mcr minimum", score)
+  }
+}
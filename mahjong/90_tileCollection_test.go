@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package mahjong
 
 import (
+  "strings"
   "testing"
 )
 
@@ -44,3 +45,115 @@ func TestTileCollectionPopulation(t *testing.T) {
 }
 
 // TODO: ensure shuffle fails when already attempted
+
+func TestWall(t *testing.T) {
+  for _, name := range []string {"classic", "riichi", "sanma"} {
+    g := New()
+    g.Rules, _ = PresetRules(name)
+    g.Initialize(0, []bool{ true, true, true, true })
+    w := g.Wall()
+    
+    if len(w.Sides) != g.Rules.Players || w.SideLength != len(g.Undealt)/g.Rules.Players {
+      t.Errorf("%s: expected %d sides of %d tiles, got %d of %d", name, g.Rules.Players, len(g.Undealt)/g.Rules.Players, len(w.Sides), w.SideLength)
+    }
+    
+    remaining, dead := 0, 0
+    for _, side := range w.Sides {
+      if len(side) != w.SideLength/2 {
+        t.Errorf("%s: expected %d stacks on each side, got %d", name, w.SideLength/2, len(side))
+      }
+      for _, stack := range side {
+        for _, tile := range []Tile{ stack.Top, stack.Bottom } {
+          if tile != EmptyTile {
+            remaining++
+            if stack.Dead {
+              dead++
+            }
+          }
+        }
+      }
+    }
+    if remaining != g.UndealtTileCount || w.Remaining != g.UndealtTileCount {
+      t.Errorf("%s: the wall holds %d tiles and reports %d, not %d", name, remaining, w.Remaining, g.UndealtTileCount)
+    }
+    if w.DeadTiles != g.Rules.DeadWall+g.Rules.ReservedTiles || dead < w.DeadTiles {
+      t.Errorf("%s: expected a dead wall of %d tiles, got %d in stacks holding %d", name, g.Rules.DeadWall+g.Rules.ReservedTiles, w.DeadTiles, dead)
+    }
+    
+    // the deal takes the stacks from the break onwards
+    if side, stack := w.Locate(g.AllocationStart % len(g.Undealt)); side != w.BreakSide || stack != w.BreakStack || w.glyph(side, stack) != WallBreakGlyph {
+      t.Errorf("%s: expected the dealt break at side %d, stack %d, got side %d, stack %d shown as %s", name, side, stack, w.BreakSide, w.BreakStack, w.glyph(side, stack))
+    }
+    
+    lines := w.Render(0)
+    if len(lines) != w.SideLength/2+3 {
+      t.Errorf("%s: expected %d lines, got %d", name, w.SideLength/2+3, len(lines))
+    }
+    glyphs := 0
+    for _, line := range lines[:len(lines)-1] {
+      glyphs += len([]rune(strings.Replace(line, " ", "", -1)))
+    }
+    if glyphs != len(g.Undealt)/2 {
+      t.Errorf("%s: expected a glyph for each of %d stacks, got %d", name, len(g.Undealt)/2, glyphs)
+    }
+  }
+}
+
+func TestReservedTiles(t *testing.T) {
+  tests := []struct {
+    rules string
+    deadWall int
+    reserved int
+    // tiles left once replacements run out
+    lastReplacement int
+    // how the game is drawn once the live wall is exhausted
+    drawn string
+  }{
+    { "classic", 0, 0, 0, "the wall is empty" },
+    { "riichi", RiichiDeadWall, 0, 0, "the live wall is exhausted" },
+    { "taiwanese", 0, TaiwaneseReservedTiles, TaiwaneseReservedTiles, "the live wall is exhausted" },
+  }
+  
+  for _, test := range tests {
+    g := New()
+    g.Rules, _ = PresetRules(test.rules)
+    g.Initialize(0, []bool{ true, true, true, true })
+    
+    // drawn tiles are discarded so that every tile stays accounted for
+    discard := func(tile Tile) {
+      g.Discard = append(g.Discard, DiscardedTile{ Player: g.CurrentPlayer, Item: tile })
+    }
+    
+    for g.LiveTiles() > 0 {
+      tile, err := g.GetNewTile(&g.DrawPointer, false)
+      if err != nil {
+        t.Fatalf("%s: draw with %d live tiles: %v", test.rules, g.LiveTiles(), err)
+      }
+      discard(tile)
+    }
+    if g.UndealtTileCount != test.deadWall+test.reserved {
+      t.Errorf("%s: expected the live wall to end with %d tiles left, got %d", test.rules, test.deadWall+test.reserved, g.UndealtTileCount)
+    }
+    
+    state := g.processState(StateUnit{ Player: g.CurrentPlayer, State: "DrawTile", Phase: "DrawProcessing" })
+    if state.State != "DrawGame" || !strings.HasPrefix(g.DrawReason, test.drawn) {
+      t.Errorf("%s: expected a draw as %s, got %v: %q", test.rules, test.drawn, state, g.DrawReason)
+    }
+    
+    // replacements come from the dead wall, never the reserved tiles
+    for {
+      tile, err := g.GetNewTile(&g.ReplacementPointer, true)
+      if err != nil {
+        break
+      }
+      discard(tile)
+    }
+    if g.UndealtTileCount != test.lastReplacement {
+      t.Errorf("%s: expected replacements to stop with %d tiles left, got %d", test.rules, test.lastReplacement, g.UndealtTileCount)
+    }
+    state = g.processState(StateUnit{ Player: g.CurrentPlayer, State: "DrawReplacementTile", Phase: "DrawProcessing" })
+    if state.State != "DrawGame" || len(g.DrawReason) == 0 {
+      t.Errorf("%s: expected a draw without a replacement tile, got %v: %q", test.rules, state, g.DrawReason)
+    }
+  }
+}
@@ -0,0 +1,86 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+func TestTaiwaneseDeal(t *testing.T) {
+  g := New()
+  g.Rules, _ = PresetRules("taiwanese")
+  g.Initialize(0, []bool{ true, true, true, true })
+  
+  for i, h := range g.Hands {
+    expected := LongHandSize
+    if i == g.StartPlayer {
+      expected++
+    }
+    tiles := 0
+    for _, tile := range h.Hidden {
+      if tile != EmptyTile {
+        tiles++
+      }
+    }
+    if tiles != expected {
+      t.Errorf("player %d was dealt %d tiles, not %d", i, tiles, expected)
+    }
+  }
+  
+  // every dealt tile and replaced flower came from the wall
+  dealt := 0
+  for _, h := range g.Hands {
+    for _, tile := range append(append([]Tile{}, h.Hidden...), h.Revealed...) {
+      if tile != EmptyTile {
+        dealt++
+      }
+    }
+  }
+  if dealt != TilesInGame - g.UndealtTileCount {
+    t.Errorf("%d tiles were dealt but the wall lost %d", dealt, TilesInGame - g.UndealtTileCount)
+  }
+}
+
+func TestScoreTaiwanese(t *testing.T) {
+  rules, _ := PresetRules("taiwanese")
+  tiles, _ := NewTilePool().Parse("123456789m234p567s99s")
+  h := PlayerHand{ Hidden: tiles[:len(tiles)-1], Rules: &rules }
+  winningTile := tiles[len(tiles)-1]
+  
+  if !h.HaveWin(winningTile, "other") {
+    t.Errorf("five sets and a pair should win")
+  }
+  if h.HaveWin(EmptyTile, "draw") {
+    t.Errorf("a hand short of a tile should not win")
+  }
+  
+  // concealed hand and single wait
+  score := ScoreWin(WinContext{ Hand: h, Consider: winningTile, WinningTile: winningTile, Source: "other", SeatWind: 2, PrevailingWind: 1, Rules: rules })
+  if score.Points != 2 || score.Unit != "tai" {
+    t.Errorf("expected 2 tai, got %v", score)
+  }
+  
+  tiles, _ = NewTilePool().Parse("555666z111222333m7z7z")
+  h = PlayerHand{ Hidden: tiles, Rules: &rules }
+  score = ScoreWin(WinContext{ Hand: h, WinningTile: tiles[len(tiles)-1], Source: "draw", SeatWind: 1, PrevailingWind: 1, Rules: rules })
+  // dealer 1, concealed self-drawn 3, single wait 1, two dragons 2, all triplets 4, five concealed triplets 8, little three dragons 4, half flush 4
+  if score.Points != 27 {
+    t.Errorf("expected 27 tai, got %v", score)
+  }
+}
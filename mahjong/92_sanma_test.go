@@ -0,0 +1,89 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestSanmaDeal(t *testing.T) {
+  g := New()
+  g.Rules, _ = PresetRules("sanma")
+  g.Initialize(1, []bool{ true, true, true, true })
+  
+  if len(g.Hands) != 3 || g.Rules.TileCount() != 108 {
+    t.Fatalf("expected three hands and 108 tiles, got %d hands and %d tiles", len(g.Hands), g.Rules.TileCount())
+  }
+  
+  dealt := 0
+  for i, h := range g.Hands {
+    hidden := 0
+    for _, tile := range h.Hidden {
+      if tile == EmptyTile {
+        continue
+      }
+      hidden++
+      if h.IsBonus(tile) {
+        t.Errorf("player %d holds the bonus tile %v", i, tile)
+      }
+    }
+    expected := StandardHandSize
+    if i == g.StartPlayer {
+      expected++
+    }
+    if hidden != expected {
+      t.Errorf("player %d was dealt %d tiles, not %d", i, hidden, expected)
+    }
+    dealt += hidden + occupiedCount(h.Revealed)
+  }
+  if dealt != g.Rules.TileCount() - g.UndealtTileCount {
+    t.Errorf("%d tiles were dealt but the wall lost %d", dealt, g.Rules.TileCount() - g.UndealtTileCount)
+  }
+  
+  for _, tile := range g.Undealt {
+    if tile.Suit == 3 && tile.Value >= 2 && tile.Value <= 8 {
+      t.Errorf("removed tile %v is in the wall", tile)
+    }
+  }
+  
+  // seats and claims wrap around three players
+  if g.SeatWind(0) != 3 || g.SeatWind(2) != 2 {
+    t.Errorf("with player 1 dealing, expected seats 3 and 2, got %d and %d", g.SeatWind(0), g.SeatWind(2))
+  }
+  if next := g.claimStateAfter(2, ""); next.Player != 0 || next.State != "HaveWin" {
+    t.Errorf("claims after player 2 discards should start with player 0, got %v", next)
+  }
+  if next := g.claimStateAfter(2, "pong"); next.State != "DrawTile" {
+    t.Errorf("chow should not be offered, got %v", next)
+  }
+}
+
+func TestSanmaSpecialHands(t *testing.T) {
+  rules, _ := PresetRules("sanma")
+  if err := rules.Validate(); err != nil {
+    t.Errorf("the sanma preset should be valid: %v", err)
+  }
+  
+  // norths are bonus tiles, so thirteen orphans can never be completed
+  rules.SpecialHands = []string{ "thirteenOrphans" }
+  if err := rules.Validate(); err == nil || !strings.Contains(err.Error(), "needs a north") {
+    t.Errorf("expected thirteen orphans to be refused with north bonus tiles, got %v", err)
+  }
+}
@@ -0,0 +1,179 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "testing"
+)
+
+const americanTestCard = `
+# test card
+2468 one suit: 222a 4444a 666a 8888a ; 25
+like kongs: FF 1111a 1111b 1111c ; 25 shift
+run of pairs: 11a 22a 33a 44a 55a 66a 77a ; 50 c shift
+`
+
+// american rules with the test card
+func americanTestRules(t *testing.T) *RuleSet {
+  rules, _ := PresetRules("american")
+  hands, err := ParseCard(americanTestCard)
+  if err != nil {
+    t.Fatalf("test card: %v", err)
+  }
+  rules.CardHands = hands
+  return &rules
+}
+
+// hand of the given tiles under the rules
+func americanTestHand(t *testing.T, rules *RuleSet, tiles string) PlayerHand {
+  parsed, err := NewTilePool().Parse(tiles)
+  if err != nil {
+    t.Fatalf("%s: %v", tiles, err)
+  }
+  h := PlayerHand{ Hidden: make([]Tile, StandardHandSize+1, StandardHandSize+1), Rules: rules }
+  copy(h.Hidden, parsed)
+  return h
+}
+
+func TestParseCard(t *testing.T) {
+  hands, err := ParseCard(americanTestCard)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(hands) != 3 || hands[1].Name != "like kongs" || hands[1].Value != 25 || !hands[1].Shift || !hands[2].Concealed {
+    t.Errorf("unexpected hands %v", hands)
+  }
+  // three suits in any order, each of the nine numbers
+  if len(hands[1].variants) != 6*9 {
+    t.Errorf("expected 54 choices for like kongs, got %d", len(hands[1].variants))
+  }
+  if hands[2].TakesJokers() || !hands[0].TakesJokers() {
+    t.Errorf("only the pairs hand should be played without jokers")
+  }
+
+  badCards := []string {
+    "too short: 222a 444a ; 25",
+    "unknown symbol: 222a 4444a 666a 888X ; 25",
+    "no suit: 222 4444a 666a 8888a ; 25",
+    "no value: 222a 4444a 666a 8888a ; x",
+    "no separator 222a 4444a 666a 8888a 25",
+    "# only a comment",
+  }
+  for _, card := range badCards {
+    if _, err := ParseCard(card); err == nil {
+      t.Errorf("card %q should not parse", card)
+    }
+  }
+}
+
+func TestCardWin(t *testing.T) {
+  rules := americanTestRules(t)
+  pool := NewTilePool()
+  eightBamboo, _ := pool.Take(2, 8)
+  joker, _ := pool.Take(6, 1)
+  sevenDots, _ := pool.Take(1, 7)
+
+  // a joker completes the kong of fours
+  h := americanTestHand(t, rules, "222s444s1j666s888s")
+  if !h.HaveWin(eightBamboo, "previous") {
+    t.Errorf("%s with %s should win", TilesNotation(h.Hidden), eightBamboo.Notation())
+  }
+  if h.HaveWin(joker, "previous") {
+    t.Errorf("a discarded joker should be dead")
+  }
+  if !h.HaveWin(joker, "draw") {
+    t.Errorf("a drawn joker should complete the kong of eights")
+  }
+  if distance, hand := americanTestHand(t, rules, "222s444s666s888s").CardDistance(EmptyTile); distance != 2 || hand.Name != "2468 one suit" {
+    t.Errorf("expected 2 tiles from 2468 one suit, got %d from %s", distance, hand.Name)
+  }
+
+  // jokers never stand in for a pair
+  if americanTestHand(t, rules, "1f1j3333p3333s3333m").HaveWin(EmptyTile, "draw") {
+    t.Errorf("a joker should not complete the pair of flowers")
+  }
+  if americanTestHand(t, rules, "33p44p55p66p77p88p1j").HaveWin(sevenDots, "previous") {
+    t.Errorf("a joker should not complete a pair of the run")
+  }
+
+  // shifted run of pairs, concealed only
+  h = americanTestHand(t, rules, "11p22p33p44p55p66p7p")
+  if !h.HaveWin(sevenDots, "previous") {
+    t.Errorf("%s with %s should win", TilesNotation(h.Hidden), sevenDots.Notation())
+  }
+  h = americanTestHand(t, rules, "33p44p55p66p77p88p9p")
+  if win := h.HaveWin(EmptyTile, "draw"); win {
+    t.Errorf("thirteen tiles should not win")
+  }
+}
+
+func TestScoreAmerican(t *testing.T) {
+  rules := americanTestRules(t)
+
+  h := americanTestHand(t, rules, "1f2f3333p3333s3333m")
+  score := ScoreWin(WinContext{ Hand: h, Source: "draw", Rules: *rules })
+  if score.Points != 100 || len(score.Patterns) != 3 || score.Patterns[0].Name != "like kongs" {
+    t.Errorf("expected like kongs doubled for self-drawn and jokerless (100), got %v", score)
+  }
+
+  eightBamboo, _ := NewTilePool().Take(2, 8)
+  h = americanTestHand(t, rules, "222s444s1j666s888s")
+  score = ScoreWin(WinContext{ Hand: h, Consider: eightBamboo, Source: "previous", Rules: *rules })
+  if score.Points != 25 {
+    t.Errorf("expected 25 for 2468 with a joker on a discard, got %v", score)
+  }
+}
+
+func TestCharleston(t *testing.T) {
+  g := New()
+  g.Rules = *americanTestRules(t)
+  g.Initialize(0, []bool{ true, true, true, true })
+
+  if g.Rules.TileCount() != 152 || g.Rules.BonusTileCount() != 0 {
+    t.Fatalf("expected 152 tiles and no bonus tiles, got %d and %d", g.Rules.TileCount(), g.Rules.BonusTileCount())
+  }
+
+  jokers := func(h PlayerHand) int {
+    count := 0
+    for _, tile := range h.Hidden {
+      if tile.IsJoker() {
+        count++
+      }
+    }
+    return count
+  }
+  tilesBefore := make([]int, len(g.Hands))
+  jokersBefore := make([]int, len(g.Hands))
+  for i, h := range g.Hands {
+    tilesBefore[i], jokersBefore[i] = occupiedCount(h.Hidden), jokers(h)
+  }
+
+  state := StateUnit{ Player: 0, State: "Charleston", Phase: "PassProcessing" }
+  for state.State == "Charleston" {
+    state = g.processState(state)
+  }
+  if state.State != "HaveWin" || state.Player != g.StartPlayer || g.CharlestonPass != len(CharlestonOffsets) {
+    t.Errorf("expected play to begin with the dealer after six passes, got %v after %d", state, g.CharlestonPass)
+  }
+  for i, h := range g.Hands {
+    if occupiedCount(h.Hidden) != tilesBefore[i] || jokers(h) != jokersBefore[i] {
+      t.Errorf("player %d went from %d tiles and %d jokers to %d and %d", i, tilesBefore[i], jokersBefore[i], occupiedCount(h.Hidden), jokers(h))
+    }
+  }
+}
@@ -0,0 +1,205 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "fmt"
+  "io/ioutil"
+  "path/filepath"
+  "sync"
+  "testing"
+)
+
+// counts of tiles given in notation
+func patternTestCounts(t *testing.T, tiles string) [][]int {
+  parsed, err := NewTilePool().Parse(tiles)
+  if err != nil {
+    t.Fatalf("%s: %v", tiles, err)
+  }
+  h := PlayerHand{ Hidden: parsed }
+  counts, _, _ := h.CountHiddenTiles(EmptyTile)
+  return counts
+}
+
+func TestBuiltinHandPatterns(t *testing.T) {
+  testCases := []struct {
+    Special string
+    Tiles string
+    Outcome bool
+  }{
+    { "thirteenOrphans", "19p19s19m12345677z", true },
+    { "thirteenOrphans", "19p19s99m12345677z", false },
+    { "sevenPairs", "11p22p33s44s55m66m77z", true },
+    { "sevenPairs", "11p22p33s44s55m66m777z", false },
+    { "sevenPairs", "1111p33s44s55m66m77z", false },
+    { "sevenPairsDoubled", "1111p33s44s55m66m77z", true },
+    { "nineGates", "11123455678999p", true },
+    { "nineGates", "11123455678999p1s", false },
+    { "allGreen", "234s234s666s888s66z", true },
+    { "allGreen", "234s234s666s888s77z", false },
+    { "knittedStraight", "147p258s369m123p55z", true },
+    { "knittedStraight", "147p258s369m123p56z", false },
+    { "lesserHonorsKnitted", "147p258s36m123456z", true },
+    { "lesserHonorsKnitted", "147p258s36m123466z", false },
+    { "greaterHonorsKnitted", "1234567z147p25s69m", true },
+    { "greaterHonorsKnitted", "1234567z147p25s68m", false },
+  }
+
+  for _, testCase := range testCases {
+    p, err := ParseHandPattern(BuiltinHandPatterns[testCase.Special])
+    if err != nil {
+      t.Fatalf("%s: %v", testCase.Special, err)
+    }
+    if p.TileCount() != StandardHandSize+1 {
+      t.Errorf("%s uses %d tiles", testCase.Special, p.TileCount())
+    }
+    if p.Matches(patternTestCounts(t, testCase.Tiles)) != testCase.Outcome {
+      t.Errorf("%s with %s: expected %v", testCase.Special, testCase.Tiles, testCase.Outcome)
+    }
+  }
+}
+
+func TestSpecialWinsWithKongs(t *testing.T) {
+  hongkong := RulePresets["hongkong"]
+  hongkong.SpecialHands = append(append([]string{}, hongkong.SpecialHands...), "allGreen")
+  testCases := []struct {
+    Rules RuleSet
+    Hidden string
+    Revealed string
+    Special string
+  }{
+    { RulePresets["mcr"], "147p258s369m1z1z", "777z", "knitted straight" },
+    { RulePresets["mcr"], "147p258s369m1z1z", "7777z", "knitted straight" },
+    { hongkong, "333444666s8s8s", "222s", "all green" },
+    { hongkong, "333444666s8s8s", "2222s", "all green" },
+    // the 1p of the knitted straight is locked in a revealed chow
+    { RulePresets["mcr"], "447p258s369m5z5z", "123p", "" },
+    { hongkong, "333444666s8s8s", "234s", "all green" },
+  }
+
+  for _, testCase := range testCases {
+    pool := NewTilePool()
+    tiles, err := pool.Parse(testCase.Hidden)
+    if err != nil {
+      t.Fatal(err)
+    }
+    sets, err := pool.ParseSets(testCase.Revealed)
+    if err != nil {
+      t.Fatal(err)
+    }
+    rules := testCase.Rules
+    h := PlayerHand{ Hidden: tiles[:len(tiles)-1], RevealedSets: len(sets), RevealedTileSets: sets, Rules: &rules }
+    matched := ""
+    for _, p := range rules.SpecialWins(h, tiles[len(tiles)-1]) {
+      matched = p.Name
+    }
+    if matched != testCase.Special || h.HaveWin(tiles[len(tiles)-1], "other") != (testCase.Special != "") {
+      t.Errorf("%s with %s revealed should win as %q, matched %q", testCase.Hidden, testCase.Revealed, testCase.Special, matched)
+    }
+  }
+}
+
+func TestParseHandPattern(t *testing.T) {
+  p, err := ParseHandPattern("wriggling snake: 123456789a ESWN tile[a] ; 13 mcr=88 c")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if p.Name != "wriggling snake" || p.PointsFor("hongkong") != 13 || p.PointsFor("mcr") != 88 || !p.Concealed || len(p.Terms) != 3 {
+    t.Errorf("unexpected pattern %+v", p)
+  }
+
+  for _, bad := range []string{
+    "no points: pair*7 ;",
+    "no separator pair*7 4",
+    "no terms: ; 4",
+    "unknown kind: triple*4 pair ; 4",
+    "no suit: 123 pair ; 4",
+    "bad repeat: pair*0 ; 4",
+    "unclosed: pair[a pair*6 ; 4",
+    "flowers: FF pair*6 ; 4",
+  } {
+    if _, err := ParseHandPattern(bad); err == nil {
+      t.Errorf("pattern %q should not parse", bad)
+    }
+  }
+  
+  // patterns are parsed from games played at once
+  var wg sync.WaitGroup
+  for i := 0; i < 8; i++ {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      if _, err := ParseHandPattern(fmt.Sprintf("pairs %d: pair*7 ; %d", i, i+1)); err != nil {
+        t.Error(err)
+      }
+    }(i)
+  }
+  wg.Wait()
+}
+
+func TestEnabledPatterns(t *testing.T) {
+  r, _ := PresetRules("hongkong")
+  patterns, err := r.EnabledPatterns()
+  if err != nil || len(patterns) != len(r.SpecialHands) {
+    t.Errorf("expected a pattern for each special hand, got %d, %v", len(patterns), err)
+  }
+  
+  // a pattern that does not parse, or an unknown special hand, is an error rather than left out
+  r.HandPatterns = []string{ "no points: pair*7 ;" }
+  if _, err := r.EnabledPatterns(); err == nil {
+    t.Errorf("expected the unparsed pattern to be reported")
+  }
+  if err := r.Validate(); err == nil {
+    t.Errorf("expected the rule set to be refused")
+  }
+  r.HandPatterns = nil
+  r.SpecialHands = append(r.SpecialHands, "fourWinds")
+  if _, err := r.EnabledPatterns(); err == nil {
+    t.Errorf("expected the unknown special hand to be reported")
+  }
+}
+
+func TestHouseHandPattern(t *testing.T) {
+  tomlFile := filepath.Join(t.TempDir(), "house.toml")
+  ioutil.WriteFile(tomlFile, []byte("specialHands = [\"sevenPairs\"]\nhandPatterns = [\"wriggling snake: 123456789a ESWN tile[a] ; 13 c\"]\n"), 0644)
+  r, err := LoadRuleSet(tomlFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  testHand, _ := gt.TestHandMaker("🀐🀑🀒🀓🀔🀕🀖🀗🀘🀀🀁🀂🀃;🀓")
+  testHand.Rules = &r
+  if testHand.HaveWin(EmptyTile, "draw") {
+    t.Errorf("thirteen tiles should not win")
+  }
+  _, draw := gt.TestHandMaker(";🀓")
+  if !testHand.HaveWin(draw, "previous") {
+    t.Errorf("the wriggling snake should win")
+  }
+  score := ScoreWin(WinContext{ Hand: testHand, Consider: draw, Source: "previous", Rules: r })
+  if score.Points != 13 || score.Patterns[0].Name != "wriggling snake" {
+    t.Errorf("expected the wriggling snake for 13 faan, got %v", score)
+  }
+
+  // the hand is concealed only
+  testHand.RevealedSets = 1
+  if len(testHand.SpecialWins(draw)) != 0 {
+    t.Errorf("a concealed pattern should not match with a revealed set")
+  }
+}
@@ -0,0 +1,81 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "strings"
+  "testing"
+)
+
+// every state transition in the tests is audited
+func init() {
+  AuditMode = true
+}
+
+func TestAuditedGames(t *testing.T) {
+  for name := range RulePresets {
+    for i := 0; i < 5; i++ {
+      g := New()
+      if name == "american" {
+        g.Rules = *americanTestRules(t)
+      } else {
+        g.Rules, _ = PresetRules(name)
+      }
+      g.Initialize(0, []bool{ true, true, true, true })
+      if err := g.Audit(); err != nil {
+        t.Fatalf("%s after the deal: %v", name, err)
+      }
+      g.BeginGame()
+    }
+  }
+}
+
+func TestAuditFindsViolations(t *testing.T) {
+  g := New()
+  g.Initialize(0, []bool{ true, true, true, true })
+  
+  // a tile lost from a hand
+  lost := g.Hands[1].Hidden[0]
+  g.Hands[1].Hidden[0] = EmptyTile
+  err := g.Audit()
+  if err == nil || !strings.Contains(err.Error(), lost.String()+" missing") || !strings.Contains(err.Error(), "P1 holds 12 hidden tiles") {
+    t.Errorf("expected the lost tile %v and short hand to be reported, got %v", lost, err)
+  }
+  
+  // the same tile held twice
+  g.Hands[1].Hidden[0] = lost
+  g.Discard = append(g.Discard, DiscardedTile{ Player: 2, Item: lost })
+  err = g.Audit()
+  if err == nil || !strings.Contains(err.Error(), lost.String()+" held 2 times: P1 hidden 0, discard 0") {
+    t.Errorf("expected the duplicate %v to be reported, got %v", lost, err)
+  }
+  
+  // claimed discards are held by the claiming set
+  g.Discard[0].Claimed = true
+  if err = g.Audit(); err != nil {
+    t.Errorf("expected a clean audit, got %v", err)
+  }
+  
+  // a pointer out of step with the wall
+  g.DrawPointer++
+  err = g.Audit()
+  if err == nil || !strings.Contains(err.Error(), "draw pointer") {
+    t.Errorf("expected the draw pointer to be reported, got %v", err)
+  }
+}
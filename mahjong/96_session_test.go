@@ -0,0 +1,131 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "sort"
+  "strings"
+  "testing"
+)
+
+func TestSeating(t *testing.T) {
+  rules, _ := PresetRules("classic")
+  names := []string{ "ann", "bob", "cy", "dee" }
+  
+  s := NewSession(rules, names, []bool{ false, true, true, true })
+  if err := s.Seat(SeatingDice, false); err != nil {
+    t.Fatal(err)
+  }
+  if s.SeatingRoll < 3 || s.SeatingRoll > 18 || s.FirstEast != (s.SeatingRoll-1)%4 || s.Dealer != s.FirstEast {
+    t.Errorf("a roll of %d should seat the first east at %d, got %d with dealer %d", s.SeatingRoll, (s.SeatingRoll-1)%4, s.FirstEast, s.Dealer)
+  }
+  if strings.Join(s.Names, ",") != "ann,bob,cy,dee" || s.SeatWind(s.FirstEast) != 1 || s.SeatWind((s.FirstEast+1)%4) != 2 {
+    t.Errorf("seating by dice should keep the seats, with winds following east, got %v", s.Names)
+  }
+  
+  s = NewSession(rules, names, []bool{ false, true, true, true })
+  if err := s.Seat(SeatingTiles, false); err != nil {
+    t.Fatal(err)
+  }
+  seated := append([]string{}, s.Names...)
+  sort.Strings(seated)
+  if s.FirstEast != 0 || s.Dealer != 0 || strings.Join(seated, ",") != "ann,bob,cy,dee" {
+    t.Errorf("seating by tiles should seat every player with east first, got %v and first east %d", s.Names, s.FirstEast)
+  }
+  for i, name := range s.Names {
+    if s.ComputerPlayers[i] != (name != "ann") {
+      t.Errorf("seat %d (%s) kept the wrong player type", i, name)
+    }
+  }
+  
+  if err := s.Seat("cards", false); err == nil {
+    t.Errorf("expected an unknown seating to be refused")
+  }
+  
+  // the game takes its names, seat winds and prevailing wind from the session
+  g := s.NewGame()
+  g.Initialize(s.Dealer, s.ComputerPlayers)
+  for i := range g.Hands {
+    if g.SeatWind(i) != s.SeatWind(i) || g.PlayerName(i) != s.Names[i] {
+      t.Errorf("seat %d is %s as wind %d in the game, %s as wind %d in the session", i, g.PlayerName(i), g.SeatWind(i), s.Names[i], s.SeatWind(i))
+    }
+  }
+}
+
+func TestSessionRounds(t *testing.T) {
+  rules, _ := PresetRules("classic")
+  s := NewSession(rules, nil, nil)
+  s.FirstEast, s.Dealer = 2, 2
+  
+  // the dealer keeps the deal after winning or as allowed after a draw
+  s.Record(true, 2)
+  s.Record(false, 2)
+  if s.Dealer != 2 || s.PrevailingWind != 1 || s.Games != 2 {
+    t.Errorf("expected the dealer to stay, got dealer %d in wind %d", s.Dealer, s.PrevailingWind)
+  }
+  
+  // the prevailing wind moves on when the deal returns to the first east
+  for _, winner := range []int{ 0, 1, 2, 0 } {
+    s.Record(true, winner)
+  }
+  if s.Dealer != 2 || s.PrevailingWind != 2 || s.Names[0] != "Player 0" {
+    t.Errorf("expected the south round to start with dealer 2, got dealer %d in wind %d", s.Dealer, s.PrevailingWind)
+  }
+  
+  for i := 0; i < 12; i++ {
+    s.Record(true, (s.Dealer+1)%4)
+  }
+  if !s.Over() || s.PrevailingWind != 5 {
+    t.Errorf("expected the session to end after the north round, got wind %d", s.PrevailingWind)
+  }
+}
+
+func TestRoundLabels(t *testing.T) {
+  rules, _ := PresetRules("classic")
+  s := NewSession(rules, []string{ "ann", "bob", "cy", "dee" }, nil)
+  s.FirstEast, s.Dealer = 3, 3
+  
+  // a drawn game leaves a bonus stick; the dealer then wins, adding another, and loses the deal
+  s.Record(false, 3)
+  s.Record(true, 3)
+  s.Record(true, 2)
+  if s.Hand() != 2 || s.BonusSticks != 0 {
+    t.Errorf("expected the second hand without bonus sticks, got hand %d with %d", s.Hand(), s.BonusSticks)
+  }
+  s.Record(false, 0)
+  
+  g := s.NewGame()
+  g.Initialize(s.Dealer, s.ComputerPlayers)
+  if label := g.RoundLabel(); label != "East 2, 1 bonus stick" {
+    t.Errorf("expected East 2, 1 bonus stick, got %q", label)
+  }
+  if label := g.SeatLabel(0); label != "East ann, dealer" {
+    t.Errorf("expected seat 0 to be the dealer, got %q", label)
+  }
+  if label := g.SeatLabel(3); label != "North dee" {
+    t.Errorf("expected seat 3 to be north, got %q", label)
+  }
+  
+  screen := g.RenderTable(1, -1, nil)
+  for _, expected := range []string{ "East 2, 1 bonus stick", "P1 (you) South bob", "P0 (left) East ann, dealer" } {
+    if !strings.Contains(screen, expected) {
+      t.Errorf("rendered table is missing %q:\n%s", expected, screen)
+    }
+  }
+}
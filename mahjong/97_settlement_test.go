@@ -0,0 +1,151 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// game with four hands and the dealer at seat 0, won by a seat
+func settlementTestGame(rules string, win ScoredWin, winner int, dealtIn int) *Game {
+  g := New()
+  g.Rules, _ = PresetRules(rules)
+  g.Hands = make([]PlayerHand, g.Rules.Players)
+  g.Names = []string{ "ann", "bob", "cy", "dee" }
+  g.Win, g.Winner, g.DealtIn = win, winner, dealtIn
+  return g
+}
+
+func TestPayments(t *testing.T) {
+  faan := ScoredWin{ Unit: "faan", Points: 3 }
+  // a mangan: 2000 basic points
+  mangan := ScoredWin{ Unit: "han", Points: 5, BasePoints: 2000 }
+  
+  tests := []struct {
+    rules string
+    payment string
+    win ScoredWin
+    winner int
+    dealtIn int
+    expected []int
+  }{
+    // the discarder pays every share
+    { "classic", "", faan, 1, 2, []int{ 0, 24, -24, 0 } },
+    { "classic", PaymentAllPay, faan, 1, 2, []int{ -8, 24, -8, -8 } },
+    { "classic", "", faan, 1, -1, []int{ -8, 24, -8, -8 } },
+    // riichi: 4 basic points from the discarder, 6 to the dealer; 1 from each and 2 from the dealer on a self-draw
+    { "riichi", "", mangan, 1, 2, []int{ 0, 8000, -8000, 0 } },
+    { "riichi", "", mangan, 0, 2, []int{ 12000, 0, -12000, 0 } },
+    { "riichi", "", mangan, 1, -1, []int{ -4000, 8000, -2000, -2000 } },
+    // no winner, no payments
+    { "classic", "", faan, -1, -1, []int{ 0, 0, 0, 0 } },
+  }
+  
+  for _, test := range tests {
+    g := settlementTestGame(test.rules, test.win, test.winner, test.dealtIn)
+    if test.payment != "" {
+      g.Rules.Payment = test.payment
+    }
+    if payments := g.Payments(); !reflect.DeepEqual(payments, test.expected) {
+      t.Errorf("%s %s: seat %d winning from %d should be paid %v, got %v", test.rules, test.payment, test.winner, test.dealtIn, test.expected, payments)
+    }
+  }
+  
+  // bob and cy declare riichi with a stick left from a drawn game: bob collects all three
+  g := settlementTestGame("riichi", mangan, 1, 2)
+  g.Hands[1].Riichi, g.Hands[2].Riichi = true, true
+  g.RiichiSticks = 3
+  if payments := g.Payments(); !reflect.DeepEqual(payments, []int{ 0, 10000, -9000, 0 }) {
+    t.Errorf("expected bob to collect the riichi sticks, got %v", payments)
+  }
+  // a drawn game keeps the deposits on the table
+  g = settlementTestGame("riichi", mangan, -1, -1)
+  g.Hands[0].Riichi = true
+  g.RiichiSticks = 1
+  if payments := g.Payments(); !reflect.DeepEqual(payments, []int{ -1000, 0, 0, 0 }) {
+    t.Errorf("expected ann's deposit to stay on the table, got %v", payments)
+  }
+  
+  rules, _ := PresetRules("classic")
+  rules.Payment = "loserPays"
+  if err := rules.Validate(); err == nil {
+    t.Errorf("expected an unknown payment to be refused")
+  }
+}
+
+func TestLedger(t *testing.T) {
+  rules, _ := PresetRules("classic")
+  s := NewSession(rules, []string{ "ann", "bob", "cy", "dee" }, nil)
+  
+  s.Settle(settlementTestGame("classic", ScoredWin{ Unit: "faan", Points: 3 }, 1, 2))
+  s.Settle(settlementTestGame("classic", ScoredWin{ Unit: "faan", Points: 2 }, 3, -1))
+  entry := s.Settle(settlementTestGame("classic", ScoredWin{}, -1, -1))
+  if entry.Winner != "" || len(s.Ledger.Entries) != 3 {
+    t.Errorf("expected a drawn game to be recorded without a winner, got %v", entry)
+  }
+  
+  expected := map[string]int{ "ann": -4, "bob": 20, "cy": -28, "dee": 12 }
+  if !reflect.DeepEqual(s.Ledger.Balances, expected) {
+    t.Errorf("expected balances %v, got %v", expected, s.Ledger.Balances)
+  }
+  
+  settlement := strings.Join(s.Ledger.Settlement(), "\n")
+  if settlement != "bob: +20\ndee: +12\nann: -4\ncy: -28\ncy pays bob 20\ncy pays dee 8\nann pays dee 4" {
+    t.Errorf("unexpected settlement:\n%s", settlement)
+  }
+  
+  // riichi sticks left by a drawn game are carried to the next game, and cleared by a win
+  rules, _ = PresetRules("riichi")
+  r := NewSession(rules, []string{ "ann", "bob", "cy", "dee" }, nil)
+  drawn := settlementTestGame("riichi", ScoredWin{}, -1, -1)
+  drawn.RiichiSticks = 2
+  r.Settle(drawn)
+  if next := r.NewGame(); next.RiichiSticks != 2 {
+    t.Errorf("expected 2 riichi sticks to be carried to the next game, got %d", next.RiichiSticks)
+  }
+  won := settlementTestGame("riichi", ScoredWin{ Unit: "han", Points: 5, BasePoints: 2000 }, 1, -1)
+  won.RiichiSticks = 2
+  r.Settle(won)
+  if r.RiichiSticks != 0 || r.Ledger.Balances["bob"] != 10000 {
+    t.Errorf("expected bob to collect the carried sticks, got %d left and a balance of %d", r.RiichiSticks, r.Ledger.Balances["bob"])
+  }
+  
+  // the ledger is saved with the session
+  dir, err := ioutil.TempDir("", "mahjong")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  path := filepath.Join(dir, "session.json")
+  if err := s.Save(path); err != nil {
+    t.Fatal(err)
+  }
+  loaded, err := LoadSession(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(loaded.Ledger, s.Ledger) || !reflect.DeepEqual(loaded.Names, s.Names) {
+    t.Errorf("expected the session to be reloaded with its ledger, got %v", loaded.Ledger)
+  }
+}
@@ -0,0 +1,91 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "io/ioutil"
+  "os"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestProfiles(t *testing.T) {
+  profiles := make(Profiles)
+  
+  // bob claims a pong and a chow and wins on cy's discard
+  g := settlementTestGame("classic", ScoredWin{ Unit: "faan", Points: 3, Patterns: []ScoredPattern{ { Name: "all triplets", Points: 3 } } }, -1, -1)
+  g.LogAction(0, "discard", nil, "", "")
+  g.LogAction(1, "pong", nil, "", "")
+  g.LogAction(1, "discard", nil, "", "")
+  g.LogAction(2, "seq", nil, "", "")
+  g.LogAction(2, "discard", nil, "", "")
+  g.LogAction(1, "win", nil, "discard", "")
+  profiles.Record(g)
+  
+  // dee wins self-drawn after a concealed kong, which is not a claim
+  g = settlementTestGame("classic", ScoredWin{ Unit: "faan", Points: 1, Patterns: []ScoredPattern{ { Name: "self-drawn", Points: 1 } } }, -1, -1)
+  g.LogAction(3, "kong", nil, "draw", "")
+  g.LogAction(3, "win", nil, "draw", "")
+  profiles.Record(g)
+  
+  bob, cy, dee := profiles["bob"], profiles["cy"], profiles["dee"]
+  if bob.Games != 2 || bob.Wins != 1 || bob.WinValue != 8 || bob.Patterns["all triplets"] != 1 || bob.Claims["pong"] != 1 {
+    t.Errorf("unexpected profile for bob: %+v", *bob)
+  }
+  if cy.DealtIn != 1 || cy.Claims["seq"] != 1 || cy.Wins != 0 {
+    t.Errorf("unexpected profile for cy: %+v", *cy)
+  }
+  if dee.SelfDrawnWins != 1 || dee.Claims["kong"] != 0 || profiles["ann"].DealtIn != 0 {
+    t.Errorf("unexpected profile for dee: %+v", *dee)
+  }
+  
+  lines := strings.Join(bob.Lines(), "\n")
+  for _, expected := range []string{ "bob: 2 games", "win rate 50.0%", "average hand value 8.0", "all triplets ×1", "claims 0.50 a game" } {
+    if !strings.Contains(lines, expected) {
+      t.Errorf("statistics are missing %q:\n%s", expected, lines)
+    }
+  }
+  
+  // a seat without a name is not profiled, though the named players are
+  g = settlementTestGame("classic", ScoredWin{ Unit: "faan", Points: 1 }, -1, -1)
+  g.Names[2] = PlaceholderName(2)
+  g.LogAction(0, "discard", nil, "", "")
+  g.LogAction(2, "win", nil, "discard", "")
+  profiles.Record(g)
+  if profiles["Player 2"] != nil || profiles["ann"].DealtIn != 1 || profiles["ann"].Games != 3 {
+    t.Errorf("unexpected profiles %v", profiles.Names())
+  }
+  
+  dir, err := ioutil.TempDir("", "mahjong")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  if empty, err := LoadProfiles(dir); err != nil || len(empty) != 0 {
+    t.Errorf("expected no profiles in a new data directory, got %v, %v", empty, err)
+  }
+  if err := profiles.Save(dir); err != nil {
+    t.Fatal(err)
+  }
+  loaded, err := LoadProfiles(dir)
+  if err != nil || !reflect.DeepEqual(loaded, profiles) {
+    t.Errorf("expected the profiles to be reloaded, got %v, %v", loaded, err)
+  }
+}
@@ -0,0 +1,115 @@
+/*
+mahjong: A computer-mediated Mah Jong game implemented in Go
+Copyright (C) 2016 <code@0n0e.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mahjong
+
+import (
+  "io/ioutil"
+  "math"
+  "os"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestStrategies(t *testing.T) {
+  rules, _ := PresetRules("classic")
+  s := NewSession(rules, []string{ "ann", "bob", "cy", "dee" }, nil)
+  if err := s.SetStrategies([]string{ "", "efficiency", "sets", "efficiency" }); err != nil {
+    t.Fatal(err)
+  }
+  if s.ComputerPlayers[0] || !s.ComputerPlayers[1] || s.RatingName(0) != "ann" || s.RatingName(1) != "bot:efficiency" || s.RatingName(2) != "bot:sets" {
+    t.Errorf("unexpected computer players %v rated as %s, %s, %s", s.ComputerPlayers, s.RatingName(0), s.RatingName(1), s.RatingName(2))
+  }
+  if err := s.SetStrategies([]string{ "guess" }); err == nil {
+    t.Errorf("expected an unknown strategy to be refused")
+  }
+  
+  // computer players of each strategy play complete games
+  for _, strategy := range StrategyNames() {
+    s = NewSession(rules, nil, nil)
+    s.SetStrategies([]string{ strategy, strategy, strategy, strategy })
+    g := s.NewGame()
+    g.Initialize(s.Dealer, s.ComputerPlayers)
+    if g.Hands[2].Strategy != strategy {
+      t.Errorf("expected the game's hands to play %s, got %q", strategy, g.Hands[2].Strategy)
+    }
+    g.BeginGame()
+  }
+}
+
+func TestRatings(t *testing.T) {
+  if places := Places([]int{ 5, -3, 5, -7 }); !reflect.DeepEqual(places, []int{ 1, 3, 1, 4 }) {
+    t.Errorf("unexpected places %v", places)
+  }
+  
+  ratings := make(Ratings)
+  ratings.Record([]string{ "ann", "bot:sets", "bot:efficiency", "dee" }, []int{ 10, -2, 0, -8 })
+  total := 0.0
+  for _, r := range ratings {
+    total += r.Rating - InitialRating
+  }
+  if math.Abs(total) > 1e-9 {
+    t.Errorf("ratings among equals should be zero-sum, changed by %v in all", total)
+  }
+  ann, dee := ratings["ann"], ratings["dee"]
+  if math.Abs(ann.Rating-(InitialRating+16)) > 1e-9 || math.Abs(dee.Rating-(InitialRating-16)) > 1e-9 || ann.Firsts != 1 || dee.Places != 4 {
+    t.Errorf("unexpected ratings for ann %+v and dee %+v", *ann, *dee)
+  }
+  
+  // seats of one strategy are not matched against each other, and an upset moves the ratings further
+  ratings.Record([]string{ "dee", "bot:sets", "bot:sets", "ann" }, []int{ 6, 0, 0, -6 })
+  if ratings["bot:sets"].Sessions != 3 || ratings["dee"].Rating-(InitialRating-16) <= 16 {
+    t.Errorf("unexpected ratings after the upset: %+v, %+v", *ratings["bot:sets"], *ratings["dee"])
+  }
+  
+  // a session rates its named players and computer strategies, leaving out players who gave no name
+  rules, _ := PresetRules("classic")
+  s := NewSession(rules, []string{ "ann" }, []bool{ false, true, false, true })
+  s.Games = 1
+  s.Scores = []int{ 4, 0, -2, -2 }
+  if !IsPlaceholderName(s.Names[2]) || IsPlaceholderName("ann") || IsPlaceholderName("Player 2b") || s.RatingName(2) != "" || s.RatingName(1) != "bot:"+DefaultStrategy {
+    t.Errorf("unexpected rating names %q, %q for %v", s.RatingName(1), s.RatingName(2), s.Names)
+  }
+  sessions := ratings["ann"].Sessions
+  s.Rate(ratings)
+  if ratings["ann"].Sessions != sessions+1 || ratings[s.Names[2]] != nil || ratings[""] != nil {
+    t.Errorf("expected the unnamed seat to be left out, got %v", ratings.Names())
+  }
+  
+  board := ratings.Leaderboard(true)
+  if len(board) != 2 || !strings.HasPrefix(board[0], "1. bot:") {
+    t.Errorf("expected the computer strategies only, got %v", board)
+  }
+  if board = ratings.Leaderboard(false); len(board) != 4 || !strings.Contains(board[0], "average place") {
+    t.Errorf("unexpected leaderboard %v", board)
+  }
+  
+  dir, err := ioutil.TempDir("", "mahjong")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  if err := ratings.Save(dir); err != nil {
+    t.Fatal(err)
+  }
+  loaded, err := LoadRatings(dir)
+  if err != nil || !reflect.DeepEqual(loaded, ratings) {
+    t.Errorf("expected the ratings to be reloaded, got %v, %v", loaded, err)
+  }
+}
@@ -25,18 +25,53 @@ import (
   "io"
   "io/ioutil"
   "os"
-  //"fmt"
+  "strings"
+  "time"
+  "fmt"
 )
 
 func main() {
-  p := -1
+  if len(os.Args) > 1 {
+    switch os.Args[1] {
+      case "analyze":
+        analyzeCommand(os.Args[2:])
+        return
+      case "train":
+        trainCommand(os.Args[2:])
+        return
+      case "puzzle":
+        puzzleCommand(os.Args[2:])
+        return
+      case "stats":
+        statsCommand(os.Args[2:])
+        return
+      case "leaderboard":
+        leaderboardCommand(os.Args[2:])
+        return
+    }
+  }
+  
   game := 0
   
   singlePlayerMode := flag.Bool("singlePlayer", false, "single player mode with computer players? [bool]")
   logFile := flag.String("logFile", "", "log file for game [file path]")
+  logFormat := flag.String("logFormat", mahjong.LogFormatText, "log file format [text|json]")
+  tui := flag.Bool("tui", false, "full-screen terminal interface with keyboard tile selection? [bool]")
+  assist := flag.Bool("assist", false, "show ready-hand waits and warn before breaking a ready hand? [bool]")
+  showWall := flag.Bool("wall", false, "draw the walls around the table, with the break and the dead wall? [bool]")
+  rulesSource := flag.String("rules", mahjong.DefaultRuleSet, "rule set: a preset (classic, hongkong, simple, sanma, riichi, mcr, taiwanese, american) or a .json/.toml rule file [preset|file path]")
+  audit := flag.Bool("audit", false, "check tile conservation, hand sizes and wall pointers after every state transition? [bool]")
+  names := flag.String("names", "", "player names in arrival order, separated by commas; seat 0 is the tentative east [names]")
+  seating := flag.String("seating", mahjong.SeatingDice, "how the first east is chosen: dice (rolled by the tentative east) or tiles (each player draws a wind) [dice|tiles]")
+  games := flag.Int("games", 1, "games to play in the session, which also ends after the last round of the winds [int]")
+  sessionFile := flag.String("session", "", "session file: the ledger of chips is carried over from it, if present, and the session saved to it after each game [file path]")
+  dataDir := flag.String("dataDir", mahjong.DefaultDataDir(), "directory of the player profiles, updated after each game, and the ratings, updated when the session is complete; players without a name are left out [directory path]")
+  bots := flag.String("bots", "", "discard strategy of each computer player in arrival order, separated by commas; seats left empty are human (strategies: "+strings.Join(mahjong.StrategyNames(), ", ")+") [strategies]")
     
   flag.Parse()
   
+  mahjong.AuditMode = *audit
+  
   var computerPlayers []bool = make([]bool, 4, 4)
   if *singlePlayerMode {
     computerPlayers[1] = *singlePlayerMode
@@ -56,18 +91,264 @@ func main() {
     }
   }
   
-  logInstance := log.New(outputLogDestination, "ACTION: ", 0)
+  if *logFormat != mahjong.LogFormatText && *logFormat != mahjong.LogFormatJson {
+    log.Fatalln("Unknown log format: ", *logFormat)
+  }
+  
+  logPrefix := "ACTION: "
+  if *logFormat == mahjong.LogFormatJson {
+    // one json object per line
+    logPrefix = ""
+  }
+  logInstance := log.New(outputLogDestination, logPrefix, 0)
+  
+  rules, err := mahjong.LoadRuleSet(*rulesSource)
+  if err != nil {
+    log.Fatalln("Could not load rules:", err)
+  }
     
-  // single game mode for now
-  for game < 1 {
+  var playerNames []string
+  if *names != "" {
+    playerNames = strings.Split(*names, ",")
+  }
+  session := mahjong.NewSession(rules, playerNames, computerPlayers)
+  if *bots != "" {
+    if err := session.SetStrategies(strings.Split(*bots, ",")); err != nil {
+      log.Fatalln("Could not set the computer players:", err)
+    }
+  }
+  if *sessionFile != "" {
+    saved, err := mahjong.LoadSession(*sessionFile)
+    if err == nil {
+      session.Ledger = saved.Ledger
+    } else if !os.IsNotExist(err) {
+      log.Fatalln("Could not load the session:", err)
+    }
+  }
+  err = session.Seat(*seating, mahjong.DeterministicRand)
+  if err != nil {
+    log.Fatalln("Could not seat the players:", err)
+  }
+  for i, name := range session.Names {
+    fmt.Printf("Seat %d: %s, sitting as %s\n", i, name, mahjong.WindNames[session.SeatWind(i)])
+  }
+  
+  // one terminal interface for the session, which holds the terminal settings until it ends
+  var terminal *mahjong.TerminalUi
+  if *tui {
+    terminal = mahjong.NewTerminalUi()
+  }
+  
+  for game < *games && !session.Over() {
     // new game
-    currentGame := mahjong.New()
+    currentGame := session.NewGame()
     currentGame.OutputLog = logInstance
-    currentGame.Initialize(p, computerPlayers)
+    currentGame.LogFormat = *logFormat
+    currentGame.Assist = *assist
+    currentGame.ShowWall = *showWall
+    currentGame.Tui = terminal
+    currentGame.Initialize(session.Dealer, session.ComputerPlayers)
 
     // return outcome
-    _, p = currentGame.BeginGame()
+    won, p := currentGame.BeginGame()
+    entry := session.Settle(currentGame)
+    for _, name := range session.Names {
+      fmt.Printf("%s: %+d\n", name, entry.Payments[name])
+    }
+    session.Record(won, p)
+    
+    profiles, err := mahjong.LoadProfiles(*dataDir)
+    if err == nil {
+      profiles.Record(currentGame)
+      err = profiles.Save(*dataDir)
+    }
+    if err != nil {
+      log.Println("Could not update the player profiles:", err)
+    }
+    
+    if *sessionFile != "" {
+      if err := session.Save(*sessionFile); err != nil {
+        if terminal != nil {
+          terminal.Stop()
+        }
+        log.Fatalln("Could not save the session:", err)
+      }
+    }
 
     game++
   }
+  
+  if terminal != nil {
+    terminal.Stop()
+  }
+  session.Ledger.OutputSettlement()
+  
+  // only a complete session, once every wind has been prevailing, is rated
+  if !session.Over() {
+    return
+  }
+  ratings, err := mahjong.LoadRatings(*dataDir)
+  if err == nil {
+    session.Rate(ratings)
+    err = ratings.Save(*dataDir)
+  }
+  if err != nil {
+    log.Println("Could not update the ratings:", err)
+  }
+}
+
+// analyze a hand outside of a game
+func analyzeCommand(args []string) {
+  analyzeFlags := flag.NewFlagSet("analyze", flag.ExitOnError)
+  hand := analyzeFlags.String("hand", "", "hidden tiles in notation (e.g., 123m456p77z) or glyphs; may also be given as arguments [tiles]")
+  revealed := analyzeFlags.String("revealed", "", "revealed sets, comma separated (e.g., 555z,123m) [sets]")
+  visible := analyzeFlags.String("visible", "", "other visible tiles, such as discards [tiles]")
+  rulesSource := analyzeFlags.String("rules", mahjong.DefaultRuleSet, "rule set the hand is analyzed under: a preset or a .json/.toml rule file [preset|file path]")
+  
+  // allow flags to follow the tiles
+  handArgs := make([]string, 0, 1)
+  for {
+    analyzeFlags.Parse(args)
+    if analyzeFlags.NArg() == 0 {
+      break
+    }
+    handArgs = append(handArgs, analyzeFlags.Arg(0))
+    args = analyzeFlags.Args()[1:]
+  }
+  
+  if *hand == "" {
+    *hand = strings.Join(handArgs, "")
+  }
+  
+  rules, err := mahjong.LoadRuleSet(*rulesSource)
+  if err != nil {
+    log.Fatalln("Could not load rules:", err)
+  }
+  
+  pool := mahjong.NewTilePool()
+  hidden, err := pool.Parse(*hand)
+  if err != nil {
+    log.Fatalln("Could not read hand:", err)
+  }
+  sets, err := pool.ParseSets(*revealed)
+  if err != nil {
+    log.Fatalln("Could not read revealed sets:", err)
+  }
+  visibleTiles, err := pool.Parse(*visible)
+  if err != nil {
+    log.Fatalln("Could not read visible tiles:", err)
+  }
+  
+  for _, t := range hidden {
+    if t.IsSpecial() {
+      log.Fatalln("Special tiles are revealed and replaced, not held in hand:", t.Ud)
+    }
+    if rules.Removed(t.Suit, t.Value) {
+      log.Fatalln("The tile is not played under the rules:", t.Ud)
+    }
+  }
+  if size := len(hidden) + 3*len(sets); size != rules.HandSize && size != rules.HandSize+1 {
+    log.Fatalf("A hand has %d or %d tiles under the rules (counting each revealed set as 3); %d were given\n", rules.HandSize, rules.HandSize+1, size)
+  }
+  
+  h := mahjong.PlayerHand{ Hidden: hidden, RevealedSets: len(sets), RevealedTileSets: sets, Rules: &rules }
+  discard := make(mahjong.DiscardPile, 0, len(visibleTiles))
+  for _, t := range visibleTiles {
+    discard = append(discard, mahjong.DiscardedTile{ Player: -1, Item: t })
+  }
+  
+  h.Analyze(discard, []mahjong.PlayerHand{ h }).OutputAnalysis()
+}
+
+// discard efficiency training
+func trainCommand(args []string) {
+  trainFlags := flag.NewFlagSet("train", flag.ExitOnError)
+  hands := trainFlags.Int("hands", 10, "number of hands in the session [int]")
+  
+  trainFlags.Parse(args)
+  
+  var session mahjong.TrainingSession
+  session.Play(*hands)
+  session.OutputSummary()
+}
+
+// generate puzzles or solve a shared one
+func puzzleCommand(args []string) {
+  puzzleFlags := flag.NewFlagSet("puzzle", flag.ExitOnError)
+  kind := puzzleFlags.String("kind", mahjong.PuzzleDiscard, "puzzle kind [discard|waits]")
+  seed := puzzleFlags.Int64("seed", time.Now().UnixNano(), "seed for the first puzzle; each further puzzle uses the next seed [int]")
+  count := puzzleFlags.Int("count", 1, "number of puzzles [int]")
+  answers := puzzleFlags.Bool("answers", false, "show answers? [bool]")
+  solve := puzzleFlags.String("solve", "", "solve a shared puzzle line (e.g., \"waits 1112345678999m seed=3\") [line]")
+  
+  puzzleFlags.Parse(args)
+  
+  if *solve != "" {
+    p, err := mahjong.ParsePuzzle(*solve)
+    if err != nil {
+      log.Fatalln("Could not read puzzle:", err)
+    }
+    p.OutputPuzzle(true)
+    return
+  }
+  
+  for i := 0; i < *count; i++ {
+    p, err := mahjong.GeneratePuzzle(*kind, *seed+int64(i))
+    if err != nil {
+      log.Fatalln(err)
+    }
+    p.OutputPuzzle(*answers)
+    fmt.Println()
+  }
+}
+
+// print the statistics of player profiles
+func statsCommand(args []string) {
+  statsFlags := flag.NewFlagSet("stats", flag.ExitOnError)
+  dataDir := statsFlags.String("dataDir", mahjong.DefaultDataDir(), "directory of the player profiles [directory path]")
+  statsFlags.Parse(args)
+  
+  profiles, err := mahjong.LoadProfiles(*dataDir)
+  if err != nil {
+    log.Fatalln("Could not load the player profiles:", err)
+  }
+  
+  // named players, or everyone
+  names := statsFlags.Args()
+  if len(names) == 0 {
+    names = profiles.Names()
+  }
+  if len(names) == 0 {
+    fmt.Printf("No games recorded in %s\n", *dataDir)
+  }
+  for _, name := range names {
+    profile, found := profiles[name]
+    if !found {
+      fmt.Printf("%s: no games recorded\n", name)
+      continue
+    }
+    for _, line := range profile.Lines() {
+      fmt.Printf("%s\n", line)
+    }
+  }
+}
+
+// print the players and computer strategies by rating
+func leaderboardCommand(args []string) {
+  leaderboardFlags := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+  dataDir := leaderboardFlags.String("dataDir", mahjong.DefaultDataDir(), "directory of the ratings [directory path]")
+  botsOnly := leaderboardFlags.Bool("bots", false, "rank the computer strategies only? [bool]")
+  leaderboardFlags.Parse(args)
+  
+  ratings, err := mahjong.LoadRatings(*dataDir)
+  if err != nil {
+    log.Fatalln("Could not load the ratings:", err)
+  }
+  lines := ratings.Leaderboard(*botsOnly)
+  if len(lines) == 0 {
+    fmt.Printf("No sessions rated in %s\n", *dataDir)
+  }
+  for _, line := range lines {
+    fmt.Printf("%s\n", line)
+  }
 }